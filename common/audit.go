@@ -0,0 +1,113 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bishopfox/sliver/client/assets"
+	"github.com/bishopfox/sliver/protobuf/clientpb"
+	"github.com/bishopfox/sliver/protobuf/commonpb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// a single line of the audit log
+type AuditEntry struct {
+	Time          string          `json:"time"`
+	Operator      string          `json:"operator"`
+	Session       string          `json:"session,omitempty"`
+	Host          string          `json:"host,omitempty"`
+	Method        string          `json:"method"`
+	Args          json.RawMessage `json:"args,omitempty"`
+	Status        string          `json:"status"`
+	Error         string          `json:"error,omitempty"`
+	BytesSent     int             `json:"bytes_sent"`
+	BytesReceived int             `json:"bytes_received"`
+	DurationMs    int64           `json:"duration_ms"`
+}
+
+// AuditLogger writes one json line per rpc to the audit log
+type AuditLogger struct {
+	operator string
+	hosts    map[string]string
+	file     *os.File
+	mu       sync.Mutex
+}
+
+// Function to open the audit log, new entries are always appended
+//
+// :param: path string -> the audit log file
+// :param: configPath string -> the operator config, used to record who issued each rpc
+// :return: *auditLogger -> the audit logger
+// :return: error -> if the audit log can not be opened
+func NewAuditLogger(path string, configPath string) (*AuditLogger, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	operator := ""
+	if config, err := assets.ReadConfig(configPath); err == nil {
+		operator = config.Operator
+	}
+	return &AuditLogger{operator: operator, hosts: map[string]string{}, file: file}, nil
+}
+
+func (a *AuditLogger) Close() error {
+	return a.file.Close()
+}
+
+// Function to log every rpc issued through the rpc client, including ones that fail
+func (a *AuditLogger) Interceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+
+	entry := AuditEntry{
+		Time:       start.UTC().Format(time.RFC3339Nano),
+		Operator:   a.operator,
+		Method:     method,
+		Status:     "ok",
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if withRequest, ok := req.(interface{ GetRequest() *commonpb.Request }); ok && withRequest.GetRequest() != nil {
+		entry.Session = withRequest.GetRequest().SessionID
+	}
+	if message, ok := req.(proto.Message); ok {
+		entry.BytesSent = proto.Size(message)
+		if args, err := protojson.Marshal(message); err == nil {
+			entry.Args = args
+		}
+	}
+	if err != nil {
+		entry.Status = "error"
+		entry.Error = err.Error()
+	} else {
+		if message, ok := reply.(proto.Message); ok {
+			entry.BytesReceived = proto.Size(message)
+		}
+		// the server call worked but the implant may still have failed
+		if withResponse, ok := reply.(interface{ GetResponse() *commonpb.Response }); ok && withResponse.GetResponse() != nil && withResponse.GetResponse().Err != "" {
+			entry.Status = "implant error"
+			entry.Error = withResponse.GetResponse().Err
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// learn the hostname of every session so later entries can name the host
+	if sessions, ok := reply.(*clientpb.Sessions); ok && err == nil {
+		for _, session := range sessions.Sessions {
+			a.hosts[session.ID] = session.Hostname
+		}
+	}
+	entry.Host = a.hosts[entry.Session]
+
+	line, marshalErr := json.Marshal(entry)
+	if marshalErr == nil {
+		a.file.Write(append(line, '\n'))
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+// Package common is what every sliver client shares: the connection the interceptors hang off,
+// request pacing, the audit log, capture and replay, rpc errors and retries, and downloads to disk
+package common
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// interceptedConn hands every unary call made through the rpc client to our interceptors
+// before it goes to the sliver server, so nothing built on top of it can skip them
+type interceptedConn struct {
+	conn         grpc.ClientConnInterface
+	interceptors []grpc.UnaryClientInterceptor
+}
+
+// Function to wrap a connection so every unary call made through it goes through the interceptors
+//
+// :param: conn grpc.ClientConnInterface -> the connection to the sliver server, or a replay of one
+// :param: interceptors ...grpc.UnaryClientInterceptor -> called in order, the last one hands the call to conn
+// :return: grpc.ClientConnInterface -> the connection to build the rpc client on
+func NewInterceptedConn(conn grpc.ClientConnInterface, interceptors ...grpc.UnaryClientInterceptor) grpc.ClientConnInterface {
+	return &interceptedConn{conn: conn, interceptors: interceptors}
+}
+
+func (c *interceptedConn) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	cc, _ := c.conn.(*grpc.ClientConn)
+	var invoke func(index int) grpc.UnaryInvoker
+	invoke = func(index int) grpc.UnaryInvoker {
+		if index == len(c.interceptors) {
+			return func(ctx context.Context, method string, req, reply interface{}, _ *grpc.ClientConn, opts ...grpc.CallOption) error {
+				return c.conn.Invoke(ctx, method, req, reply, opts...)
+			}
+		}
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return c.interceptors[index](ctx, method, req, reply, cc, invoke(index+1), opts...)
+		}
+	}
+	return invoke(0)(ctx, method, args, reply, cc, opts...)
+}
+
+func (c *interceptedConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return c.conn.NewStream(ctx, desc, method, opts...)
+}
@@ -0,0 +1,207 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bishopfox/sliver/protobuf/commonpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorCategory is what went wrong with an rpc, it decides whether the rpc is retried, how the
+// failure is reported and which exit code the client finishes with
+type ErrorCategory int
+
+const (
+	CategoryUnknown ErrorCategory = iota
+	CategoryNotFound
+	CategoryPermission
+	CategoryUnsupported
+	CategoryTimeout
+	CategoryImplantCrash
+	CategorySessionGone
+	CategoryServer
+)
+
+// the categories as errors so callers can check for them with errors.Is
+var (
+	ErrNotFound         = errors.New("ENOENT")
+	ErrPermissionDenied = errors.New("EACCES")
+	ErrUnsupported      = errors.New("unsupported on target os")
+	ErrTimeout          = errors.New("timeout")
+	ErrImplantCrash     = errors.New("implant crash")
+	ErrSessionGone      = errors.New("session gone")
+	ErrServerSide       = errors.New("server error")
+)
+
+var categoryErrors = map[ErrorCategory]error{
+	CategoryNotFound:     ErrNotFound,
+	CategoryPermission:   ErrPermissionDenied,
+	CategoryUnsupported:  ErrUnsupported,
+	CategoryTimeout:      ErrTimeout,
+	CategoryImplantCrash: ErrImplantCrash,
+	CategorySessionGone:  ErrSessionGone,
+	CategoryServer:       ErrServerSide,
+}
+
+// the implant reports failures as plain text, these are the messages that give away the category
+var implantErrorMessages = []struct {
+	category ErrorCategory
+	messages []string
+}{
+	{CategoryNotFound, []string{"no such file or directory", "does not exist", "cannot find the"}},
+	{CategoryPermission, []string{"permission denied", "access is denied", "operation not permitted"}},
+	{CategoryUnsupported, []string{"not supported", "unsupported", "not implemented", "unknown message type"}},
+	{CategoryTimeout, []string{"implant timeout", "i/o timeout", "deadline exceeded"}},
+	{CategoryImplantCrash, []string{"panic", "segmentation", "signal: killed", "unexpected eof", "broken pipe", "connection reset"}},
+}
+
+func (c ErrorCategory) String() string {
+	if err, ok := categoryErrors[c]; ok {
+		return err.Error()
+	}
+	return "error"
+}
+
+// Function to determine if an rpc that failed this way is worth sending again, everything
+// else will fail the same way a second time
+func (c ErrorCategory) Retryable() bool {
+	return c == CategoryTimeout || c == CategoryServer
+}
+
+// Function to get the exit code for a run that failed this way, a missing file is an everyday
+// occurrence during a survey so it does not fail the run
+func (c ErrorCategory) ExitCode() int {
+	switch c {
+	case CategoryNotFound:
+		return 0
+	case CategoryUnsupported:
+		return 2
+	case CategoryUnknown:
+		return 3
+	case CategoryPermission:
+		return 4
+	case CategoryTimeout:
+		return 5
+	case CategoryImplantCrash:
+		return 6
+	case CategorySessionGone:
+		return 7
+	}
+	return 8
+}
+
+// rpcError is a failed rpc along with what it was doing and which category of failure it was
+type rpcError struct {
+	op       string
+	path     string
+	category ErrorCategory
+	err      error
+}
+
+func (e *rpcError) Error() string {
+	target := e.op
+	if e.path != "" {
+		target += " " + e.path
+	}
+	if e.category == CategoryUnknown {
+		return fmt.Sprintf("%s: %v", target, e.err)
+	}
+	return fmt.Sprintf("%s: %v (%v)", target, e.category, e.err)
+}
+
+func (e *rpcError) Is(target error) bool {
+	return categoryErrors[e.category] == target
+}
+
+func (e *rpcError) Unwrap() error {
+	return e.err
+}
+
+// Function to wrap a failed rpc in an rpcError, nil if the rpc worked. The server hands back an
+// implants error as the rpc error, older servers leave it in the Response instead so check both
+//
+// :param: op string -> what we were doing i.e. "ls", "download"
+// :param: path string -> the path on the target, if any
+// :param: err error -> the error returned by the rpc
+// :param: response *commonpb.Response -> the Response of the reply, may be nil
+// :return: error -> the wrapped error or nil
+func NewRPCError(op string, path string, err error, response *commonpb.Response) error {
+	if err == nil && response != nil && response.Err != "" {
+		err = errors.New(response.Err)
+	}
+	if err == nil {
+		return nil
+	}
+	return &rpcError{op: op, path: path, category: ClassifyError(err), err: err}
+}
+
+// Function to build the error for a file the implant says does not exist
+func NotFoundError(op string, path string) error {
+	return &rpcError{op: op, path: path, category: CategoryNotFound, err: errors.New("no such file or directory")}
+}
+
+// Function to work out which category of failure an error is. The gRPC status code tells us
+// whether the server itself failed, anything the implant reported arrives as codes.Unknown and
+// only its message tells us more
+//
+// :param: err error -> the error returned by the rpc, or an rpcError wrapping it
+// :return: errorCategory -> the category, categoryUnknown if nothing matched
+func ClassifyError(err error) ErrorCategory {
+	if err == nil {
+		return CategoryUnknown
+	}
+	var wrapped *rpcError
+	if errors.As(err, &wrapped) {
+		return wrapped.category
+	}
+
+	message := strings.ToLower(status.Convert(err).Message())
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		if strings.Contains(message, "invalid session id") {
+			return CategorySessionGone
+		}
+		return CategoryServer
+	case codes.DeadlineExceeded:
+		return CategoryTimeout
+	case codes.Unimplemented:
+		return CategoryUnsupported
+	case codes.Unknown:
+		for _, known := range implantErrorMessages {
+			for _, text := range known.messages {
+				if strings.Contains(message, text) {
+					return known.category
+				}
+			}
+		}
+		return CategoryUnknown
+	}
+	// anything else comes from the server or the connection to it, i.e. Unavailable or PermissionDenied
+	// when the operator is not allowed to make the call
+	return CategoryServer
+}
+
+// Function to get the exit code for a run from everything that failed in it, the most serious
+// failure decides
+//
+// :param: errs []error -> every failure of the run
+// :return: int -> 0 if nothing but missing files failed
+func ExitCodeFor(errs []error) int {
+	code := 0
+	for _, err := range errs {
+		// joined errors i.e. several downloads failing in one module
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			if nested := ExitCodeFor(joined.Unwrap()); nested > code {
+				code = nested
+			}
+			continue
+		}
+		if c := ClassifyError(err).ExitCode(); c > code {
+			code = c
+		}
+	}
+	return code
+}
@@ -0,0 +1,104 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bishopfox/sliver/protobuf/commonpb"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		response *commonpb.Response
+		want     ErrorCategory
+	}{
+		{"implant not found", status.Error(codes.Unknown, "stat /etc/nope: no such file or directory"), nil, CategoryNotFound},
+		{"implant permission denied", status.Error(codes.Unknown, "open /etc/shadow: permission denied"), nil, CategoryPermission},
+		{"windows access denied", status.Error(codes.Unknown, "open C:\\Windows\\System32\\config\\SAM: Access is denied."), nil, CategoryPermission},
+		{"implant timeout", status.Error(codes.Unknown, "implant timeout"), nil, CategoryTimeout},
+		{"deadline", status.Error(codes.DeadlineExceeded, "context deadline exceeded"), nil, CategoryTimeout},
+		{"session gone", status.Error(codes.InvalidArgument, "Invalid session ID"), nil, CategorySessionGone},
+		{"unsupported", status.Error(codes.Unknown, "not supported on this platform"), nil, CategoryUnsupported},
+		{"unimplemented", status.Error(codes.Unimplemented, "method Ls not implemented"), nil, CategoryUnsupported},
+		{"implant crash", status.Error(codes.Unknown, "panic: runtime error: invalid memory address"), nil, CategoryImplantCrash},
+		{"server unavailable", status.Error(codes.Unavailable, "connection refused"), nil, CategoryServer},
+		{"operator not permitted", status.Error(codes.PermissionDenied, "permission denied"), nil, CategoryServer},
+		{"error in response", nil, &commonpb.Response{Err: "open /root: permission denied"}, CategoryPermission},
+		{"anything else", status.Error(codes.Unknown, "something odd"), nil, CategoryUnknown},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := NewRPCError("ls", "/x", test.err, test.response)
+			if got := ClassifyError(err); got != test.want {
+				t.Errorf("classifyError(%v) = %v, want %v", err, got, test.want)
+			}
+			if want, ok := categoryErrors[test.want]; ok && !errors.Is(err, want) {
+				t.Errorf("expected %v to be %v", err, want)
+			}
+		})
+	}
+
+	if err := NewRPCError("ls", "/x", nil, &commonpb.Response{}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestExitCodeFor(t *testing.T) {
+	notFound := NotFoundError("download", "/etc/hosts.deny")
+	denied := NewRPCError("ls", "/root", status.Error(codes.Unknown, "permission denied"), nil)
+	gone := NewRPCError("ps", "", status.Error(codes.InvalidArgument, "Invalid session ID"), nil)
+
+	if code := ExitCodeFor(nil); code != 0 {
+		t.Errorf("expected 0 for a clean run, got %d", code)
+	}
+	if code := ExitCodeFor([]error{notFound}); code != 0 {
+		t.Errorf("expected missing files not to fail the run, got %d", code)
+	}
+	if code := ExitCodeFor([]error{errors.Join(notFound, denied)}); code != CategoryPermission.ExitCode() {
+		t.Errorf("expected the joined permission error to decide, got %d", code)
+	}
+	if code := ExitCodeFor([]error{denied, gone, notFound}); code != CategorySessionGone.ExitCode() {
+		t.Errorf("expected the session being gone to decide, got %d", code)
+	}
+}
+
+func TestRetrier(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		calls  int
+	}{
+		{"timeout is retried", "/rpcpb.SliverRPC/Ls", status.Error(codes.Unknown, "implant timeout"), 3},
+		{"server error is retried", "/rpcpb.SliverRPC/Ls", status.Error(codes.Unavailable, "transport is closing"), 3},
+		{"not found is not retried", "/rpcpb.SliverRPC/Ls", status.Error(codes.Unknown, "stat /x: no such file or directory"), 1},
+		{"execute is never retried", "/rpcpb.SliverRPC/Execute", status.Error(codes.Unknown, "implant timeout"), 1},
+		{"success", "/rpcpb.SliverRPC/Ls", nil, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				calls++
+				return test.err
+			}
+			r := NewRetrier(2, time.Millisecond)
+			err := r.Interceptor(context.Background(), test.method, &sliverpb.LsReq{}, &sliverpb.Ls{}, nil, invoker)
+			if calls != test.calls {
+				t.Errorf("expected %d calls, got %d", test.calls, calls)
+			}
+			if fmt.Sprint(err) != fmt.Sprint(test.err) {
+				t.Errorf("expected the last error to be returned, got %v", err)
+			}
+		})
+	}
+}
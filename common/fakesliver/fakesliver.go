@@ -0,0 +1,175 @@
+// Package fakesliver is the in process sliver server the client tests dial. It serves GetSessions
+// and records every call, a client embeds Server and adds the rpcs it uses on top
+package fakesliver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"common"
+	"github.com/bishopfox/sliver/protobuf/clientpb"
+	"github.com/bishopfox/sliver/protobuf/commonpb"
+	"github.com/bishopfox/sliver/protobuf/rpcpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Server stands in for the sliver server and the implant behind it. Everything it knows about
+// the target comes from a fixture directory:
+//
+//	sessions.json  clientpb.Sessions returned by GetSessions
+//	fs/            the targets filesystem, for the rpcs a client adds to read it
+type Server struct {
+	rpcpb.UnimplementedSliverRPCServer
+
+	Root     string
+	Sessions *clientpb.Sessions
+
+	mu    sync.Mutex
+	calls []call
+}
+
+// a call the fake received, kept so tests can assert on exactly what was sent to the target
+type call struct {
+	method string
+	target string
+}
+
+// Function to load a fake sliver server from a fixture directory
+//
+// :param: t *testing.T -> the test the fake is for
+// :param: fixtures string -> the fixture directory
+// :return: *Server -> the fake, serving the sessions in sessions.json
+func New(t *testing.T, fixtures string) *Server {
+	t.Helper()
+	server := &Server{
+		Root:     filepath.Join(fixtures, "fs"),
+		Sessions: &clientpb.Sessions{},
+	}
+	LoadFixture(t, filepath.Join(fixtures, "sessions.json"), server.Sessions)
+	return server
+}
+
+// Function to read a protojson fixture into a message, a missing fixture leaves it empty
+func LoadFixture(t *testing.T, path string, message proto.Message) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	if err := protojson.Unmarshal(data, message); err != nil {
+		t.Fatalf("parsing %s: %v", path, err)
+	}
+}
+
+// Function to start a fake on an in memory listener and connect an rpc client to it
+//
+// :param: t *testing.T -> the test, the server and connection are closed when it ends
+// :param: service rpcpb.SliverRPCServer -> the clients fake, the Server with its rpcs on top
+// :param: interceptors ...grpc.UnaryClientInterceptor -> the interceptors the client would use
+// :return: rpcpb.SliverRPCClient -> an rpc client wired through the given interceptors
+func Dial(t *testing.T, service rpcpb.SliverRPCServer, interceptors ...grpc.UnaryClientInterceptor) rpcpb.SliverRPCClient {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	rpcpb.RegisterSliverRPCServer(server, service)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dialing fake sliver: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return rpcpb.NewSliverRPCClient(common.NewInterceptedConn(conn, interceptors...))
+}
+
+// Function to note a call the fake received, every rpc records itself first
+func (s *Server) Record(method string, target string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, call{method: method, target: target})
+}
+
+// Function to get the targets of every call of a method the fake has received
+func (s *Server) Called(method string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var targets []string
+	for _, call := range s.calls {
+		if call.method == method {
+			targets = append(targets, call.target)
+		}
+	}
+	return targets
+}
+
+// Function to map a path on the target to the fixture filesystem
+func (s *Server) Local(path string) string {
+	return filepath.Join(s.Root, filepath.FromSlash(filepath.Clean("/"+path)))
+}
+
+// Function to reject requests for a session the server does not know, the same as a session that has gone away
+func (s *Server) CheckSession(request *commonpb.Request) error {
+	for _, session := range s.Sessions.Sessions {
+		if session.ID == request.GetSessionID() {
+			return nil
+		}
+	}
+	return status.Error(codes.InvalidArgument, "Invalid session ID")
+}
+
+// Function to build an error from the implant, it reaches the client the same way the real server sends it
+func ImplantError(format string, args ...interface{}) error {
+	return status.Error(codes.Unknown, fmt.Sprintf(format, args...))
+}
+
+func (s *Server) GetSessions(ctx context.Context, _ *commonpb.Empty) (*clientpb.Sessions, error) {
+	s.Record("GetSessions", "")
+	return s.Sessions, nil
+}
+
+// Function to capture everything written to stdout while fn runs
+func CaptureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+
+	output := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, reader)
+		output <- buf.String()
+	}()
+
+	defer func() {
+		os.Stdout = stdout
+	}()
+	fn()
+	writer.Close()
+	return <-output
+}
@@ -0,0 +1,229 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bishopfox/sliver/protobuf/commonpb"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+	"google.golang.org/grpc"
+)
+
+// a window of the day, in minutes since midnight, during which we leave the target alone
+type quietWindow struct {
+	start int
+	end   int
+}
+
+// Pacer spaces out the requests we send to the implant and holds them during quiet hours
+type Pacer struct {
+	delay    time.Duration
+	jitter   time.Duration
+	interval time.Duration
+	quiet    []quietWindow
+	location *time.Location
+	located  chan struct{}
+	last     time.Time
+	mu       sync.Mutex
+}
+
+// Function to create a pacer from the operators flags
+//
+// :param: delay time.Duration -> the minimum time between two requests
+// :param: jitter time.Duration -> up to this much random time is added to every delay
+// :param: rate int -> the maximum number of requests per minute, 0 for no limit
+// :param: quietHours string -> comma separated windows in implant time i.e. "22:00-06:00,12:00-13:00"
+// :return: *pacer -> the pacer
+// :return: error -> if the quiet hours can not be parsed
+func NewPacer(delay time.Duration, jitter time.Duration, rate int, quietHours string) (*Pacer, error) {
+	windows, err := parseQuietHours(quietHours)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pacer{delay: delay, jitter: jitter, quiet: windows, located: make(chan struct{})}
+	if rate > 0 {
+		p.interval = time.Minute / time.Duration(rate)
+	}
+	return p, nil
+}
+
+// Function to parse the -quiet-hours flag
+//
+// :param: value string -> i.e. "22:00-06:00,12:00-13:00"
+// :return: []quietWindow -> the parsed windows
+// :return: error -> if any window is malformed
+func parseQuietHours(value string) ([]quietWindow, error) {
+	var windows []quietWindow
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bounds := strings.Split(part, "-")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("quiet hours window %q must look like 22:00-06:00", part)
+		}
+		start, err := parseClock(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, quietWindow{start: start, end: end})
+	}
+	return windows, nil
+}
+
+// Function to convert "HH:MM" into minutes since midnight
+func parseClock(value string) (int, error) {
+	parts := strings.Split(strings.TrimSpace(value), ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, fmt.Errorf("invalid hour in %q", value)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, fmt.Errorf("invalid minute in %q", value)
+	}
+	return hour*60 + minute, nil
+}
+
+// Function to determine if a minute of the day falls inside the window, windows may wrap midnight
+func (w quietWindow) contains(minute int) bool {
+	if w.start <= w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+// Function to find when the quiet hours we are currently in end, the caller holds p.mu and
+// the implants timezone must be known
+//
+// :param: now time.Time -> the current time
+// :return: time.Time -> when polling may resume
+// :return: bool -> false if we are not in quiet hours
+func (p *Pacer) quietUntil(now time.Time) (time.Time, bool) {
+	location := p.location
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	for _, window := range p.quiet {
+		if !window.contains(minute) {
+			continue
+		}
+		midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+		end := midnight.Add(time.Duration(window.end) * time.Minute)
+		if !end.After(local) {
+			end = end.Add(24 * time.Hour)
+		}
+		return end, true
+	}
+	return time.Time{}, false
+}
+
+// Function to block until the next request is allowed to go out
+//
+// :param: listing bool -> true for a directory listing, which is let out before the implants
+// timezone is known as it is what tells us the timezone
+// :return: None
+func (p *Pacer) wait(listing bool) {
+	// quiet hours are in implant time, rather than guess it everything else is held until a
+	// listing the client makes has come back with the implants timezone
+	if len(p.quiet) > 0 && !listing {
+		<-p.located
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for p.location != nil {
+		until, quiet := p.quietUntil(time.Now())
+		if !quiet {
+			break
+		}
+		fmt.Printf("[*] Quiet hours, pausing until %s implant time\n", until.Format("2006-01-02 15:04 MST"))
+		time.Sleep(time.Until(until))
+	}
+
+	gap := WithJitter(p.delay, p.jitter)
+	if gap < p.interval {
+		gap = p.interval
+	}
+	if !p.last.IsZero() {
+		if since := time.Since(p.last); since < gap {
+			time.Sleep(gap - since)
+		}
+	}
+	p.last = time.Now()
+}
+
+// Function to learn the implants timezone from a directory listing
+//
+// :param: ls *sliverpb.Ls -> any directory listing from the implant
+// :return: None
+func (p *Pacer) learnLocation(ls *sliverpb.Ls) {
+	if ls == nil || ls.Timezone == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.location == nil {
+		p.location = time.FixedZone(ls.Timezone, int(ls.TimezoneOffset))
+		close(p.located)
+	}
+}
+
+// Function to determine if requests are being held until a listing tells us the implants
+// timezone, a client that would not list anything otherwise has to list something first
+func (p *Pacer) Holding() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.quiet) > 0 && p.location == nil
+}
+
+// Function to get the implants timezone, UTC until a listing has told us
+func (p *Pacer) ImplantLocation() *time.Location {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.location == nil {
+		return time.UTC
+	}
+	return p.location
+}
+
+// Function to pace every request bound for an implant, calls that only talk to the
+// server (i.e. GetSessions) are let straight through
+func (p *Pacer) Interceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	withRequest, ok := req.(interface{ GetRequest() *commonpb.Request })
+	if !ok || withRequest.GetRequest() == nil || withRequest.GetRequest().SessionID == "" {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+
+	_, listing := req.(*sliverpb.LsReq)
+	p.wait(listing)
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if ls, ok := reply.(*sliverpb.Ls); ok && err == nil {
+		p.learnLocation(ls)
+	}
+	return err
+}
+
+// Function to add up to jitter of random time to a sleep
+//
+// :param: sleep time.Duration -> the base sleep
+// :param: jitter time.Duration -> the most random time to add
+// :return: time.Duration -> the sleep with jitter applied
+func WithJitter(sleep time.Duration, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return sleep
+	}
+	return sleep + time.Duration(rand.Int63n(int64(jitter)))
+}
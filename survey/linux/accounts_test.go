@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordState(t *testing.T) {
+	tests := []struct {
+		field     string
+		wantState string
+		wantHash  string
+	}{
+		{field: "", wantState: passwordEmpty},
+		{field: "*", wantState: passwordLocked},
+		{field: "!", wantState: passwordLocked},
+		{field: "!!", wantState: passwordLocked},
+		{field: "$6$salt$hash", wantState: passwordSet, wantHash: "sha512"},
+		{field: "!$6$salt$hash", wantState: passwordLocked, wantHash: "sha512"},
+		{field: "$y$j9T$salt$hash", wantState: passwordSet, wantHash: "yescrypt"},
+		{field: "$2b$10$hash", wantState: passwordSet, wantHash: "bcrypt"},
+		{field: "$1$salt$hash", wantState: passwordSet, wantHash: "md5"},
+		{field: "abJnggxhB/yWI", wantState: passwordSet, wantHash: "des"},
+		{field: "$9$what", wantState: passwordSet, wantHash: "unknown"},
+	}
+	for _, test := range tests {
+		state, hash := passwordState(test.field)
+		if state != test.wantState || hash != test.wantHash {
+			t.Errorf("passwordState(%q) = %s, %s want %s, %s", test.field, state, hash, test.wantState, test.wantHash)
+		}
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	accounts := parsePasswd(strings.Join([]string{
+		"root:x:0:0:root:/root:/bin/bash",
+		"toor:x:0:0::/root:/bin/sh",
+		"backup:x:34:34:backup:/var/backups:/bin/sh",
+		"sync:x:4:65534:sync:/bin:/bin/sync",
+		"guest:x:1002:1002::/home/guest:/bin/bash",
+		"legacy::1003:1003::/home/legacy:/bin/bash",
+		"# a comment",
+		"broken:x:notanumber",
+	}, "\n"))
+	applyGroups(accounts, parseGroup("root:x:0:\nbackup:x:34:\nsudo:x:27:guest,toor\nguest:x:1002:\n"))
+	applyShadow(accounts, strings.Join([]string{
+		"root:$6$salt$hash:19800:0:99999:7:::",
+		"toor:$6$salt$hash:19800:0:99999:7:::",
+		"backup:*:19700:0:99999:7:::",
+		"guest::0:0:90:7::19900:",
+	}, "\n"))
+
+	byName := map[string]*account{}
+	for _, a := range accounts {
+		byName[a.Name] = a
+	}
+	if len(accounts) != 6 {
+		t.Fatalf("expected 6 accounts, got %d", len(accounts))
+	}
+	if groups := strings.Join(byName["guest"].Groups, ","); groups != "guest,sudo" {
+		t.Errorf("expected guest to be in guest,sudo, got %s", groups)
+	}
+	guest := byName["guest"]
+	if !guest.MustChange || !guest.PasswordExpires.IsZero() || formatShadowDate(guest.AccountExpires) != "2024-06-26" {
+		t.Errorf("unexpected ageing for guest %+v", guest)
+	}
+	if root := byName["root"]; root.LastChange.Format("2006-01-02") != "2024-03-18" || !root.PasswordExpires.IsZero() {
+		t.Errorf("unexpected ageing for root %+v", root)
+	}
+	// not in shadow, the empty passwd field is the password
+	if byName["legacy"].Password != passwordEmpty {
+		t.Errorf("expected legacy to have an empty password, got %s", byName["legacy"].Password)
+	}
+
+	want := []string{
+		"toor has uid 0",
+		"backup is a service account with the interactive shell /bin/sh",
+		"guest has an empty password",
+		"legacy has an empty password",
+	}
+	if got := accountFindings(accounts); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected findings\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bishopfox/sliver/client/console"
+	"github.com/bishopfox/sliver/protobuf/clientpb"
+	"github.com/bishopfox/sliver/protobuf/rpcpb"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// the tools the survey steps know how to use, in no particular order
+var defaultBinTools = []string{"uptime", "cat", "uname", "grep", "route", "ip", "netstat", "arp", "head"}
+
+// the $PATH style directories we search on the target, first match wins
+var defaultBinDirs = []string{"/bin", "/usr/bin", "/sbin", "/usr/sbin", "/usr/local/bin"}
+
+// binInventory records where each tool we care about lives on the target host
+type binInventory struct {
+	Host    string            `json:"host"`
+	Created int64             `json:"created"`
+	Dirs    []string          `json:"dirs"`
+	Tools   map[string]string `json:"tools"`
+	Busybox string            `json:"busybox"`
+}
+
+// a single way of running a survey step i.e. "uname -r"
+type execCandidate struct {
+	tool string
+	args []string
+}
+
+// a survey step that can be satisfied by the first available candidate, or in -no-exec mode
+// by reading file and parsing it client side
+type execStep struct {
+	title      string
+	candidates []execCandidate
+	file       string
+	parse      func([]byte) string
+}
+
+// Function to resolve a tool name to something we can execute on the target
+// falls back to a busybox applet when the tool itself does not exist
+//
+// :param: tool string -> the tool name i.e. "uptime"
+// :return: string -> the binary to execute
+// :return: []string -> the args that must come before the callers args
+// :return: bool -> false if there is no way to run the tool
+func (inv *binInventory) lookup(tool string) (string, []string, bool) {
+	if inv == nil {
+		return "", nil, false
+	}
+	if path := inv.Tools[tool]; path != "" {
+		return path, nil, true
+	}
+	if inv.Busybox != "" {
+		return inv.Busybox, []string{tool}, true
+	}
+	return "", nil, false
+}
+
+// Function to build the binary inventory of the target, one directory listing per search directory
+//
+// :param: targetSession *clientpb.Session -> the target session we are interacting with
+// :param: rpc rpcpb.SliverRPCClient -> the rpc object allowing us to make command request
+// :param: tools []string -> the tool names we want to find
+// :param: dirs []string -> the directories to search in order
+// :return: *binInventory -> the resolved tools
+func buildBinInventory(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, tools []string, dirs []string) *binInventory {
+	inv := &binInventory{
+		Host:    targetSession.Hostname,
+		Created: time.Now().Unix(),
+		Dirs:    dirs,
+		Tools:   map[string]string{},
+	}
+	wanted := map[string]bool{}
+	for _, tool := range tools {
+		wanted[tool] = true
+	}
+
+	for _, dir := range dirs {
+		ls, err := rpc.Ls(context.Background(), &sliverpb.LsReq{
+			Path:    dir,
+			Request: makeRequest(targetSession),
+		})
+		// a missing search directory is normal, just move on to the next one
+		if err != nil || ls == nil || !ls.Exists || (ls.Response != nil && ls.Response.Err != "") {
+			continue
+		}
+		for _, fi := range ls.Files {
+			if fi.IsDir {
+				continue
+			}
+			fullPath := dir + "/" + fi.Name
+			if fi.Name == "busybox" && inv.Busybox == "" {
+				inv.Busybox = fullPath
+			}
+			if wanted[fi.Name] && inv.Tools[fi.Name] == "" {
+				inv.Tools[fi.Name] = fullPath
+			}
+		}
+	}
+	// record the misses too so the cache knows they were searched for
+	for _, tool := range tools {
+		if _, ok := inv.Tools[tool]; !ok {
+			inv.Tools[tool] = ""
+		}
+	}
+	return inv
+}
+
+// Function to get the binary inventory for a host, using the on disk cache when we already have one
+//
+// :param: targetSession *clientpb.Session -> the target session we are interacting with
+// :param: rpc rpcpb.SliverRPCClient -> the rpc object allowing us to make command request
+// :param: cacheDir string -> the local directory holding one cache file per host
+// :param: tools []string -> the tool names we want to find
+// :param: dirs []string -> the directories to search in order
+// :param: refresh bool -> ignore any cached inventory and rebuild it
+// :return: *binInventory -> the resolved tools
+func loadBinInventory(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, cacheDir string, tools []string, dirs []string, refresh bool) *binInventory {
+	cachePath := binCachePath(cacheDir, targetSession.Hostname)
+
+	if !refresh {
+		if inv := readBinInventoryCache(cacheDir, targetSession.Hostname, tools, dirs); inv != nil {
+			fmt.Println("[*] Using cached binary inventory:", cachePath)
+			return inv
+		}
+	}
+
+	inv := buildBinInventory(targetSession, rpc, tools, dirs)
+
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err == nil {
+		os.MkdirAll(cacheDir, 0777)
+		if err := os.WriteFile(cachePath, data, 0644); err != nil {
+			fmt.Println("[!] Error writing binary inventory cache:", err)
+		}
+	}
+	return inv
+}
+
+func binCachePath(cacheDir string, host string) string {
+	return filepath.Join(cacheDir, host+".json")
+}
+
+// Function to read the cached binary inventory of a host without touching the target
+//
+// :param: cacheDir string -> the local directory holding one cache file per host
+// :param: host string -> the hostname of the target
+// :param: tools []string -> the tool names we want to find
+// :param: dirs []string -> the directories to search in order
+// :return: *binInventory -> the cached inventory, nil if there is no usable cache
+func readBinInventoryCache(cacheDir string, host string, tools []string, dirs []string) *binInventory {
+	data, err := os.ReadFile(binCachePath(cacheDir, host))
+	if err != nil {
+		return nil
+	}
+	inv := &binInventory{}
+	if err := json.Unmarshal(data, inv); err != nil || !sameStrings(inv.Dirs, dirs) || !hasAllTools(inv, tools) {
+		return nil
+	}
+	return inv
+}
+
+// a cached inventory is only reusable if it was built looking for every tool we want now,
+// tools that were searched for and not found are stored with an empty path
+func hasAllTools(inv *binInventory, tools []string) bool {
+	for _, tool := range tools {
+		if _, ok := inv.Tools[tool]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for index := range a {
+		if a[index] != b[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// Function to print what exists on the target and where
+//
+// :param: inv *binInventory -> the inventory to print
+// :param: tools []string -> the tool names that were searched for
+// :return: None
+func printBinInventory(inv *binInventory, tools []string) {
+	makeBorder("Binary Inventory")
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Tool", "Path"})
+
+	sorted := append([]string{}, tools...)
+	sort.Strings(sorted)
+	for _, tool := range sorted {
+		path, prefix, ok := inv.lookup(tool)
+		switch {
+		case !ok:
+			tw.AppendRow(table.Row{tool, fmt.Sprintf(console.Red+"%s"+console.Normal, "not found")})
+		case len(prefix) > 0:
+			tw.AppendRow(table.Row{tool, fmt.Sprintf("%s %s (applet)", path, strings.Join(prefix, " "))})
+		default:
+			tw.AppendRow(table.Row{tool, path})
+		}
+	}
+	if inv.Busybox != "" {
+		tw.AppendRow(table.Row{"busybox", inv.Busybox})
+	}
+	fmt.Printf("%s\n", tw.Render())
+}
+
+// Function to pick the first candidate of a step the target can satisfy, native binaries are
+// preferred over busybox applets so "ip" wins over "busybox route"
+//
+// :param: step execStep -> the step to resolve
+// :return: string -> the binary to execute
+// :return: []string -> the full argument list
+// :return: bool -> false if nothing can satisfy the step
+func (inv *binInventory) resolve(step execStep) (string, []string, bool) {
+	if inv == nil {
+		return "", nil, false
+	}
+	for _, candidate := range step.candidates {
+		if path := inv.Tools[candidate.tool]; path != "" {
+			return path, candidate.args, true
+		}
+	}
+	for _, candidate := range step.candidates {
+		if path, prefix, ok := inv.lookup(candidate.tool); ok {
+			return path, append(append([]string{}, prefix...), candidate.args...), true
+		}
+	}
+	return "", nil, false
+}
+
+// Function to run a survey step with the first candidate the target can satisfy, if nothing
+// can satisfy the step it is skipped rather than executing a binary that is not there
+//
+// :param: targetSession *clientpb.Session -> the target session we are interacting with
+// :param: rpc rpcpb.SliverRPCClient -> the rpc object allowing us to make command request
+// :param: inv *binInventory -> the binary inventory of the target
+// :param: step execStep -> the step to run
+// :return: error -> if the candidate could not be executed, skipping the step is not an error
+func runExecStep(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, inv *binInventory, step execStep) error {
+	if step.title != "" {
+		fmt.Println(console.Bold + step.title + console.Normal)
+	}
+	path, args, ok := inv.resolve(step)
+	if !ok {
+		var tried []string
+		for _, candidate := range step.candidates {
+			tried = append(tried, candidate.tool)
+		}
+		fmt.Printf("[-] Skipping, none of %s found on target\n", strings.Join(tried, ", "))
+		return nil
+	}
+	return executeBinary(targetSession, rpc, path, args, true)
+}
+
+// Function to split a comma separated flag value into its parts
+//
+// :param: value string -> i.e. "/bin,/usr/bin"
+// :return: []string -> i.e. ["/bin", "/usr/bin"]
+func splitList(value string) []string {
+	var parts []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
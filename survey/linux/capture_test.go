@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"common"
+	"common/fakesliver"
+	"github.com/bishopfox/sliver/protobuf/commonpb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// the age of the last checkin, which changes between two runs of the survey
+var checkinAge = regexp.MustCompile(`\(\S+ ago\)`)
+
+func TestCaptureThenReplay(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "web01.capture.jsonl")
+
+	// capture a survey against the fake
+	fake := newFakeSliver(t, "testdata/host")
+	recorder, err := common.NewCaptureRecorder(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	// the live run sees every reply redacted after it was recorded, as the replay will
+	live.rpc = fakesliver.Dial(t, fake, redactReplies, recorder.Interceptor)
+	if _, err := live.rpc.GetSessions(context.Background(), &commonpb.Empty{}); err != nil {
+		t.Fatal(err)
+	}
+	liveOutput := fakesliver.CaptureOutput(t, func() { live.run(noiseExec) })
+	recorder.Close()
+
+	data, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"S3cretPass!", "abc123token", "$6$Qk3xPz1r$"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("expected %q to be redacted from the capture", secret)
+		}
+	}
+
+	// replay it with nothing listening
+	sessions, rpc, err := common.MakeReplayConnection(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := newTestSurvey(t, fake, sessions.Sessions[0])
+	replayed.rpc = rpc
+	replayOutput := fakesliver.CaptureOutput(t, func() { replayed.run(noiseExec) })
+
+	for _, want := range []string{"6.8.0-45-generic", "/usr/sbin/sshd -D", "10.10.20.31:43122", "eth0"} {
+		if !strings.Contains(replayOutput, want) {
+			t.Errorf("expected replayed output to contain %q", want)
+		}
+	}
+	for _, section := range []string{"Shell History", "Credential Files", "Credential Files /root", "Secret Scan"} {
+		if !strings.Contains(replayOutput, "[*] "+section+"\n") {
+			t.Errorf("expected the replay to include the %s section", section)
+		}
+	}
+	// the two runs are apart by however long the survey took, the last checkin is that much further back
+	liveOutput = checkinAge.ReplaceAllString(strings.ReplaceAll(liveOutput, live.fileTag, replayed.fileTag), "ago")
+	replayOutput = checkinAge.ReplaceAllString(replayOutput, "ago")
+	if replayOutput != liveOutput {
+		t.Errorf("expected the replay to print what the redacted live run did\nlive:\n%s\nreplay:\n%s", liveOutput, replayOutput)
+	}
+	history, err := os.ReadFile(filepath.Join(replayed.fileTag, "home/ubuntu/.bash_history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(history), "-p<redacted>") {
+		t.Errorf("expected replayed loot to be redacted, got %q", history)
+	}
+}
+
+// Function to redact every reply the way the capture archive does
+func redactReplies(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if message, ok := reply.(proto.Message); ok && err == nil {
+		common.RedactMessage(message)
+	}
+	return err
+}
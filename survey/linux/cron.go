@@ -0,0 +1,752 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"common"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// the directories run-parts runs every script of, with the schedule Debian's /etc/crontab gives
+// them when the crontab does not say
+var runPartsDirs = []struct {
+	dir      string
+	schedule string
+}{
+	{"/etc/cron.hourly", "17 * * * *"},
+	{"/etc/cron.daily", "25 6 * * *"},
+	{"/etc/cron.weekly", "47 6 * * 7"},
+	{"/etc/cron.monthly", "52 6 1 * *"},
+}
+
+// the per user crontabs, Debian keeps them in crontabs while Red Hat keeps them in the directory itself
+var cronSpoolDirs = []string{"/var/spool/cron/crontabs", "/var/spool/cron"}
+
+// the PATH cron gives a job when the crontab does not set one
+var cronDefaultPath = []string{"/usr/bin", "/bin"}
+
+// the @ schedules of cron as the five fields they stand for
+var cronShorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// the shorthands of a systemd OnCalendar as the full calendar event
+var calendarShorthands = map[string]string{
+	"minutely":     "*-*-* *:*:00",
+	"hourly":       "*-*-* *:00:00",
+	"daily":        "*-*-* 00:00:00",
+	"weekly":       "Mon *-*-* 00:00:00",
+	"monthly":      "*-*-01 00:00:00",
+	"quarterly":    "*-01,04,07,10-01 00:00:00",
+	"semiannually": "*-01,07-01 00:00:00",
+	"yearly":       "*-01-01 00:00:00",
+	"annually":     "*-01-01 00:00:00",
+}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+var isoWeekdayNames = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+// the commands of a job that do not run anything worth following
+var shellBuiltins = map[string]bool{
+	"cd": true, "test": true, "[": true, "[[": true, "]": true, "exit": true, "true": true,
+	"false": true, "echo": true, "export": true, "command": true, "if": true, "then": true,
+	"else": true, "fi": true, "exec": true, ":": true,
+}
+
+// programs that run whatever file they are given, that file is as much a target as the program
+var runsItsArgument = map[string]bool{
+	"sh": true, "bash": true, "dash": true, "zsh": true, "ksh": true, "python": true, "perl": true,
+	"php": true, "ruby": true, "node": true, "run-parts": true, "nice": true, "ionice": true,
+	"nohup": true, "flock": true, "timeout": true, "env": true, "sudo": true, "su": true,
+}
+
+var cronEnvLine = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*\s*=`)
+var shellSeparator = regexp.MustCompile(`&&|\|\||[;|()]`)
+
+// names run-parts runs, anything else in its directories (i.e. README, .placeholder) is skipped
+var runPartsName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// schedule is when a cron job or OnCalendar timer runs, down to the minute
+type schedule struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+	// nil for every year
+	years map[int]bool
+	// cron runs a job when either the day of the month or the day of the week matches when
+	// both are restricted, systemd wants both
+	eitherDay bool
+}
+
+// scheduledJob is one cron entry, script run by run-parts or systemd timer
+type scheduledJob struct {
+	source   string
+	user     string
+	schedule string
+	// nil for jobs that do not run on the clock i.e. @reboot or OnBootSec
+	when     *schedule
+	command  string
+	pathDirs []string
+}
+
+// Function to parse one field of a cron schedule into the values it matches
+//
+// :param: field string -> i.e. "*/15", "1-5", "mon,wed" or "3"
+// :param: min int -> the lowest value the field takes
+// :param: max int -> the highest value the field takes
+// :param: names []string -> the names of the values starting at min, nil if it has none
+// :return: map[int]bool -> the values the field matches
+// :return: error -> if the field can not be parsed
+func parseScheduleField(field string, min int, max int, names []string) (map[int]bool, error) {
+	values := map[int]bool{}
+	value := func(s string) (int, error) {
+		for i, name := range names {
+			if strings.EqualFold(s, name) || (len(s) > 3 && strings.EqualFold(s[:3], name)) {
+				return min + i, nil
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("%q is not between %d and %d", s, min, max)
+		}
+		return n, nil
+	}
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return nil, fmt.Errorf("bad step in %q", part)
+			}
+		}
+		start, end := min, max
+		if rangePart != "*" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = value(first); err != nil {
+				return nil, err
+			}
+			end = start
+			if isRange {
+				if end, err = value(last); err != nil {
+					return nil, err
+				}
+			} else if stepped {
+				// "5/15" starts at 5 and keeps going
+				end = max
+			}
+		}
+		if end < start {
+			return nil, fmt.Errorf("range %q runs backwards", rangePart)
+		}
+		for n := start; n <= end; n += step {
+			values[n] = true
+		}
+	}
+	return values, nil
+}
+
+// Function to parse the five fields of a cron schedule, or one of its @ shorthands
+//
+// :param: spec string -> i.e. "*/5 * * * *" or "@daily"
+// :return: *schedule -> the schedule, nil for @reboot
+// :return: error -> if the schedule can not be parsed
+func parseCronSchedule(spec string) (*schedule, error) {
+	if spec == "@reboot" {
+		return nil, nil
+	}
+	if full, ok := cronShorthands[spec]; ok {
+		spec = full
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in %q", spec)
+	}
+	var err error
+	s := &schedule{eitherDay: !strings.HasPrefix(fields[2], "*") && !strings.HasPrefix(fields[4], "*")}
+	if s.minutes, err = parseScheduleField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if s.hours, err = parseScheduleField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if s.days, err = parseScheduleField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if s.months, err = parseScheduleField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, err
+	}
+	// both 0 and 7 are sunday
+	if s.weekdays, err = parseScheduleField(fields[4], 0, 7, weekdayNames); err != nil {
+		return nil, err
+	}
+	if s.weekdays[7] {
+		s.weekdays[0] = true
+	}
+	return s, nil
+}
+
+// Function to parse a systemd OnCalendar event i.e. "Mon..Fri *-*-* 06:00:00" or "daily".
+// Seconds are dropped, the schedule is only as fine as a minute
+//
+// :param: spec string -> the OnCalendar value
+// :return: *schedule -> the schedule
+// :return: error -> if the event can not be parsed
+func parseCalendar(spec string) (*schedule, error) {
+	if full, ok := calendarShorthands[strings.ToLower(spec)]; ok {
+		spec = full
+	}
+	weekdays, date, clock := "*", "*-*-*", "00:00:00"
+	for i, token := range strings.Fields(spec) {
+		switch {
+		case strings.Contains(token, ":"):
+			clock = token
+		case strings.ContainsAny(token, "-*") && !strings.ContainsAny(token, "abcdefghijklmnopqrstuvwxyz"):
+			date = token
+		case i == 0:
+			weekdays = token
+		}
+		// anything else is a timezone, the schedule is in implant time either way
+	}
+	if strings.Contains(date, "~") {
+		return nil, fmt.Errorf("counting days from the end of the month is not supported in %q", spec)
+	}
+	dateParts := strings.Split(date, "-")
+	if len(dateParts) == 2 {
+		dateParts = append([]string{"*"}, dateParts...)
+	}
+	clockParts := strings.Split(clock, ":")
+	if len(dateParts) != 3 || len(clockParts) < 2 {
+		return nil, fmt.Errorf("can not parse calendar event %q", spec)
+	}
+
+	// systemd writes ranges with .. and cron with -, dates use - to split their fields
+	cronRange := func(field string) string { return strings.ReplaceAll(field, "..", "-") }
+	var err error
+	s := &schedule{}
+	if s.minutes, err = parseScheduleField(cronRange(clockParts[1]), 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if s.hours, err = parseScheduleField(cronRange(clockParts[0]), 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if s.days, err = parseScheduleField(cronRange(dateParts[2]), 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if s.months, err = parseScheduleField(cronRange(dateParts[1]), 1, 12, nil); err != nil {
+		return nil, err
+	}
+	// systemd weeks start on monday so "Sat..Sun" is a range, sunday is moved back to 0 after
+	if s.weekdays, err = parseScheduleField(cronRange(weekdays), 1, 7, isoWeekdayNames); err != nil {
+		return nil, err
+	}
+	if s.weekdays[7] {
+		s.weekdays[0] = true
+	}
+	if dateParts[0] != "*" {
+		if s.years, err = parseScheduleField(cronRange(dateParts[0]), 1970, 2199, nil); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Function to determine if a schedule runs on the day of a time
+func (s *schedule) dayMatches(t time.Time) bool {
+	if s.years != nil && !s.years[t.Year()] {
+		return false
+	}
+	if !s.months[int(t.Month())] {
+		return false
+	}
+	if s.eitherDay {
+		return s.days[t.Day()] || s.weekdays[int(t.Weekday())]
+	}
+	return s.days[t.Day()] && s.weekdays[int(t.Weekday())]
+}
+
+// Function to find the next time a schedule runs after a time, within the next five years
+//
+// :param: after time.Time -> the time to start from, in the timezone the schedule is kept in
+// :return: time.Time -> the next run, zero if there is none i.e. a date that has passed
+func (s *schedule) next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+	for t.Before(end) {
+		switch {
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !s.hours[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !s.minutes[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Function to describe how long until a job runs i.e. "3h 12m"
+func untilNext(d time.Duration) string {
+	if d < time.Minute {
+		return "under a minute"
+	}
+	d = d.Round(time.Minute)
+	days, hours, minutes := int(d.Hours())/24, int(d.Hours())%24, int(d.Minutes())%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+// Function to describe when a job runs next
+//
+// :param: now time.Time -> the time on the implant
+// :return: string -> i.e. "2024-05-01 06:25 (in 3h 12m)", or the schedule for jobs not on the clock
+func (j *scheduledJob) nextRun(now time.Time) string {
+	if j.when == nil {
+		if j.schedule == "@reboot" {
+			return "at boot"
+		}
+		return j.schedule
+	}
+	next := j.when.next(now)
+	if next.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("%s (in %s)", next.Format("2006-01-02 15:04"), untilNext(next.Sub(now)))
+}
+
+// Function to parse a crontab into its jobs, the system crontab and /etc/cron.d name the user of
+// every job while a users own crontab does not
+//
+// :param: file string -> where the crontab is on the target, used to name the source of each job
+// :param: data string -> the content of the crontab
+// :param: user string -> the owner of a users crontab, empty for a system crontab
+// :return: []*scheduledJob -> the jobs
+// :return: []string -> the lines that could not be parsed
+func parseCrontab(file string, data string, user string) ([]*scheduledJob, []string) {
+	var jobs []*scheduledJob
+	var bad []string
+	pathDirs := cronDefaultPath
+	for number, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		source := fmt.Sprintf("%s:%d", file, number+1)
+		if cronEnvLine.MatchString(line) {
+			name, value, _ := strings.Cut(line, "=")
+			if strings.TrimSpace(name) == "PATH" {
+				pathDirs = splitPath(strings.Trim(strings.TrimSpace(value), `"'`))
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		scheduleFields := 5
+		if strings.HasPrefix(fields[0], "@") {
+			scheduleFields = 1
+		}
+		need := scheduleFields + 1
+		if user == "" {
+			need++
+		}
+		if len(fields) < need {
+			bad = append(bad, source)
+			continue
+		}
+		spec := strings.Join(fields[:scheduleFields], " ")
+		when, err := parseCronSchedule(spec)
+		if err != nil {
+			bad = append(bad, source)
+			continue
+		}
+		job := &scheduledJob{source: source, user: user, schedule: spec, when: when, pathDirs: pathDirs}
+		rest := fields[scheduleFields:]
+		if user == "" {
+			job.user, rest = rest[0], rest[1:]
+		}
+		// an unescaped % ends the command, what follows it is fed to the command on stdin
+		command := strings.Join(rest, " ")
+		if i := strings.Index(strings.ReplaceAll(command, `\%`, "__"), "%"); i >= 0 {
+			command = command[:i]
+		}
+		job.command = strings.TrimSpace(command)
+		jobs = append(jobs, job)
+	}
+	return jobs, bad
+}
+
+// Function to split a PATH into its directories
+func splitPath(value string) []string {
+	var dirs []string
+	for _, dir := range strings.Split(value, ":") {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// Function to find the files a job's command runs: every program it starts and the script
+// handed to an interpreter or run-parts. Programs that are not a full path are resolved through
+// the PATH of the job with the directory listings
+//
+// :param: job *scheduledJob -> the job
+// :return: []string -> the paths the job runs, in the order they appear
+func (s *survey) jobTargets(job *scheduledJob) []string {
+	var targets []string
+	seen := map[string]bool{}
+	add := func(target string) {
+		if target != "" && !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	// a cd earlier in the command is where a relative program is run from
+	cwd := ""
+	for _, segment := range shellSeparator.Split(job.command, -1) {
+		var words []string
+		for _, word := range strings.Fields(segment) {
+			// redirections are where output goes, not what runs
+			if strings.ContainsAny(word[:1], "<>&") || strings.Contains(word, ">") {
+				continue
+			}
+			words = append(words, strings.Trim(word, `"'`))
+		}
+		// skip the VAR=value in front of a command
+		for len(words) > 0 && cronEnvLine.MatchString(words[0]) {
+			words = words[1:]
+		}
+		if len(words) > 1 && words[0] == "cd" && strings.HasPrefix(words[1], "/") {
+			cwd = words[1]
+		}
+		if len(words) == 0 || shellBuiltins[words[0]] {
+			continue
+		}
+		program := s.resolveProgram(words[0], job.pathDirs)
+		if strings.Contains(words[0], "/") && !strings.HasPrefix(words[0], "/") && cwd != "" {
+			program = path.Join(cwd, words[0])
+		}
+		add(program)
+		if runsItsArgument[versionless(path.Base(words[0]))] {
+			for _, arg := range words[1:] {
+				if strings.HasPrefix(arg, "/") {
+					add(arg)
+					break
+				}
+			}
+		}
+	}
+	return targets
+}
+
+// Function to find a program in a list of directories from their listings
+//
+// :param: name string -> the program, returned as is when it is already a full path
+// :param: dirs []string -> the directories to look in, in order
+// :return: string -> the full path of the program, empty if it was not found
+func (s *survey) resolveProgram(name string, dirs []string) string {
+	if strings.HasPrefix(name, "/") {
+		return name
+	}
+	if strings.Contains(name, "/") {
+		return ""
+	}
+	for _, dir := range dirs {
+		if isPrivilegedPath(dir) {
+			continue
+		}
+		if fi := s.lookupListed(path.Join(dir, name)); fi != nil && !fi.IsDir {
+			return path.Join(dir, name)
+		}
+	}
+	return ""
+}
+
+// Function to determine if the session user can change what a job runs, either the file itself
+// or the directory it is in. Without the owner in a listing this is the user's own home
+// directory and anything world writable
+//
+// :param: target string -> the file the job runs
+// :param: home string -> the home directory of the session user, empty if it is not known
+// :return: string -> why the user can write to it, empty if they can not
+func (s *survey) writableReason(target string, home string) string {
+	if home != "" && home != "/" && (target == home || strings.HasPrefix(target, home+"/")) {
+		return fmt.Sprintf("is in the home directory of %s", s.session.Username)
+	}
+	dir := path.Dir(target)
+	if isPrivilegedPath(dir) {
+		return ""
+	}
+	if fi := s.lookupListed(target); fi != nil && othersMay(fi.Mode, 'w') {
+		return fmt.Sprintf("is world writable (%s)", fi.Mode)
+	}
+	if fi := s.lookupListed(dir); fi != nil && othersMay(fi.Mode, 'w') && !strings.Contains(modeFlags(fi.Mode), "t") {
+		return fmt.Sprintf("is in the world writable directory %s (%s)", dir, fi.Mode)
+	}
+	return ""
+}
+
+// Function to collect the crontabs of a directory, sudo and cron both skip names with a dot
+//
+// :param: dir string -> the directory on the target
+// :param: user bool -> true for a spool directory where the file name is the user
+// :return: []*scheduledJob -> the jobs of every crontab in it
+// :return: error -> if the session went away
+func (s *survey) cronDir(dir string, user bool) ([]*scheduledJob, error) {
+	files, err := s.listDir(dir)
+	if err != nil {
+		if !errors.Is(err, common.ErrNotFound) {
+			printRPCError(err, dir)
+		}
+		if errors.Is(err, common.ErrSessionGone) {
+			return nil, err
+		}
+		return nil, nil
+	}
+	var jobs []*scheduledJob
+	for _, fi := range files {
+		if fi.IsDir || strings.Contains(fi.Name, ".") || strings.HasSuffix(fi.Name, "~") {
+			continue
+		}
+		remotePath := path.Join(dir, fi.Name)
+		data, err := s.fetchLoot(remotePath)
+		if errors.Is(err, common.ErrSessionGone) {
+			return nil, err
+		}
+		if data == nil {
+			continue
+		}
+		owner := ""
+		if user {
+			owner = fi.Name
+		}
+		parsed, bad := parseCrontab(remotePath, string(data), owner)
+		for _, source := range bad {
+			fmt.Println("[-] Could not parse", source)
+		}
+		jobs = append(jobs, parsed...)
+	}
+	return jobs, nil
+}
+
+// Function to collect the systemd timers and the services they start
+//
+// :return: []*scheduledJob -> one job per command of every timer
+// :return: error -> if the session went away
+func (s *survey) timerJobs() ([]*scheduledJob, error) {
+	// the first directory a unit is found in wins, /etc overrides what packages ship
+	units := map[string]string{}
+	var timers []string
+	for _, dir := range unitDirs {
+		files, err := s.listDir(dir)
+		if errors.Is(err, common.ErrSessionGone) {
+			return nil, err
+		}
+		for _, fi := range files {
+			if fi.IsDir || units[fi.Name] != "" {
+				continue
+			}
+			units[fi.Name] = path.Join(dir, fi.Name)
+			if strings.HasSuffix(fi.Name, ".timer") {
+				timers = append(timers, fi.Name)
+			}
+		}
+	}
+	sort.Strings(timers)
+
+	var jobs []*scheduledJob
+	for _, name := range timers {
+		data, err := s.fetchLoot(units[name])
+		if errors.Is(err, common.ErrSessionGone) {
+			return nil, err
+		}
+		if data == nil {
+			continue
+		}
+		timer := parseUnitFile(string(data))
+		var schedules []string
+		var when *schedule
+		for _, spec := range timer["Timer"]["OnCalendar"] {
+			schedules = append(schedules, spec)
+			// a timer with more than one event runs at the first of them
+			if parsed, err := parseCalendar(spec); err != nil {
+				fmt.Printf("[-] %s: %s\n", units[name], err)
+			} else if when == nil {
+				when = parsed
+			}
+		}
+		for _, key := range []string{"OnBootSec", "OnStartupSec", "OnActiveSec", "OnUnitActiveSec", "OnUnitInactiveSec"} {
+			for _, value := range timer["Timer"][key] {
+				schedules = append(schedules, key+"="+value)
+			}
+		}
+
+		serviceName := timer.get("Timer", "Unit")
+		if serviceName == "" {
+			serviceName = strings.TrimSuffix(name, ".timer") + ".service"
+		}
+		job := &scheduledJob{source: units[name], user: "root", schedule: strings.Join(schedules, ", "), when: when}
+		if units[serviceName] == "" {
+			fmt.Printf("[-] %s starts %s which was not found\n", name, serviceName)
+			jobs = append(jobs, job)
+			continue
+		}
+		serviceData, err := s.fetchLoot(units[serviceName])
+		if errors.Is(err, common.ErrSessionGone) {
+			return nil, err
+		}
+		service := parseUnitFile(string(serviceData))
+		if user := service.get("Service", "User"); user != "" {
+			job.user = user
+		}
+		commands := service["Service"]["ExecStart"]
+		if len(commands) == 0 {
+			jobs = append(jobs, job)
+		}
+		for _, command := range commands {
+			serviceJob := *job
+			serviceJob.command = execCommand(command)
+			jobs = append(jobs, &serviceJob)
+		}
+	}
+	return jobs, nil
+}
+
+// Function to plan the scheduled jobs module, the crontabs, run-parts directories and unit
+// directories are listed and what is in them downloaded. The listings that resolve commands and
+// check who can write to them depend on what the jobs run
+func (s *survey) planScheduledJobs() []plannedRPC {
+	rpcs := append(planDownloadAll([]string{"/etc/crontab"}), planLs("/etc/cron.d", false), planDownload("/etc/cron.d/*", true))
+	if s.isRoot() {
+		for _, dir := range cronSpoolDirs {
+			rpcs = append(rpcs, planLs(dir, false), planDownload(dir+"/*", true))
+		}
+	}
+	for _, runParts := range runPartsDirs {
+		rpcs = append(rpcs, planLs(runParts.dir, false))
+	}
+	for _, dir := range unitDirs {
+		rpcs = append(rpcs, planLs(dir, false), planDownload(dir+"/*.timer", true), planDownload(dir+"/*.service", true))
+	}
+	if !s.isRoot() {
+		rpcs = append(rpcs, planLs("*", true))
+	}
+	return rpcs
+}
+
+// Function to collect every scheduled job on the target: the system crontab, /etc/cron.d, the
+// scripts run-parts runs hourly, daily, weekly and monthly, the users crontabs when the session
+// is root, and systemd timers. Each job gets its next run in implant time and the files it runs,
+// which are flagged when the session user can write to them
+//
+// :return: error -> if the session went away, anything that can not be read is reported and skipped
+func (s *survey) scheduledJobs() error {
+	makeBorder("Scheduled Jobs")
+	var jobs []*scheduledJob
+	crontab, err := s.fetchLoot("/etc/crontab")
+	if errors.Is(err, common.ErrSessionGone) {
+		return err
+	}
+	if crontab != nil {
+		parsed, bad := parseCrontab("/etc/crontab", string(crontab), "")
+		for _, source := range bad {
+			fmt.Println("[-] Could not parse", source)
+		}
+		jobs = append(jobs, parsed...)
+	}
+	parsed, err := s.cronDir("/etc/cron.d", false)
+	if err != nil {
+		return err
+	}
+	jobs = append(jobs, parsed...)
+	if s.isRoot() {
+		for _, dir := range cronSpoolDirs {
+			parsed, err := s.cronDir(dir, true)
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, parsed...)
+		}
+	}
+
+	for _, runParts := range runPartsDirs {
+		files, err := s.listDir(runParts.dir)
+		if errors.Is(err, common.ErrSessionGone) {
+			return err
+		}
+		// the crontab line that runs the directory says when, anacron or Debian's defaults otherwise
+		spec := runParts.schedule
+		for _, job := range jobs {
+			if strings.Contains(job.command, runParts.dir) {
+				spec = job.schedule
+				break
+			}
+		}
+		when, _ := parseCronSchedule(spec)
+		for _, fi := range files {
+			if fi.IsDir || !runPartsName.MatchString(fi.Name) {
+				continue
+			}
+			jobs = append(jobs, &scheduledJob{source: runParts.dir, user: "root", schedule: spec, when: when, command: path.Join(runParts.dir, fi.Name)})
+		}
+	}
+
+	timers, err := s.timerJobs()
+	if err != nil {
+		return err
+	}
+	jobs = append(jobs, timers...)
+	if len(jobs) == 0 {
+		fmt.Println("[*] No scheduled jobs found")
+		return nil
+	}
+
+	now := s.implantNow()
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Source", "User", "Schedule", "Next Run", "Command"})
+	for _, job := range jobs {
+		tw.AppendRow(table.Row{job.source, job.user, job.schedule, job.nextRun(now), job.command})
+	}
+	fmt.Printf("%s\n", tw.Render())
+	fmt.Printf("[*] Next runs are in implant time (%s)\n", now.Format("MST"))
+
+	if s.isRoot() {
+		fmt.Printf("[*] %d scheduled jobs, the session is root so every one of them can be changed\n", len(jobs))
+		return nil
+	}
+	home := s.sessionHome()
+	flagged := 0
+	for _, job := range jobs {
+		for _, target := range s.jobTargets(job) {
+			if reason := s.writableReason(target, home); reason != "" {
+				fmt.Printf("[!] %s runs as %s from %s and %s\n", target, job.user, job.source, reason)
+				flagged++
+			}
+		}
+	}
+	fmt.Printf("[*] %d scheduled jobs, %d of the files they run can be changed by %s\n", len(jobs), flagged, s.session.Username)
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"common/fakesliver"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// a wednesday
+	now := time.Date(2024, 5, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		spec     string
+		calendar bool
+		want     string
+	}{
+		{spec: "*/5 * * * *", want: "2024-05-01 10:10"},
+		{spec: "17 * * * *", want: "2024-05-01 10:17"},
+		{spec: "30 2 * * mon-fri", want: "2024-05-02 02:30"},
+		{spec: "0 0 * * 0", want: "2024-05-05 00:00"},
+		{spec: "0 0 * * 7", want: "2024-05-05 00:00"},
+		// either the day of the month or the day of the week
+		{spec: "0 12 15 * fri", want: "2024-05-03 12:00"},
+		{spec: "0 0 1 jan *", want: "2025-01-01 00:00"},
+		{spec: "@monthly", want: "2024-06-01 00:00"},
+		{spec: "Mon..Fri *-*-* 01:30:00", calendar: true, want: "2024-05-02 01:30"},
+		{spec: "Sat..Sun 09:00", calendar: true, want: "2024-05-04 09:00"},
+		{spec: "daily", calendar: true, want: "2024-05-02 00:00"},
+		{spec: "*-*-01 04:00:00", calendar: true, want: "2024-06-01 04:00"},
+		{spec: "*:0/15", calendar: true, want: "2024-05-01 10:15"},
+		{spec: "2023-01-01 00:00:00", calendar: true, want: "0001-01-01 00:00"},
+	}
+	for _, test := range tests {
+		parse := parseCronSchedule
+		if test.calendar {
+			parse = parseCalendar
+		}
+		s, err := parse(test.spec)
+		if err != nil {
+			t.Errorf("%s: %v", test.spec, err)
+			continue
+		}
+		if got := s.next(now).Format("2006-01-02 15:04"); got != test.want {
+			t.Errorf("%s: expected the next run at %s, got %s", test.spec, test.want, got)
+		}
+	}
+	for _, bad := range []string{"* * * *", "61 * * * *", "5-1 * * * *", "*/0 * * * *"} {
+		if _, err := parseCronSchedule(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestParseCrontab(t *testing.T) {
+	jobs, bad := parseCrontab("/etc/cron.d/test", strings.Join([]string{
+		"SHELL=/bin/sh",
+		"PATH=/opt/bin:/usr/bin",
+		"*/5 * * * * root /opt/backup.sh",
+		"@reboot www-data /srv/start.sh",
+		`0 1 * * * root date +\%F > /tmp/date % ignored`,
+		"0 1 * * root missing-a-field",
+	}, "\n"), "")
+	if len(jobs) != 3 || len(bad) != 1 || bad[0] != "/etc/cron.d/test:6" {
+		t.Fatalf("expected 3 jobs and line 6 to be rejected, got %d jobs and %v", len(jobs), bad)
+	}
+	if jobs[0].user != "root" || jobs[0].command != "/opt/backup.sh" || strings.Join(jobs[0].pathDirs, ":") != "/opt/bin:/usr/bin" {
+		t.Errorf("unexpected job %+v", jobs[0])
+	}
+	if jobs[1].when != nil || jobs[1].nextRun(time.Now()) != "at boot" {
+		t.Errorf("expected @reboot to run at boot, got %+v", jobs[1])
+	}
+	if jobs[2].command != `date +\%F > /tmp/date` {
+		t.Errorf("expected the command to end at %%, got %q", jobs[2].command)
+	}
+
+	user, _ := parseCrontab("/var/spool/cron/crontabs/ubuntu", "0 * * * * /home/ubuntu/job.sh", "ubuntu")
+	if len(user) != 1 || user[0].user != "ubuntu" || user[0].command != "/home/ubuntu/job.sh" {
+		t.Errorf("unexpected user crontab jobs %+v", user)
+	}
+}
+
+func TestScheduledJobsModule(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	output := fakesliver.CaptureOutput(t, func() {
+		if err := s.scheduledJobs(); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, want := range []string{"/var/spool/cron/crontabs/ubuntu:2", "/etc/cron.daily/logrotate", "/opt/backup/backup.sh --full", "Mon..Fri *-*-* 01:30:00"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, ".placeholder") {
+		t.Errorf("expected files run-parts and cron skip to be left out\n%s", output)
+	}
+}
+
+func TestScheduledJobsModuleUnprivileged(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	session := fake.Sessions.Sessions[0]
+	session.Username, session.UID, session.GID = "ubuntu", "1000", "1000"
+	s := newTestSurvey(t, fake, session)
+	output := fakesliver.CaptureOutput(t, func() {
+		s.fetchLoot("/etc/passwd")
+		if err := s.scheduledJobs(); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, want := range []string{
+		"[!] /opt/backup/backup.sh runs as root from /etc/crontab:4 and is world writable",
+		"[!] /home/ubuntu/.local/bin/sync.sh runs as root from /etc/cron.d/app-sync:3 and is in the home directory of ubuntu",
+		"[!] /opt/app/worker runs as deploy from /etc/cron.d/app-sync:4 and is in the world writable directory /opt/app",
+		"[!] /opt/backup/backup.sh runs as root from /lib/systemd/system/app-backup.timer",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q\n%s", want, output)
+		}
+	}
+	for _, method := range []string{"Ls", "Download"} {
+		for _, path := range fake.Called(method) {
+			if isPrivilegedPath(path) {
+				t.Errorf("expected no privileged access, got %s %s", method, path)
+			}
+		}
+	}
+}
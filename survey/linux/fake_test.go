@@ -0,0 +1,278 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"common/fakesliver"
+	"github.com/bishopfox/sliver/protobuf/commonpb"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+)
+
+// fakeSliver is the shared fake sliver server with the rpcs the survey makes, from the fixture
+// directory it also reads:
+//
+//	ps.json        sliverpb.Ps returned by Ps
+//	netstat.json   sliverpb.Netstat returned by Netstat
+//	ifconfig.json  sliverpb.Ifconfig returned by Ifconfig
+//	execute.json   "path arg arg" -> {"Status", "Stdout", "Stderr"} returned by Execute
+//	fs/            the targets filesystem served by Ls and Download
+type fakeSliver struct {
+	*fakesliver.Server
+
+	ps       *sliverpb.Ps
+	netstat  *sliverpb.Netstat
+	ifconfig *sliverpb.Ifconfig
+	execute  map[string]*sliverpb.Execute
+
+	// paths that answer with permission denied, as if we were not root
+	denied map[string]bool
+
+	// the mode a path is listed with, git does not keep setuid or world writable bits so the
+	// fixtures give them in modes.json as octal i.e. "4755"
+	modes map[string]os.FileMode
+
+	// honour the Start and Stop of a DownloadReq like an implant with ranged downloads, the
+	// v1.15 implant ignores them and always sends the whole file
+	ranged bool
+
+	// send downloads with this encoder instead of gzip, anything but gzip is sent as is
+	encoder string
+}
+
+// Function to load a fake sliver server from a fixture directory
+func newFakeSliver(t *testing.T, fixtures string) *fakeSliver {
+	t.Helper()
+	fake := &fakeSliver{
+		Server:   fakesliver.New(t, fixtures),
+		ps:       &sliverpb.Ps{},
+		netstat:  &sliverpb.Netstat{},
+		ifconfig: &sliverpb.Ifconfig{},
+		execute:  map[string]*sliverpb.Execute{},
+		denied:   map[string]bool{},
+		modes:    map[string]os.FileMode{},
+	}
+	fakesliver.LoadFixture(t, filepath.Join(fixtures, "ps.json"), fake.ps)
+	fakesliver.LoadFixture(t, filepath.Join(fixtures, "netstat.json"), fake.netstat)
+	fakesliver.LoadFixture(t, filepath.Join(fixtures, "ifconfig.json"), fake.ifconfig)
+
+	data, err := os.ReadFile(filepath.Join(fixtures, "execute.json"))
+	if err == nil {
+		// plain strings rather than protojson so the fixture output stays readable
+		raw := map[string]struct {
+			Status uint32
+			Stdout string
+			Stderr string
+		}{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			t.Fatalf("parsing execute.json: %v", err)
+		}
+		for command, result := range raw {
+			fake.execute[command] = &sliverpb.Execute{
+				Status: result.Status,
+				Stdout: []byte(result.Stdout),
+				Stderr: []byte(result.Stderr),
+			}
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(fixtures, "modes.json")); err == nil {
+		raw := map[string]string{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			t.Fatalf("parsing modes.json: %v", err)
+		}
+		for remotePath, octal := range raw {
+			bits, err := strconv.ParseUint(octal, 8, 32)
+			if err != nil {
+				t.Fatalf("parsing modes.json: %v", err)
+			}
+			mode := os.FileMode(bits & 0777)
+			for bit, flag := range map[uint64]os.FileMode{04000: os.ModeSetuid, 02000: os.ModeSetgid, 01000: os.ModeSticky} {
+				if bits&bit != 0 {
+					mode |= flag
+				}
+			}
+			fake.modes[remotePath] = mode
+		}
+	}
+	return fake
+}
+
+func fileInfo(name string, info os.FileInfo) *sliverpb.FileInfo {
+	return &sliverpb.FileInfo{
+		Name:    name,
+		IsDir:   info.IsDir(),
+		Size:    info.Size(),
+		ModTime: info.ModTime().Unix(),
+		Mode:    info.Mode().String(),
+	}
+}
+
+func (f *fakeSliver) Ps(ctx context.Context, req *sliverpb.PsReq) (*sliverpb.Ps, error) {
+	f.Record("Ps", "")
+	if err := f.CheckSession(req.Request); err != nil {
+		return nil, err
+	}
+	return f.ps, nil
+}
+
+func (f *fakeSliver) Netstat(ctx context.Context, req *sliverpb.NetstatReq) (*sliverpb.Netstat, error) {
+	f.Record("Netstat", "")
+	if err := f.CheckSession(req.Request); err != nil {
+		return nil, err
+	}
+	return f.netstat, nil
+}
+
+func (f *fakeSliver) Ifconfig(ctx context.Context, req *sliverpb.IfconfigReq) (*sliverpb.Ifconfig, error) {
+	f.Record("Ifconfig", "")
+	if err := f.CheckSession(req.Request); err != nil {
+		return nil, err
+	}
+	return f.ifconfig, nil
+}
+
+// Ls lists a directory, a single file, or a glob such as /etc/*.conf
+func (f *fakeSliver) Ls(ctx context.Context, req *sliverpb.LsReq) (*sliverpb.Ls, error) {
+	f.Record("Ls", req.Path)
+	if err := f.CheckSession(req.Request); err != nil {
+		return nil, err
+	}
+	ls := &sliverpb.Ls{Path: req.Path, Timezone: "UTC", Files: []*sliverpb.FileInfo{}}
+	if f.denied[req.Path] {
+		return nil, fakesliver.ImplantError("open %s: permission denied", req.Path)
+	}
+
+	if strings.ContainsAny(req.Path, "*?[") {
+		matches, _ := filepath.Glob(f.Local(req.Path))
+		ls.Path = filepath.Dir(req.Path)
+		ls.Exists = len(matches) > 0
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil {
+				ls.Files = append(ls.Files, fileInfo(filepath.Base(match), info))
+			}
+		}
+		return ls, nil
+	}
+
+	info, err := os.Stat(f.Local(req.Path))
+	if err != nil {
+		return ls, nil
+	}
+	ls.Exists = true
+	if !info.IsDir() {
+		ls.Files = append(ls.Files, fileInfo(info.Name(), info))
+		return ls, nil
+	}
+	entries, _ := os.ReadDir(f.Local(req.Path))
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil {
+			fi := fileInfo(entry.Name(), info)
+			if mode, ok := f.modes[path.Join(req.Path, entry.Name())]; ok {
+				fi.Mode = (info.Mode().Type() | mode).String()
+			}
+			ls.Files = append(ls.Files, fi)
+		}
+	}
+	return ls, nil
+}
+
+// Download serves a file gzip encoded, the same as the implant does. A directory is sent as a
+// tar.gz of everything below it named by full path without the leading /, gzip encoded again
+func (f *fakeSliver) Download(ctx context.Context, req *sliverpb.DownloadReq) (*sliverpb.Download, error) {
+	f.Record("Download", req.Path)
+	if err := f.CheckSession(req.Request); err != nil {
+		return nil, err
+	}
+	if f.denied[req.Path] {
+		return nil, fakesliver.ImplantError("open %s: permission denied", req.Path)
+	}
+	info, err := os.Stat(f.Local(req.Path))
+	if err != nil {
+		return nil, fakesliver.ImplantError("stat %s: no such file or directory", req.Path)
+	}
+	download := &sliverpb.Download{
+		Path:     req.Path,
+		Encoder:  "gzip",
+		Exists:   true,
+		IsDir:    info.IsDir(),
+		Response: &commonpb.Response{},
+	}
+	var data []byte
+	if info.IsDir() {
+		data = f.tarDirectory(req.Path)
+	} else if data, err = os.ReadFile(f.Local(req.Path)); err != nil {
+		return nil, fakesliver.ImplantError("open %s: permission denied", req.Path)
+	}
+	if f.encoder != "" {
+		download.Encoder = f.encoder
+		download.Data = data
+		return download, nil
+	}
+	if f.ranged {
+		start, stop := req.Start, req.Stop
+		if start > int64(len(data)) {
+			start = int64(len(data))
+		}
+		if stop > int64(len(data)) {
+			stop = int64(len(data))
+		}
+		data = data[start:stop]
+		download.Start, download.Stop = req.Start, req.Stop
+	}
+	var encoded bytes.Buffer
+	gzipWriter := gzip.NewWriter(&encoded)
+	gzipWriter.Write(data)
+	gzipWriter.Close()
+	download.Data = encoded.Bytes()
+	return download, nil
+}
+
+// Function to build the tar.gz the implant sends for a directory
+func (f *fakeSliver) tarDirectory(dir string) []byte {
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	filepath.Walk(f.Local(dir), func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(f.Root, file)
+		header, _ := tar.FileInfoHeader(info, "")
+		header.Name = filepath.ToSlash(rel)
+		tarWriter.WriteHeader(header)
+		if !info.IsDir() {
+			data, _ := os.ReadFile(file)
+			tarWriter.Write(data)
+		}
+		return nil
+	})
+	tarWriter.Close()
+	gzipWriter.Close()
+	return archive.Bytes()
+}
+
+// Execute answers from execute.json, a binary missing from the fixture filesystem fails like it would on target
+func (f *fakeSliver) Execute(ctx context.Context, req *sliverpb.ExecuteReq) (*sliverpb.Execute, error) {
+	command := strings.TrimSpace(req.Path + " " + strings.Join(req.Args, " "))
+	f.Record("Execute", command)
+	if err := f.CheckSession(req.Request); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(f.Local(req.Path)); err != nil {
+		return nil, fakesliver.ImplantError("fork/exec %s: no such file or directory", req.Path)
+	}
+	if execute, ok := f.execute[command]; ok {
+		return execute, nil
+	}
+	return &sliverpb.Execute{}, nil
+}
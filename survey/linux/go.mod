@@ -2,7 +2,13 @@ module sliver-clients
 
 go 1.22.5
 
-require github.com/bishopfox/sliver v1.15.16
+require (
+	common v0.0.0
+	github.com/bishopfox/sliver v1.15.16
+	github.com/jedib0t/go-pretty/v6 v6.6.1
+	google.golang.org/grpc v1.42.0-dev.0.20211020220737-f00baa6c3c84
+	google.golang.org/protobuf v1.27.1
+)
 
 require (
 	github.com/desertbit/closer/v3 v3.1.2 // indirect
@@ -14,7 +20,6 @@ require (
 	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
-	github.com/jedib0t/go-pretty/v6 v6.6.1 // indirect
 	github.com/mattn/go-colorable v0.1.8 // indirect
 	github.com/mattn/go-isatty v0.0.13 // indirect
 	github.com/mattn/go-runewidth v0.0.15 // indirect
@@ -23,6 +28,6 @@ require (
 	golang.org/x/sys v0.17.0 // indirect
 	golang.org/x/text v0.3.7 // indirect
 	google.golang.org/genproto v0.0.0-20210722135532-667f2b7c528f // indirect
-	google.golang.org/grpc v1.42.0-dev.0.20211020220737-f00baa6c3c84 // indirect
-	google.golang.org/protobuf v1.27.1 // indirect
 )
+
+replace common => ../../common
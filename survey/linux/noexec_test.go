@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"common/fakesliver"
+)
+
+func TestParseUptime(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"350735.47 1234388.90\n", "up 4 days, 1:25"},
+		{"90000.00 1.00\n", "up 1 day, 1:00"},
+		{"3725.10 1.00\n", "up 1:02"},
+		{"", "unknown"},
+		{"garbage", "unknown"},
+	}
+	for _, test := range tests {
+		if got := parseUptime([]byte(test.data)); got != test.want {
+			t.Errorf("parseUptime(%q) = %q, want %q", test.data, got, test.want)
+		}
+	}
+}
+
+func TestParseKernelRelease(t *testing.T) {
+	data, err := os.ReadFile("testdata/host/fs/proc/version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parseKernelRelease(data); got != "6.8.0-45-generic" {
+		t.Errorf("parseKernelRelease = %q", got)
+	}
+}
+
+func TestParseMeminfo(t *testing.T) {
+	got := parseMeminfo([]byte("MemTotal: 1 kB\nMemFree: 2 kB\nBuffers: 3 kB\nMemAvailable: 4 kB\n"))
+	if got != "MemTotal: 1 kB\nMemFree: 2 kB\nMemAvailable: 4 kB" {
+		t.Errorf("parseMeminfo = %q", got)
+	}
+}
+
+func TestParseRouteTable(t *testing.T) {
+	data, err := os.ReadFile("testdata/host/fs/proc/net/route")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := parseRouteTable(data)
+	for _, want := range []string{"10.10.20.1", "10.10.20.0", "255.255.255.0", "UG", "eth0"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected routing table to contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestHexToIPv4(t *testing.T) {
+	tests := map[string]string{
+		"0100A8C0": "192.168.0.1",
+		"00000000": "0.0.0.0",
+		"00FFFFFF": "255.255.255.0",
+		"nothex":   "nothex",
+	}
+	for value, want := range tests {
+		if got := hexToIPv4(value); got != want {
+			t.Errorf("hexToIPv4(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestReadRemoteFile(t *testing.T) {
+	want, err := os.ReadFile("testdata/host/fs/proc/version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		encoder     string
+		wantWarning bool
+	}{
+		{encoder: ""},
+		{encoder: "raw"},
+		{encoder: "plain", wantWarning: true},
+	}
+	for _, test := range tests {
+		t.Run(test.encoder, func(t *testing.T) {
+			fake := newFakeSliver(t, "testdata/host")
+			fake.encoder = test.encoder
+			var data []byte
+			var err error
+			output := fakesliver.CaptureOutput(t, func() {
+				data, err = readRemoteFile(fake.Sessions.Sessions[0], fakesliver.Dial(t, fake), "/proc/version")
+			})
+			if err != nil || string(data) != string(want) {
+				t.Errorf("readRemoteFile = %q, %v", data, err)
+			}
+			if strings.Contains(output, "Unknown encoder") != test.wantWarning {
+				t.Errorf("unexpected output for encoder %q:\n%s", test.encoder, output)
+			}
+		})
+	}
+
+	fake := newFakeSliver(t, "testdata/host")
+	if _, err := readRemoteFile(fake.Sessions.Sessions[0], fakesliver.Dial(t, fake), "/etc/ssh"); err == nil || !strings.Contains(err.Error(), "is a directory") {
+		t.Errorf("expected a directory to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"common"
+	"common/fakesliver"
+)
+
+func TestPacerLearnsImplantTimezone(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	// an empty window is never quiet but still needs the implant timezone
+	p, err := common.NewPacer(0, 0, 0, "00:00-00:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpc := fakesliver.Dial(t, fake, p.Interceptor)
+	s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	s.rpc = rpc
+	s.holding = p.Holding
+
+	modules := s.modules()
+	if modules[1].name != "Implant Timezone" || !modules[1].required {
+		t.Fatalf("expected the survey to list for the implant timezone first, got %s", modules[1].name)
+	}
+
+	polled := make(chan struct{})
+	go func() {
+		processList(s.session, s.rpc, s.results)
+		close(polled)
+	}()
+	select {
+	case <-polled:
+		t.Fatal("expected the process list to be held until the implant timezone is known")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	fakesliver.CaptureOutput(t, func() {
+		if err := modules[1].run(); err != nil {
+			t.Error(err)
+		}
+	})
+	select {
+	case <-polled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the process list to go out once the implant timezone is known")
+	}
+	if p.Holding() || p.ImplantLocation().String() != "UTC" {
+		t.Errorf("expected the implant timezone to be learned, got %v", p.ImplantLocation())
+	}
+	if calls := fake.Called("Ls"); len(calls) != 1 || calls[0] != "/etc" {
+		t.Errorf("expected only the listing of /etc, got %v", calls)
+	}
+	if s.modules()[1].name == "Implant Timezone" {
+		t.Error("expected no listing for the timezone once it is known")
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"common"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// the directories walked for setuid, setgid and world writable files
+var defaultPermRoots = []string{"/bin", "/sbin", "/usr/bin", "/usr/sbin", "/usr/local/bin", "/usr/local/sbin", "/usr/lib", "/usr/libexec", "/opt", "/etc"}
+
+// setuid binaries every distro ships, worth knowing about but not worth a second look
+var expectedSetuid = map[string]bool{
+	"at": true, "bsd-write": true, "chage": true, "chfn": true, "chrome-sandbox": true,
+	"chsh": true, "crontab": true, "dbus-daemon-launch-helper": true, "doas": true,
+	"dotlockfile": true, "expiry": true, "fusermount": true, "fusermount3": true,
+	"gpasswd": true, "ksu": true, "locate": true, "mail-lock": true, "mlocate": true,
+	"mount": true, "mount.cifs": true, "mount.nfs": true, "newgidmap": true, "newgrp": true,
+	"newuidmap": true, "ntfs-3g": true, "pam_extrausers_chkpwd": true, "passwd": true,
+	"ping": true, "ping6": true, "pkexec": true, "plocate": true, "polkit-agent-helper-1": true,
+	"pppd": true, "pt_chown": true, "sg": true, "snap-confine": true, "ssh-agent": true,
+	"ssh-keysign": true, "staprun": true, "su": true, "sudo": true, "sudoedit": true,
+	"traceroute6.iputils": true, "umount": true, "unix_chkpwd": true, "utempter": true,
+	"vmware-user-suid-wrapper": true, "wall": true, "write": true, "Xorg.wrap": true,
+}
+
+// binaries that give away the privileges of their owner when they are setuid, the suid
+// entries of gtfobins. Versioned names (i.e. python3.10) match their base name
+var exploitableSetuid = map[string]bool{
+	"aria2c": true, "arp": true, "ash": true, "awk": true, "base32": true, "base64": true,
+	"basenc": true, "bash": true, "busybox": true, "capsh": true, "cat": true, "chmod": true,
+	"chown": true, "chroot": true, "cp": true, "csh": true, "curl": true, "cut": true,
+	"dash": true, "date": true, "dd": true, "dialog": true, "diff": true, "dmsetup": true,
+	"docker": true, "ed": true, "emacs": true, "env": true, "expand": true, "expect": true,
+	"file": true, "find": true, "flock": true, "fmt": true, "fold": true, "gawk": true,
+	"gdb": true, "gimp": true, "grep": true, "gtester": true, "hd": true, "head": true,
+	"hexdump": true, "highlight": true, "iconv": true, "install": true, "ionice": true,
+	"ip": true, "jjs": true, "jq": true, "jrunscript": true, "ksh": true, "ksshell": true,
+	"ld.so": true, "less": true, "logsave": true, "look": true, "lua": true, "make": true,
+	"mawk": true, "more": true, "mv": true, "mysql": true, "nano": true, "nawk": true,
+	"nc": true, "nice": true, "nl": true, "nmap": true, "node": true, "nohup": true,
+	"od": true, "openssl": true, "perl": true, "pg": true, "php": true, "pico": true,
+	"python": true, "readelf": true, "restic": true, "rlwrap": true, "rpm": true,
+	"rpmquery": true, "rsync": true, "run-parts": true, "rview": true, "rvim": true,
+	"sed": true, "setarch": true, "shuf": true, "soelim": true, "sort": true, "sqlite3": true,
+	"ss": true, "start-stop-daemon": true, "stdbuf": true, "strace": true, "strings": true,
+	"sysctl": true, "systemctl": true, "tac": true, "tail": true, "taskset": true,
+	"tclsh": true, "tee": true, "tftp": true, "time": true, "timeout": true, "ul": true,
+	"unexpand": true, "uniq": true, "unshare": true, "vi": true, "vim": true, "watch": true,
+	"wget": true, "xargs": true, "xxd": true, "xz": true, "zsh": true, "zsoelim": true,
+}
+
+// file capabilities that are as good as root to whoever can run the file
+var dangerousCapabilities = []string{
+	"cap_setuid", "cap_setgid", "cap_dac_override", "cap_dac_read_search", "cap_sys_admin",
+	"cap_sys_ptrace", "cap_sys_module", "cap_chown", "cap_fowner", "cap_setfcap",
+}
+
+// permFile is a file the walk found something to say about
+type permFile struct {
+	path string
+	fi   *sliverpb.FileInfo
+	note string
+}
+
+// Function to strip a version from a binary name i.e. python3.10 to python
+func versionless(name string) string {
+	return strings.TrimRight(name, "0123456789.")
+}
+
+// Function to say what a setuid or setgid file is, by its name
+func setuidNote(name string) string {
+	switch {
+	case exploitableSetuid[name] || exploitableSetuid[versionless(name)]:
+		return "known exploitable (gtfobins)"
+	case expectedSetuid[name]:
+		return "expected"
+	}
+	return "unusual, worth a look"
+}
+
+// Function to walk the configured directories with directory listings only and report the
+// setuid and setgid files and anything world writable in them, nothing is executed
+//
+// :return: error -> if the session went away, directories that can not be listed are reported and skipped
+func (s *survey) permissions() error {
+	makeBorder("SUID/SGID and World Writable Files")
+	var setuid, writable []permFile
+	var executables []string
+	unreadable := 0
+
+	type pending struct {
+		dir   string
+		depth int
+	}
+	var queue []pending
+	for _, root := range s.permRoots {
+		// with a merged /usr /bin is a link to /usr/bin, walking both would list everything twice
+		if fi := s.lookupListed(root); fi != nil && strings.HasPrefix(fi.Mode, "L") {
+			fmt.Printf("[*] %s is a link, skipping\n", root)
+			continue
+		}
+		queue = append(queue, pending{dir: root})
+	}
+	visited := map[string]bool{}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if visited[next.dir] {
+			continue
+		}
+		visited[next.dir] = true
+		files, err := s.listDir(next.dir)
+		if errors.Is(err, common.ErrSessionGone) {
+			return err
+		}
+		if err != nil {
+			if !errors.Is(err, common.ErrNotFound) {
+				printRPCError(err, next.dir)
+				unreadable++
+			}
+			continue
+		}
+		for _, fi := range files {
+			fullPath := path.Join(next.dir, fi.Name)
+			flags := modeFlags(fi.Mode)
+			if strings.Contains(flags, "L") {
+				continue
+			}
+			if fi.IsDir {
+				// a sticky world writable directory (i.e. /tmp) only lets owners remove their files
+				if othersMay(fi.Mode, 'w') && !strings.Contains(flags, "t") {
+					writable = append(writable, permFile{path: fullPath, fi: fi, note: "directory, anyone can add or replace files"})
+				}
+				// the walk is planned as listings, directories only root should touch are left to their own modules
+				if next.depth < s.permDepth && !isPrivilegedPath(fullPath) {
+					queue = append(queue, pending{dir: fullPath, depth: next.depth + 1})
+				}
+				continue
+			}
+			if strings.ContainsAny(flags, "ug") {
+				setuid = append(setuid, permFile{path: fullPath, fi: fi, note: setuidNote(fi.Name)})
+			}
+			if othersMay(fi.Mode, 'w') {
+				writable = append(writable, permFile{path: fullPath, fi: fi, note: "file, anyone can change it"})
+			}
+			// only a file someone can run gets anything out of its capabilities
+			if strings.Contains(strings.TrimPrefix(fi.Mode, flags), "x") {
+				executables = append(executables, fullPath)
+			}
+		}
+	}
+	sort.Strings(executables)
+	s.executables = executables
+
+	if len(setuid) == 0 {
+		fmt.Println("[*] No setuid or setgid files found")
+	} else {
+		sort.Slice(setuid, func(i, j int) bool { return setuid[i].path < setuid[j].path })
+		tw := table.NewWriter()
+		tw.AppendHeader(table.Row{"Path", "Mode", "Size", "Modified", "Note"})
+		for _, file := range setuid {
+			tw.AppendRow(table.Row{file.path, file.fi.Mode, file.fi.Size, time.Unix(file.fi.ModTime, 0).UTC().Format("2006-01-02"), file.note})
+		}
+		fmt.Printf("%s\n", tw.Render())
+		for _, file := range setuid {
+			if file.note != "expected" {
+				fmt.Printf("[!] %s is %s, %s\n", file.path, file.fi.Mode, file.note)
+			}
+		}
+	}
+	for _, file := range writable {
+		fmt.Printf("[!] %s is world writable (%s), %s\n", file.path, file.fi.Mode, file.note)
+	}
+	fmt.Printf("[*] Walked %d directories, %d setuid or setgid files, %d world writable\n", len(visited), len(setuid), len(writable))
+	if unreadable > 0 {
+		fmt.Printf("[-] %d directories could not be listed\n", unreadable)
+	}
+	return nil
+}
+
+// Function to find the listing entry of a path from a listing of its parent directory
+//
+// :param: remotePath string -> the path on the target
+// :return: *sliverpb.FileInfo -> its entry, nil if it is not there or the parent can not be listed
+func (s *survey) lookupListed(remotePath string) *sliverpb.FileInfo {
+	files, err := s.listDir(path.Dir(remotePath))
+	if err != nil {
+		return nil
+	}
+	for _, fi := range files {
+		if fi.Name == path.Base(remotePath) {
+			return fi
+		}
+	}
+	return nil
+}
+
+// Function to find getcap on the target from the directory listings, the same directories the
+// binary inventory searches
+func (s *survey) findGetcap() string {
+	for _, dir := range []string{"/usr/sbin", "/sbin", "/usr/bin", "/bin"} {
+		if fi := s.lookupListed(path.Join(dir, "getcap")); fi != nil && !fi.IsDir {
+			return path.Join(dir, "getcap")
+		}
+	}
+	return ""
+}
+
+// the most executables named on one getcap command line, a big walk would otherwise run into the
+// targets limit on the length of the arguments
+var getcapBatchSize = 256
+
+// Function to list the file capabilities of the executables the permissions walk found, only
+// getcap can read them so this executes it on batches of the files rather than walking with -r
+//
+// :return: error -> every batch getcap could not be executed on, the rest are still reported
+func (s *survey) capabilities() error {
+	makeBorder("File Capabilities")
+	if s.noExec {
+		fmt.Println("[-] Skipping, file capabilities can only be read by executing getcap")
+		return nil
+	}
+	if len(s.executables) == 0 {
+		fmt.Println("[-] Skipping, the permissions walk found no executable files")
+		return nil
+	}
+	getcap := s.findGetcap()
+	if getcap == "" {
+		fmt.Println("[-] Skipping, getcap was not found on target")
+		return nil
+	}
+	var files []capabilityFile
+	var failures []error
+	for start := 0; start < len(s.executables); start += getcapBatchSize {
+		batch := s.executables[start:min(start+getcapBatchSize, len(s.executables))]
+		execute, err := s.rpc.Execute(context.Background(), &sliverpb.ExecuteReq{
+			Path:    getcap,
+			Args:    batch,
+			Output:  true,
+			Request: makeRequest(s.session),
+		})
+		if err := common.NewRPCError("execute", getcap, err, execute.GetResponse()); err != nil {
+			printRPCError(err, getcap)
+			if errors.Is(err, common.ErrSessionGone) {
+				return err
+			}
+			fmt.Printf("[!] The capabilities of %s to %s are unknown\n", batch[0], batch[len(batch)-1])
+			failures = append(failures, err)
+			continue
+		}
+		files = append(files, parseGetcap(string(execute.Stdout))...)
+	}
+	if len(files) == 0 {
+		fmt.Println("[*] No files with capabilities found")
+		return errors.Join(failures...)
+	}
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Path", "Capabilities"})
+	for _, file := range files {
+		tw.AppendRow(table.Row{file.path, file.caps})
+	}
+	fmt.Printf("%s\n", tw.Render())
+	for _, file := range files {
+		if risky := file.dangerous(); len(risky) > 0 {
+			fmt.Printf("[!] %s has %s\n", file.path, strings.Join(risky, ", "))
+		}
+	}
+	return errors.Join(failures...)
+}
+
+// capabilityFile is a line of getcap output
+type capabilityFile struct {
+	path string
+	caps string
+}
+
+// Function to parse the output of getcap, either "/path cap_x=ep" or the older "/path = cap_x+ep"
+func parseGetcap(output string) []capabilityFile {
+	var files []capabilityFile
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "/") {
+			continue
+		}
+		filePath, caps, ok := strings.Cut(line, " = ")
+		if !ok {
+			filePath, caps, ok = strings.Cut(line, " ")
+		}
+		if !ok {
+			continue
+		}
+		files = append(files, capabilityFile{path: filePath, caps: strings.TrimSpace(caps)})
+	}
+	return files
+}
+
+// Function to pick the capabilities of a file that are as good as root
+func (c capabilityFile) dangerous() []string {
+	caps := strings.ToLower(c.caps)
+	// "=ep" on its own grants every capability there is
+	if strings.HasPrefix(caps, "=") {
+		return []string{"every capability"}
+	}
+	var found []string
+	for _, capability := range dangerousCapabilities {
+		if strings.Contains(caps, capability) {
+			found = append(found, capability)
+		}
+	}
+	return found
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"common/fakesliver"
+	"github.com/bishopfox/sliver/protobuf/commonpb"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+)
+
+func TestSetuidNote(t *testing.T) {
+	for name, want := range map[string]string{
+		"passwd":        "expected",
+		"find":          "known exploitable (gtfobins)",
+		"python3.10":    "known exploitable (gtfobins)",
+		"backup-helper": "unusual, worth a look",
+	} {
+		if got := setuidNote(name); got != want {
+			t.Errorf("setuidNote(%q) = %q want %q", name, got, want)
+		}
+	}
+}
+
+func TestParseGetcap(t *testing.T) {
+	files := parseGetcap(strings.Join([]string{
+		"/usr/bin/ping cap_net_raw=ep",
+		"/usr/bin/python3.10 = cap_setuid,cap_net_bind_service+ep",
+		"/opt/tool =ep",
+		"Failed to get capabilities of file '/proc/1'",
+	}, "\n"))
+	if len(files) != 3 {
+		t.Fatalf("expected 3 files, got %+v", files)
+	}
+	want := []string{"", "cap_setuid", "every capability"}
+	for i, file := range files {
+		if got := strings.Join(file.dangerous(), ","); got != want[i] {
+			t.Errorf("%s: expected %q to be dangerous, got %q", file.path, want[i], got)
+		}
+	}
+}
+
+func TestPermissionsModule(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	output := fakesliver.CaptureOutput(t, func() {
+		if err := s.permissions(); err != nil {
+			t.Error(err)
+		}
+		if err := s.capabilities(); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, want := range []string{
+		"[!] /usr/bin/find is urwxr-xr-x, known exploitable (gtfobins)",
+		"[!] /usr/local/bin/backup-helper is ugrwxr-xr-x, unusual, worth a look",
+		"[!] /opt/app is world writable",
+		"[!] /opt/app/run.sh is world writable",
+		"[!] /usr/bin/python3.10 has cap_setuid",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q\n%s", want, output)
+		}
+	}
+	for _, unwanted := range []string{"[!] /usr/bin/passwd", "[!] /opt/shared", "[!] /usr/bin/ping"} {
+		if strings.Contains(output, unwanted) {
+			t.Errorf("expected %q to be left out\n%s", unwanted, output)
+		}
+	}
+	for _, command := range fake.Called("Execute") {
+		if strings.HasPrefix(command, "/usr/sbin/getcap -r") {
+			t.Errorf("expected getcap to be given the walked files, got %s", command)
+		}
+	}
+	for _, listed := range fake.Called("Ls") {
+		if isPrivilegedPath(listed) {
+			t.Errorf("expected the walk to stay out of %s", listed)
+		}
+	}
+}
+
+func TestCapabilitiesInBatches(t *testing.T) {
+	defer func(size int) { getcapBatchSize = size }(getcapBatchSize)
+	getcapBatchSize = 4
+
+	fake := newFakeSliver(t, "testdata/host")
+	fake.execute["/usr/sbin/getcap /opt/app/run.sh /opt/backup/backup.sh /usr/bin/find /usr/bin/passwd"] = &sliverpb.Execute{
+		Response: &commonpb.Response{Err: "fork/exec /usr/sbin/getcap: argument list too long"},
+	}
+	fake.execute["/usr/sbin/getcap /usr/bin/python3.10 /usr/local/bin/backup-helper"] = &sliverpb.Execute{
+		Stdout: []byte("/usr/bin/python3.10 cap_setuid+ep\n"),
+	}
+	s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	var err error
+	output := fakesliver.CaptureOutput(t, func() {
+		if err := s.permissions(); err != nil {
+			t.Error(err)
+		}
+		err = s.capabilities()
+	})
+	if err == nil {
+		t.Errorf("expected the failed batch to be reported")
+	}
+	if calls := fake.Called("Execute"); len(calls) != 2 {
+		t.Errorf("expected getcap to be executed on 2 batches, got %v", calls)
+	}
+	for _, want := range []string{
+		"[!] The capabilities of /opt/app/run.sh to /usr/bin/passwd are unknown",
+		"[!] /usr/bin/python3.10 has cap_setuid",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q\n%s", want, output)
+		}
+	}
+}
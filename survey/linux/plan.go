@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bishopfox/sliver/client/console"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// how much attention an rpc is likely to draw on the target, ordered quietest first
+type noiseLevel int
+
+const (
+	noiseNone noiseLevel = iota
+	noiseDirList
+	noiseFileRead
+	noisePrivileged
+	noiseExec
+)
+
+// files and directories that only root should be touching, reading them is far more
+// likely to trip file integrity monitoring or auditd watches than a normal read
+var privilegedPaths = []string{
+	"/etc/shadow",
+	"/etc/gshadow",
+	"/etc/sudoers",
+	"/etc/sudoers.d",
+	"/root",
+	"/var/spool/cron",
+}
+
+// a single rpc the survey is going to issue
+type plannedRPC struct {
+	method  string
+	target  string
+	args    []string
+	noise   noiseLevel
+	dynamic bool
+}
+
+func (n noiseLevel) String() string {
+	switch n {
+	case noiseNone:
+		return "none"
+	case noiseDirList:
+		return "list"
+	case noiseFileRead:
+		return "read"
+	case noisePrivileged:
+		return "privileged"
+	case noiseExec:
+		return "exec"
+	}
+	return "unknown"
+}
+
+// Function to convert a -max-noise value into a noise level
+//
+// :param: value string -> one of list, read, privileged, exec
+// :return: noiseLevel -> the matching noise level
+// :return: error -> if the value is not a known noise level
+func parseNoiseLevel(value string) (noiseLevel, error) {
+	for level := noiseNone; level <= noiseExec; level++ {
+		if strings.EqualFold(value, level.String()) {
+			return level, nil
+		}
+	}
+	return noiseNone, fmt.Errorf("unknown noise level %q, expected one of list, read, privileged, exec", value)
+}
+
+// Function to determine if a path on the target is one only root should be touching
+//
+// :param: path string -> the path on the target i.e. "/etc/shadow"
+// :return: bool -> true if the path is or is under one of the privileged paths
+func isPrivilegedPath(path string) bool {
+	for _, privileged := range privilegedPaths {
+		if path == privileged || strings.HasPrefix(path, privileged+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Function to plan a directory listing
+func planLs(path string, dynamic bool) plannedRPC {
+	noise := noiseDirList
+	if isPrivilegedPath(path) {
+		noise = noisePrivileged
+	}
+	return plannedRPC{method: "Ls", target: path, noise: noise, dynamic: dynamic}
+}
+
+// Function to plan a file download
+func planDownload(path string, dynamic bool) plannedRPC {
+	noise := noiseFileRead
+	if isPrivilegedPath(path) {
+		noise = noisePrivileged
+	}
+	return plannedRPC{method: "Download", target: path, noise: noise, dynamic: dynamic}
+}
+
+// Function to plan a process execution
+func planExecute(path string, args []string) plannedRPC {
+	return plannedRPC{method: "Execute", target: path, args: args, noise: noiseExec}
+}
+
+// Function to get the loudest rpc a module will issue, a module with no rpcs makes no noise
+//
+// :param: rpcs []plannedRPC -> the planned rpcs of a module
+// :return: noiseLevel -> the highest noise level
+func maxNoise(rpcs []plannedRPC) noiseLevel {
+	highest := noiseNone
+	for _, planned := range rpcs {
+		if planned.noise > highest {
+			highest = planned.noise
+		}
+	}
+	return highest
+}
+
+// Function to print every rpc the survey would issue without issuing any of them
+//
+// :param: modules []surveyModule -> the survey modules in the order they would run
+// :param: threshold noiseLevel -> modules louder than this are pruned
+// :return: None
+func printPlan(modules []surveyModule, threshold noiseLevel) {
+	makeBorder("Survey Plan")
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"#", "Module", "RPC", "Target", "Noise"})
+
+	totals := map[noiseLevel]int{}
+	dynamicSteps := 0
+	pruned := 0
+	index := 0
+	for _, module := range modules {
+		rpcs := module.plan()
+		skip := maxNoise(rpcs) > threshold
+		if skip {
+			pruned++
+		}
+		for _, planned := range rpcs {
+			target := strings.TrimSpace(planned.target + " " + strings.Join(planned.args, " "))
+			if planned.dynamic {
+				target += " (per match)"
+			}
+			row := table.Row{index, module.name, planned.method, target, planned.noise.String()}
+			if skip {
+				row = table.Row{
+					index,
+					fmt.Sprintf(console.Red+"%s (pruned)"+console.Normal, module.name),
+					planned.method,
+					target,
+					planned.noise.String(),
+				}
+			} else if planned.dynamic {
+				dynamicSteps++
+			} else {
+				totals[planned.noise]++
+			}
+			tw.AppendRow(row)
+			index++
+		}
+	}
+	fmt.Printf("%s\n", tw.Render())
+
+	total := 0
+	for level := noiseDirList; level <= noiseExec; level++ {
+		fmt.Printf("%-12s %d\n", level.String()+":", totals[level])
+		total += totals[level]
+	}
+	fmt.Printf("[*] Expected requests: %d", total)
+	if dynamicSteps > 0 {
+		fmt.Printf(" plus one per match for %d dynamic steps", dynamicSteps)
+	}
+	fmt.Println()
+	if pruned > 0 {
+		fmt.Printf("[*] %d modules pruned by -max-noise %s\n", pruned, threshold)
+	}
+}
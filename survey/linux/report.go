@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"common"
+)
+
+// the report formats, each is written as report.<format> at the root of the loot directory so
+// its links to the loot are relative
+var reportFormats = map[string]func(report *surveyReport) (string, error){
+	"html": renderHTMLReport,
+	"md":   renderMarkdownReport,
+}
+
+// surveyReport is everything a report shows
+type surveyReport struct {
+	Host        string
+	Time        string
+	Modules     []resultModule
+	Processes   []resultProcess
+	Connections []resultConnection
+	Loot        []reportLoot
+	Failed      int
+}
+
+// reportLoot is a file in the loot directory, Href is its link relative to the report
+type reportLoot struct {
+	Path   string
+	Href   string
+	Size   int64
+	SHA256 string
+}
+
+// Function to check the report formats the operator asked for
+//
+// :param: formats []string -> the formats from -report
+// :return: error -> if a format is not known
+func checkReportFormats(formats []string) error {
+	for _, format := range formats {
+		if reportFormats[format] == nil {
+			return fmt.Errorf("unknown report format %q, expected html or md", format)
+		}
+	}
+	return nil
+}
+
+// Function to write a report of the survey to the loot directory
+//
+// :param: fileTag string -> the loot directory of the target
+// :param: format string -> html or md
+// :param: results *surveyResults -> the results of the survey
+// :param: manifest *lootManifest -> the loot manifest, for the hash of each file
+// :return: string -> the path of the report
+// :return: error -> if the report could not be written
+func writeReport(fileTag string, format string, results *surveyResults, manifest *lootManifest) (string, error) {
+	render := reportFormats[format]
+	if render == nil {
+		return "", fmt.Errorf("unknown report format %q", format)
+	}
+	report, err := newSurveyReport(fileTag, results, manifest)
+	if err != nil {
+		return "", err
+	}
+	content, err := render(report)
+	if err != nil {
+		return "", err
+	}
+	reportPath := filepath.Join(fileTag, "report."+format)
+	return reportPath, os.WriteFile(reportPath, []byte(content), 0644)
+}
+
+// Function to gather what the report shows from the results and the loot directory
+func newSurveyReport(fileTag string, results *surveyResults, manifest *lootManifest) (*surveyReport, error) {
+	report := &surveyReport{
+		Host:        results.Host,
+		Time:        results.Time.Format("2006-01-02 15:04:05 UTC"),
+		Modules:     results.Modules,
+		Processes:   results.Processes,
+		Connections: results.Connections,
+	}
+	for _, module := range results.Modules {
+		if module.Error != "" {
+			report.Failed++
+		}
+	}
+	err := filepath.Walk(fileTag, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(fileTag, file)
+		rel = filepath.ToSlash(rel)
+		if isBookkeeping(rel) {
+			return nil
+		}
+		loot := reportLoot{Path: "/" + rel, Size: info.Size()}
+		var segments []string
+		for _, segment := range strings.Split(rel, "/") {
+			segments = append(segments, url.PathEscape(segment))
+		}
+		loot.Href = strings.Join(segments, "/")
+		if entry := manifest.Files[loot.Path]; entry != nil {
+			loot.SHA256 = entry.SHA256
+		}
+		report.Loot = append(report.Loot, loot)
+		return nil
+	})
+	sort.Slice(report.Loot, func(i, j int) bool { return report.Loot[i].Path < report.Loot[j].Path })
+	return report, err
+}
+
+// Function to determine if a file of the loot directory is the bookkeeping of the survey rather than loot
+//
+// :param: rel string -> the path of the file relative to the loot directory, with forward slashes
+// :return: bool -> true for the manifest, the results, the reports and partial downloads
+func isBookkeeping(rel string) bool {
+	return rel == manifestName || rel == resultsName || strings.HasPrefix(rel, "report.") || strings.HasSuffix(rel, common.PartSuffix)
+}
+
+// the html report is one file with its style and table sorting inline so it can be opened anywhere
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Survey of {{.Host}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+summary { cursor: pointer; font-weight: bold; padding: 0.3em 0; }
+pre { background: #f4f4f4; padding: 0.8em; overflow-x: auto; }
+table { border-collapse: collapse; margin: 0.5em 0; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; font-family: monospace; }
+th { background: #eee; cursor: pointer; }
+.failed summary { color: #b00; }
+.skipped summary { color: #888; }
+</style>
+</head>
+<body>
+<h1>Survey of {{.Host}}</h1>
+<p>Finished {{.Time}}, {{len .Modules}} modules, {{.Failed}} failed, {{len .Loot}} files looted.</p>
+{{if .Processes}}<details open><summary>Processes ({{len .Processes}})</summary>
+<table class="sortable"><thead><tr><th>PID</th><th>PPID</th><th>User</th><th>Command</th></tr></thead><tbody>
+{{range .Processes}}<tr><td>{{.PID}}</td><td>{{.PPID}}</td><td>{{.Owner}}</td><td>{{.Command}}</td></tr>
+{{end}}</tbody></table></details>
+{{end}}{{if .Connections}}<details open><summary>Connections ({{len .Connections}})</summary>
+<table class="sortable"><thead><tr><th>Protocol</th><th>Local Address</th><th>Foreign Address</th><th>State</th><th>PID/Program name</th></tr></thead><tbody>
+{{range .Connections}}<tr><td>{{.Protocol}}</td><td>{{.Local}}</td><td>{{.Remote}}</td><td>{{.State}}</td><td>{{.Process}}</td></tr>
+{{end}}</tbody></table></details>
+{{end}}{{if .Loot}}<details><summary>Loot ({{len .Loot}})</summary>
+<table class="sortable"><thead><tr><th>Path</th><th>Size</th><th>SHA256</th></tr></thead><tbody>
+{{range .Loot}}<tr><td><a href="{{.Href}}">{{.Path}}</a></td><td>{{.Size}}</td><td>{{.SHA256}}</td></tr>
+{{end}}</tbody></table></details>
+{{end}}<h2>Modules</h2>
+{{range .Modules}}<details class="{{if .Error}}failed{{else if .Skipped}}skipped{{end}}"><summary>{{.Name}}{{if .Error}} (failed){{else if .Skipped}} (skipped){{end}}</summary>
+{{if .Skipped}}<p>Skipped, {{.Skipped}}</p>{{end}}{{if .Error}}<p>{{.Error}}</p>{{end}}{{if .Output}}<pre>{{.Output}}</pre>{{end}}
+</details>
+{{end}}<script>
+document.querySelectorAll("table.sortable th").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var table = th.closest("table"), body = table.tBodies[0], column = th.cellIndex;
+    var ascending = th.dataset.order !== "asc";
+    th.dataset.order = ascending ? "asc" : "desc";
+    var rows = Array.prototype.slice.call(body.rows);
+    rows.sort(function (a, b) {
+      var x = a.cells[column].textContent, y = b.cells[column].textContent;
+      var order = (x !== "" && y !== "" && !isNaN(x) && !isNaN(y)) ? x - y : x.localeCompare(y);
+      return ascending ? order : -order;
+    });
+    rows.forEach(function (row) { body.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`))
+
+// Function to render the report as a self contained html page
+func renderHTMLReport(report *surveyReport) (string, error) {
+	var out strings.Builder
+	err := htmlReport.Execute(&out, report)
+	return out.String(), err
+}
+
+// Function to render the report as markdown for engagement notes
+func renderMarkdownReport(report *surveyReport) (string, error) {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# Survey of %s\n\n", report.Host)
+	fmt.Fprintf(&out, "Finished %s, %d modules, %d failed, %d files looted.\n\n", report.Time, len(report.Modules), report.Failed, len(report.Loot))
+
+	if len(report.Processes) > 0 {
+		out.WriteString("## Processes\n\n| PID | PPID | User | Command |\n| --- | --- | --- | --- |\n")
+		for _, proc := range report.Processes {
+			fmt.Fprintf(&out, "| %d | %d | %s | %s |\n", proc.PID, proc.PPID, markdownCell(proc.Owner), markdownCell(proc.Command))
+		}
+		out.WriteString("\n")
+	}
+	if len(report.Connections) > 0 {
+		out.WriteString("## Connections\n\n| Protocol | Local Address | Foreign Address | State | PID/Program name |\n| --- | --- | --- | --- | --- |\n")
+		for _, conn := range report.Connections {
+			fmt.Fprintf(&out, "| %s | %s | %s | %s | %s |\n", conn.Protocol, conn.Local, conn.Remote, markdownCell(conn.State), markdownCell(conn.Process))
+		}
+		out.WriteString("\n")
+	}
+	if len(report.Loot) > 0 {
+		out.WriteString("## Loot\n\n| Path | Size | SHA256 |\n| --- | --- | --- |\n")
+		for _, loot := range report.Loot {
+			fmt.Fprintf(&out, "| [%s](%s) | %d | %s |\n", markdownCell(loot.Path), loot.Href, loot.Size, loot.SHA256)
+		}
+		out.WriteString("\n")
+	}
+
+	out.WriteString("## Modules\n\n")
+	for _, module := range report.Modules {
+		fmt.Fprintf(&out, "### %s\n\n", module.Name)
+		if module.Skipped != "" {
+			fmt.Fprintf(&out, "Skipped, %s\n\n", module.Skipped)
+		}
+		if module.Error != "" {
+			fmt.Fprintf(&out, "**Failed:** %s\n\n", module.Error)
+		}
+		if module.Output != "" {
+			// a fence longer than any run of backticks in the output can not be closed early by it
+			fence := "```"
+			for strings.Contains(module.Output, fence) {
+				fence += "`"
+			}
+			fmt.Fprintf(&out, "%s\n%s\n%s\n\n", fence, module.Output, fence)
+		}
+	}
+	return out.String(), nil
+}
+
+// Function to escape a value for a markdown table cell
+func markdownCell(value string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(value, "|", "\\|"), "\n", " ")
+}
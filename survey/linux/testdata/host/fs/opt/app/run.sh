@@ -0,0 +1,2 @@
+#!/bin/sh
+/opt/app/bin/app --config /opt/app/app.conf
@@ -0,0 +1,2 @@
+#!/bin/sh
+tar czf /var/backups/app.tgz /opt/app
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"common"
+	"common/fakesliver"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestDownloadToFile(t *testing.T) {
+	want, err := os.ReadFile("testdata/host/fs/etc/passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(want)
+	wantSum := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name         string
+		ranged       bool
+		partial      int
+		expectedSize int64
+		wantCalls    int
+		wantResumed  int64
+	}{
+		{name: "ranged", ranged: true, expectedSize: -1, wantCalls: 6},
+		{name: "ranged with listed size", ranged: true, expectedSize: int64(len(want)), wantCalls: 6},
+		{name: "implant ignores the range", expectedSize: -1, wantCalls: 1},
+		{name: "resume", ranged: true, partial: 100, expectedSize: -1, wantCalls: 4, wantResumed: 100},
+		{name: "resume when the implant ignores the range", partial: 100, expectedSize: -1, wantCalls: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func(size int64) { common.ChunkSize = size }(common.ChunkSize)
+			common.ChunkSize = 64
+
+			fake := newFakeSliver(t, "testdata/host")
+			fake.ranged = test.ranged
+			localPath := filepath.Join(t.TempDir(), "loot", "etc", "passwd")
+			if test.partial > 0 {
+				os.MkdirAll(filepath.Dir(localPath), 0777)
+				os.WriteFile(localPath+common.PartSuffix, want[:test.partial], 0644)
+			}
+
+			var result *common.TransferResult
+			fakesliver.CaptureOutput(t, func() {
+				result, err = common.DownloadToFile(makeRequest(fake.Sessions.Sessions[0]), fakesliver.Dial(t, fake), "/etc/passwd", localPath, test.expectedSize, true)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(localPath)
+			if err != nil || string(got) != string(want) {
+				t.Errorf("expected the downloaded file to match the target, err %v", err)
+			}
+			if _, err := os.Stat(localPath + common.PartSuffix); !os.IsNotExist(err) {
+				t.Errorf("expected the partial file to be renamed")
+			}
+			if result.Size != int64(len(want)) || result.SHA256 != wantSum || result.Ranged != test.ranged || result.Resumed != test.wantResumed {
+				t.Errorf("unexpected result %+v", result)
+			}
+			if calls := len(fake.Called("Download")); calls != test.wantCalls {
+				t.Errorf("expected %d downloads, got %d", test.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestDownloadToFileKeepsPartialFile(t *testing.T) {
+	defer func(size int64) { common.ChunkSize = size }(common.ChunkSize)
+	common.ChunkSize = 64
+
+	fake := newFakeSliver(t, "testdata/host")
+	fake.ranged = true
+	localPath := filepath.Join(t.TempDir(), "passwd")
+
+	// the implant goes quiet after two chunks
+	calls := 0
+	flaky := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if calls++; calls > 2 {
+			return status.Error(codes.Unknown, "implant timeout")
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	fakesliver.CaptureOutput(t, func() {
+		_, err := common.DownloadToFile(makeRequest(fake.Sessions.Sessions[0]), fakesliver.Dial(t, fake, flaky), "/etc/passwd", localPath, -1, false)
+		if !errors.Is(err, common.ErrTimeout) {
+			t.Errorf("expected a timeout, got %v", err)
+		}
+	})
+	if info, err := os.Stat(localPath + common.PartSuffix); err != nil || info.Size() != 128 {
+		t.Fatalf("expected the first two chunks to be kept, got %v %v", info, err)
+	}
+
+	var result *common.TransferResult
+	var err error
+	fakesliver.CaptureOutput(t, func() {
+		result, err = common.DownloadToFile(makeRequest(fake.Sessions.Sessions[0]), fakesliver.Dial(t, fake), "/etc/passwd", localPath, -1, false)
+	})
+	want, _ := os.ReadFile("testdata/host/fs/etc/passwd")
+	got, _ := os.ReadFile(localPath)
+	if err != nil || string(got) != string(want) || result.Resumed != 128 {
+		t.Errorf("expected the download to resume at 128 bytes, got %+v %v", result, err)
+	}
+}
+
+func TestDownloadToFileRestartsStalePart(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	fake.ranged = true
+	localPath := filepath.Join(t.TempDir(), "passwd")
+	want, _ := os.ReadFile("testdata/host/fs/etc/passwd")
+
+	// a .part of the same length as the real start of the file but from an older version of it
+	stale := []byte(strings.Repeat("x", 100))
+	if err := os.WriteFile(localPath+common.PartSuffix, stale, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var result *common.TransferResult
+	var err error
+	output := fakesliver.CaptureOutput(t, func() {
+		result, err = common.DownloadToFile(makeRequest(fake.Sessions.Sessions[0]), fakesliver.Dial(t, fake), "/etc/passwd", localPath, int64(len(want)), false)
+	})
+	got, _ := os.ReadFile(localPath)
+	if err != nil || string(got) != string(want) || result.Resumed != 0 {
+		t.Errorf("expected the stale .part to be downloaded again, got %+v %v", result, err)
+	}
+	if !strings.Contains(output, "downloading it from the start") {
+		t.Errorf("expected the restart to be reported:\n%s", output)
+	}
+	if calls := fake.Called("Download"); len(calls) < 2 {
+		t.Errorf("expected the end of the .part to be checked before the download restarted, got %v", calls)
+	}
+}
+
+func TestDownloadToFileDetectsChangedFile(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	fake.ranged = true
+	localPath := filepath.Join(t.TempDir(), "passwd")
+
+	var err error
+	fakesliver.CaptureOutput(t, func() {
+		_, err = common.DownloadToFile(makeRequest(fake.Sessions.Sessions[0]), fakesliver.Dial(t, fake), "/etc/passwd", localPath, 300, false)
+	})
+	if err == nil {
+		t.Fatal("expected a size mismatch to fail the download")
+	}
+	for _, path := range []string{localPath, localPath + common.PartSuffix} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", path)
+		}
+	}
+}
+
+func TestDownloadToFileMissing(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	localPath := filepath.Join(t.TempDir(), "loot", "nope")
+	_, err := common.DownloadToFile(makeRequest(fake.Sessions.Sessions[0]), fakesliver.Dial(t, fake), "/etc/nope", localPath, -1, false)
+	if !errors.Is(err, common.ErrNotFound) {
+		t.Errorf("expected ENOENT, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(localPath)); !os.IsNotExist(err) {
+		t.Errorf("expected no directories to be created for a missing file")
+	}
+}
+
+func TestDownloadToFileEncoders(t *testing.T) {
+	want, _ := os.ReadFile("testdata/host/fs/etc/passwd")
+	tests := []struct {
+		encoder  string
+		wantPath string
+	}{
+		{encoder: "raw", wantPath: "passwd"},
+		{encoder: "plain", wantPath: "passwd.plain"},
+	}
+	for _, test := range tests {
+		t.Run(test.encoder, func(t *testing.T) {
+			fake := newFakeSliver(t, "testdata/host")
+			fake.encoder = test.encoder
+			dir := t.TempDir()
+			var result *common.TransferResult
+			var err error
+			output := fakesliver.CaptureOutput(t, func() {
+				result, err = common.DownloadToFile(makeRequest(fake.Sessions.Sessions[0]), fakesliver.Dial(t, fake), "/etc/passwd", filepath.Join(dir, "passwd"), int64(len(want)), false)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(filepath.Join(dir, test.wantPath))
+			if err != nil || string(got) != string(want) {
+				t.Errorf("expected %s to hold the file, err %v", test.wantPath, err)
+			}
+			known := test.wantPath == "passwd"
+			if strings.Contains(output, "Unknown encoder") == known || (result.Encoder == "") != known {
+				t.Errorf("expected a warning only for an unknown encoder, got %q\n%s", result.Encoder, output)
+			}
+		})
+	}
+}
+
+func TestDownloadToFileDirectory(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	localPath := filepath.Join(t.TempDir(), "loot", "etc", "systemd")
+
+	var result *common.TransferResult
+	var err error
+	fakesliver.CaptureOutput(t, func() {
+		result, err = common.DownloadToFile(makeRequest(fake.Sessions.Sessions[0]), fakesliver.Dial(t, fake), "/etc/systemd", localPath, -1, false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wantFiles int
+	filepath.Walk("testdata/host/fs/etc/systemd", func(file string, info os.FileInfo, err error) error {
+		// links are not extracted, a link in a download could point anywhere on our side
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		wantFiles++
+		rel, _ := filepath.Rel("testdata/host/fs/etc/systemd", file)
+		want, _ := os.ReadFile(file)
+		if got, err := os.ReadFile(filepath.Join(localPath, rel)); err != nil || string(got) != string(want) {
+			t.Errorf("expected %s to be extracted, err %v", rel, err)
+		}
+		return nil
+	})
+	if !result.IsDir || result.Files != wantFiles || wantFiles == 0 {
+		t.Errorf("expected %d files extracted, got %+v", wantFiles, result)
+	}
+	if _, err := os.Stat(localPath + common.PartSuffix); !os.IsNotExist(err) {
+		t.Errorf("expected the archive to be removed once extracted")
+	}
+}
+
+func TestExtractDirectoryStaysInLoot(t *testing.T) {
+	dir := t.TempDir()
+	var archive bytes.Buffer
+	gzipWriter := gzip.NewWriter(&archive)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, name := range []string{"etc/cron.d/../../../../escaped", "etc/cron.d/job", "var/elsewhere"} {
+		tarWriter.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: 2})
+		tarWriter.Write([]byte("x\n"))
+	}
+	tarWriter.Close()
+	gzipWriter.Close()
+	archivePath := filepath.Join(dir, "cron.d.part")
+	os.WriteFile(archivePath, archive.Bytes(), 0644)
+
+	localPath := filepath.Join(dir, "loot", "etc", "cron.d")
+	var files int
+	var err error
+	fakesliver.CaptureOutput(t, func() { files, err = common.ExtractDirectory(archivePath, "/etc/cron.d", localPath) })
+	if err != nil || files != 2 {
+		t.Fatalf("expected 2 files extracted, got %d %v", files, err)
+	}
+	if _, err := os.Stat(filepath.Join(localPath, "job")); err != nil {
+		t.Errorf("expected job to be extracted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(localPath, "escaped")); err != nil {
+		t.Errorf("expected the ../ entry to be kept inside the directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be written outside the loot tree")
+	}
+}
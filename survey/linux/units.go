@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+)
+
+// the directories systemd loads unit files from, earlier ones override later ones by name
+var unitDirs = []string{"/etc/systemd/system", "/usr/lib/systemd/system", "/lib/systemd/system"}
+
+// unitFile is a parsed systemd unit, every value of every key by section in the order given
+type unitFile map[string]map[string][]string
+
+// Function to parse a systemd unit file. Like systemd an empty assignment clears what the key
+// was set to before, which is how drop-ins reset a list such as ExecStart
+//
+// :param: data string -> the content of the unit file
+// :return: unitFile -> the sections of the unit
+func parseUnitFile(data string) unitFile {
+	unit := unitFile{}
+	section := ""
+	var pending string
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if pending != "" {
+			line = pending + " " + line
+			pending = ""
+		}
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasSuffix(line, "\\") {
+			pending = strings.TrimSpace(strings.TrimSuffix(line, "\\"))
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			if unit[section] == nil {
+				unit[section] = map[string][]string{}
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section == "" {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if value == "" {
+			delete(unit[section], key)
+			continue
+		}
+		unit[section][key] = append(unit[section][key], value)
+	}
+	return unit
+}
+
+// Function to get the last value of a key, the one systemd uses for keys that are not a list
+func (u unitFile) get(section string, key string) string {
+	values := u[section][key]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// Function to get the command an Exec line runs, without the prefixes that change how systemd
+// runs it i.e. "-" to ignore a failure
+func execCommand(value string) string {
+	return strings.TrimLeft(value, "-@:+!|")
+}
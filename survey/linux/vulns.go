@@ -0,0 +1,494 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"common"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// the vulnerability database bundled with the survey, -vulndb points at a newer one
+//
+//go:embed vulndb.json
+var bundledVulnDB []byte
+
+// how much a candidate can be trusted, from the distro's own fixed version down to an upstream
+// kernel range the distro may well have backported the fix into
+const (
+	confidenceHigh   = "high"
+	confidenceMedium = "medium"
+	confidenceLow    = "low"
+)
+
+// the kinds of components a vulnerability is in
+const (
+	componentKernel  = "kernel"
+	componentPackage = "package"
+)
+
+// the upstream version and the distro's build number of a kernel release i.e. "6.8.0-45-generic"
+var kernelReleaseVersion = regexp.MustCompile(`^(\d+\.\d+(?:\.\d+)?)(?:-(\d+))?`)
+
+// the upstream version a distro kernel was built from, which its release does not give: Ubuntu's
+// signature i.e. "Ubuntu 5.15.0-91.101-generic 5.15.131" and Debian's i.e. "#1 SMP Debian 5.10.162-1"
+var kernelSignatures = []*regexp.Regexp{
+	regexp.MustCompile(`\bUbuntu \d+\.\d+\.\d+-\d+\.\d+\S* (\d+\.\d+\.\d+)`),
+	regexp.MustCompile(`#\S+ [^()]*\bDebian (\d+\.\d+\.\d+)`),
+}
+
+// vulnDatabase is the offline vulnerability database
+type vulnDatabase struct {
+	Updated         string          `json:"updated"`
+	Vulnerabilities []vulnerability `json:"vulnerabilities"`
+}
+
+// vulnerability is a privilege escalation in the kernel or a distro package and where it is fixed
+type vulnerability struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Component   string         `json:"component"`
+	Package     string         `json:"package,omitempty"`
+	Description string         `json:"description"`
+	Affected    []versionRange `json:"affected,omitempty"`
+	Distros     []distroFix    `json:"distros,omitempty"`
+	Note        string         `json:"note,omitempty"`
+	References  []string       `json:"references"`
+}
+
+// versionRange is the upstream versions that are affected, from introduced up to but not
+// including fixed
+type versionRange struct {
+	Introduced string `json:"introduced"`
+	Fixed      string `json:"fixed"`
+}
+
+// distroFix is the first version of a distro release's package or kernel with the fix
+type distroFix struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+	Fixed   string `json:"fixed"`
+}
+
+// vulnTarget is what the vulnerabilities are matched against
+type vulnTarget struct {
+	distro        string
+	distroVersion string
+	// the kernel version of the release i.e. "6.8.0", the version the distro gives it i.e.
+	// "6.8.0-45" and the upstream version it was built from i.e. "6.8.12"
+	kernel         string
+	kernelDistro   string
+	kernelUpstream string
+	// the installed packages and their versions, nil when they are not known
+	packages map[string]string
+}
+
+// vulnCandidate is a vulnerability the target looks to have
+type vulnCandidate struct {
+	vuln       vulnerability
+	installed  string
+	fixed      string
+	confidence string
+	basis      string
+}
+
+// Function to load the vulnerability database
+//
+// :param: path string -> a database to use instead of the bundled one, empty for the bundled one
+// :return: *vulnDatabase -> the database
+// :return: error -> if the database could not be read or an entry is not complete
+func loadVulnDB(path string) (*vulnDatabase, error) {
+	data := bundledVulnDB
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	var db vulnDatabase
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, fmt.Errorf("invalid vulnerability database: %w", err)
+	}
+	for _, vuln := range db.Vulnerabilities {
+		switch {
+		case vuln.ID == "":
+			return nil, errors.New("invalid vulnerability database: an entry has no id")
+		case vuln.Component != componentKernel && vuln.Component != componentPackage:
+			return nil, fmt.Errorf("invalid vulnerability database: %s has the unknown component %q", vuln.ID, vuln.Component)
+		case vuln.Component == componentPackage && vuln.Package == "":
+			return nil, fmt.Errorf("invalid vulnerability database: %s has no package", vuln.ID)
+		case len(vuln.Affected) == 0 && len(vuln.Distros) == 0:
+			return nil, fmt.Errorf("invalid vulnerability database: %s has no affected versions", vuln.ID)
+		}
+	}
+	return &db, nil
+}
+
+// Function to compare two versions the way dpkg does, which orders upstream versions such as
+// "5.10.102" or "1.9.5p2" the way their authors mean them too
+//
+// :param: a string -> a version i.e. "1:2.35-0ubuntu3.4"
+// :param: b string -> the version to compare it with
+// :return: int -> less than 0 if a is older, 0 if they are the same and more than 0 if a is newer
+func compareVersions(a string, b string) int {
+	epochA, upstreamA, revisionA := splitVersion(a)
+	epochB, upstreamB, revisionB := splitVersion(b)
+	if c := compareVersionPart(epochA, epochB); c != 0 {
+		return c
+	}
+	if c := compareVersionPart(upstreamA, upstreamB); c != 0 {
+		return c
+	}
+	return compareVersionPart(revisionA, revisionB)
+}
+
+// Function to split a debian version into its epoch, upstream version and revision
+func splitVersion(version string) (string, string, string) {
+	epoch := "0"
+	if before, after, ok := strings.Cut(version, ":"); ok {
+		epoch, version = before, after
+	}
+	revision := ""
+	if i := strings.LastIndex(version, "-"); i >= 0 {
+		version, revision = version[:i], version[i+1:]
+	}
+	return epoch, version, revision
+}
+
+// Function to compare one part of a version, runs of anything but digits are compared with
+// letters before everything else and "~" before even the end, then runs of digits by value
+func compareVersionPart(a string, b string) int {
+	order := func(c byte) int {
+		switch {
+		case c == '~':
+			return -1
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			return int(c)
+		}
+		return int(c) + 256
+	}
+	for a != "" || b != "" {
+		for (a != "" && !isDigit(a[0])) || (b != "" && !isDigit(b[0])) {
+			ca, cb := 0, 0
+			if a != "" && !isDigit(a[0]) {
+				ca = order(a[0])
+			}
+			if b != "" && !isDigit(b[0]) {
+				cb = order(b[0])
+			}
+			if ca != cb {
+				return ca - cb
+			}
+			a, b = a[1:], b[1:]
+		}
+		var digitsA, digitsB string
+		digitsA, a = leadingDigits(a)
+		digitsB, b = leadingDigits(b)
+		digitsA, digitsB = strings.TrimLeft(digitsA, "0"), strings.TrimLeft(digitsB, "0")
+		if len(digitsA) != len(digitsB) {
+			return len(digitsA) - len(digitsB)
+		}
+		if digitsA != digitsB {
+			return strings.Compare(digitsA, digitsB)
+		}
+	}
+	return 0
+}
+
+// Function to determine if a byte is an ascii digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Function to split the digits off the front of a string
+func leadingDigits(value string) (string, string) {
+	i := 0
+	for i < len(value) && isDigit(value[i]) {
+		i++
+	}
+	return value[:i], value[i:]
+}
+
+// Function to get the upstream part of a package version i.e. "1.9.9" from "1.9.9-1ubuntu2.1"
+func upstreamVersion(version string) string {
+	_, upstream, _ := splitVersion(version)
+	return upstream
+}
+
+// Function to parse /etc/os-release
+//
+// :param: data string -> the content of /etc/os-release
+// :return: map[string]string -> every variable with the quotes taken off its value
+func parseOSRelease(data string) map[string]string {
+	release := map[string]string{}
+	for _, line := range configLines(data) {
+		key, value, ok := strings.Cut(line, "=")
+		if ok {
+			release[key] = strings.Trim(value, `"'`)
+		}
+	}
+	return release
+}
+
+// Function to parse the dpkg status file into the packages that are installed
+//
+// :param: data string -> the content of /var/lib/dpkg/status
+// :return: map[string]string -> the version of every installed package by name
+func parseDpkgStatus(data string) map[string]string {
+	packages := map[string]string{}
+	for _, stanza := range strings.Split(data, "\n\n") {
+		fields := map[string]string{}
+		for _, line := range strings.Split(stanza, "\n") {
+			if key, value, ok := strings.Cut(line, ": "); ok && !strings.HasPrefix(line, " ") {
+				fields[key] = value
+			}
+		}
+		if fields["Package"] != "" && strings.HasSuffix(fields["Status"], " installed") {
+			packages[fields["Package"]] = fields["Version"]
+		}
+	}
+	return packages
+}
+
+// Function to get the upstream version of a kernel release and the version the distro gives
+// it, the build number the distro added in front of the flavour
+//
+// :param: release string -> the kernel release i.e. "6.8.0-45-generic"
+// :return: string -> the upstream version i.e. "6.8.0"
+// :return: string -> the distro version i.e. "6.8.0-45", the upstream version when there is none
+func kernelVersions(release string) (string, string) {
+	match := kernelReleaseVersion.FindStringSubmatch(release)
+	if match == nil {
+		return "", ""
+	}
+	if match[2] == "" {
+		return match[1], match[1]
+	}
+	return match[1], match[1] + "-" + match[2]
+}
+
+// Function to get the upstream version a distro kernel was built from, Ubuntu and Debian keep the
+// version of a release i.e. "5.15.0-91-generic" the same for every upstream point release
+//
+// :param: version string -> /proc/version or /proc/version_signature
+// :return: string -> the upstream version i.e. "5.15.131", empty if the distro does not say
+func kernelSignatureVersion(version string) string {
+	for _, signature := range kernelSignatures {
+		if match := signature.FindStringSubmatch(version); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// Function to find the fix of a vulnerability for the distro release of the target
+func (v vulnerability) distroFix(target vulnTarget) *distroFix {
+	for _, fix := range v.Distros {
+		if fix.ID == target.distro && fix.Version == target.distroVersion {
+			return &fix
+		}
+	}
+	return nil
+}
+
+// Function to find the upstream range a version is in
+func (v vulnerability) affectedRange(version string) *versionRange {
+	for _, affected := range v.Affected {
+		if compareVersions(version, affected.Introduced) >= 0 && compareVersions(version, affected.Fixed) < 0 {
+			return &affected
+		}
+	}
+	return nil
+}
+
+// Function to match a vulnerability against the target. The fixed version of the target's
+// distro release is trusted over the upstream ranges, a distro kernel only has a fix for the
+// upstream version it was built from and anything else is matched against the upstream ranges
+//
+// :param: target vulnTarget -> the kernel, distro and packages of the target
+// :return: *vulnCandidate -> the candidate, nil if the target does not look affected or can not be checked
+func (v vulnerability) match(target vulnTarget) *vulnCandidate {
+	fix := v.distroFix(target)
+	distro := target.distro + " " + target.distroVersion
+	switch v.Component {
+	case componentKernel:
+		if target.kernel == "" {
+			return nil
+		}
+		if fix != nil {
+			fixedUpstream, _ := kernelVersions(fix.Fixed)
+			if fixedUpstream == target.kernel {
+				if compareVersions(target.kernelDistro, fix.Fixed) >= 0 {
+					return nil
+				}
+				return &vulnCandidate{vuln: v, installed: target.kernelDistro, fixed: fix.Fixed, confidence: confidenceHigh, basis: "fixed in " + distro}
+			}
+		}
+		if affected := v.affectedRange(target.kernelUpstream); affected != nil {
+			return &vulnCandidate{vuln: v, installed: target.kernelDistro, fixed: affected.Fixed, confidence: confidenceLow, basis: "upstream range, the distro may have backported the fix"}
+		}
+	case componentPackage:
+		installed, ok := target.packages[v.Package]
+		if !ok {
+			return nil
+		}
+		if fix != nil {
+			if compareVersions(installed, fix.Fixed) >= 0 {
+				return nil
+			}
+			return &vulnCandidate{vuln: v, installed: installed, fixed: fix.Fixed, confidence: confidenceHigh, basis: "fixed in " + distro}
+		}
+		if affected := v.affectedRange(upstreamVersion(installed)); affected != nil {
+			return &vulnCandidate{vuln: v, installed: installed, fixed: affected.Fixed, confidence: confidenceMedium, basis: "upstream range, the distro may have backported the fix"}
+		}
+	}
+	return nil
+}
+
+// Function to match the vulnerability database against the target
+//
+// :param: db *vulnDatabase -> the vulnerability database
+// :param: target vulnTarget -> the kernel, distro and packages of the target
+// :return: []vulnCandidate -> the candidates, the most certain first
+func matchVulnerabilities(db *vulnDatabase, target vulnTarget) []vulnCandidate {
+	rank := map[string]int{confidenceHigh: 0, confidenceMedium: 1, confidenceLow: 2}
+	var candidates []vulnCandidate
+	for _, vuln := range db.Vulnerabilities {
+		if candidate := vuln.match(target); candidate != nil {
+			candidates = append(candidates, *candidate)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if rank[candidates[i].confidence] != rank[candidates[j].confidence] {
+			return rank[candidates[i].confidence] < rank[candidates[j].confidence]
+		}
+		return candidates[i].vuln.ID < candidates[j].vuln.ID
+	})
+	return candidates
+}
+
+// Function to find out what the vulnerabilities are matched against: the kernel from
+// /proc/version (and Ubuntu's /proc/version_signature), the distro from /etc/os-release and the
+// packages from the dpkg status file
+//
+// :return: vulnTarget -> whatever of the target could be found out
+// :return: error -> if the session went away
+func (s *survey) vulnTarget() (vulnTarget, error) {
+	var target vulnTarget
+	version, err := readRemoteFile(s.session, s.rpc, "/proc/version")
+	if errors.Is(err, common.ErrSessionGone) {
+		return target, err
+	}
+	if err != nil {
+		printRPCError(err, "/proc/version")
+	} else {
+		target.kernel, target.kernelDistro = kernelVersions(parseKernelRelease(version))
+		target.kernelUpstream = kernelSignatureVersion(string(version))
+	}
+	if target.kernel != "" && target.kernelUpstream == "" {
+		// only Ubuntu has the file, anywhere else not finding it is expected
+		signature, err := readRemoteFile(s.session, s.rpc, "/proc/version_signature")
+		if errors.Is(err, common.ErrSessionGone) {
+			return target, err
+		}
+		target.kernelUpstream = kernelSignatureVersion(string(signature))
+	}
+	if target.kernelUpstream == "" {
+		target.kernelUpstream = target.kernel
+	}
+
+	osRelease, err := s.fetchLoot("/etc/os-release")
+	if errors.Is(err, common.ErrSessionGone) {
+		return target, err
+	}
+	release := parseOSRelease(string(osRelease))
+	target.distro, target.distroVersion = release["ID"], release["VERSION_ID"]
+
+	// rpm keeps its packages in a database, only dpkg's are plain text
+	if s.lookupListed("/var/lib/dpkg/status") != nil {
+		status, err := s.fetchLoot("/var/lib/dpkg/status")
+		if errors.Is(err, common.ErrSessionGone) {
+			return target, err
+		}
+		if status != nil {
+			target.packages = parseDpkgStatus(string(status))
+		}
+	}
+	return target, nil
+}
+
+// Function to match the kernel and the distro packages of the target against the offline
+// vulnerability database and print the privilege escalations it may have, with how much each
+// one can be trusted and where to read about it
+//
+// :return: error -> if the database could not be loaded or the session went away
+func (s *survey) vulnerabilities() error {
+	makeBorder("Vulnerability Candidates")
+	db, err := loadVulnDB(s.vulnDB)
+	if err != nil {
+		fmt.Println("[!]", err)
+		return err
+	}
+	target, err := s.vulnTarget()
+	if err != nil {
+		return err
+	}
+
+	distro := "unknown distro"
+	if target.distro != "" {
+		distro = strings.TrimSpace(target.distro + " " + target.distroVersion)
+	}
+	kernel := target.kernelDistro
+	if target.kernelUpstream != target.kernel {
+		kernel += " (upstream " + target.kernelUpstream + ")"
+	}
+	fmt.Printf("[*] Kernel %s, %s, %d installed packages, matched against %d vulnerabilities updated %s\n",
+		kernel, distro, len(target.packages), len(db.Vulnerabilities), db.Updated)
+	if target.kernel == "" {
+		fmt.Println("[-] The kernel release is unknown, kernel vulnerabilities are not checked")
+	}
+	if target.packages == nil {
+		fmt.Println("[-] /var/lib/dpkg/status was not collected, package vulnerabilities are not checked")
+	}
+
+	candidates := matchVulnerabilities(db, target)
+	if len(candidates) == 0 {
+		fmt.Println("[*] Nothing in the database matches the target")
+		return nil
+	}
+	tw := table.NewWriter()
+	tw.AppendHeader(table.Row{"ID", "Name", "Component", "Installed", "Fixed", "Confidence", "Basis", "Note", "References"})
+	high := 0
+	for _, candidate := range candidates {
+		component := candidate.vuln.Component
+		if component == componentPackage {
+			component = candidate.vuln.Package
+		}
+		tw.AppendRow(table.Row{candidate.vuln.ID, candidate.vuln.Name, component, candidate.installed, candidate.fixed,
+			candidate.confidence, candidate.basis, candidate.vuln.Note, strings.Join(candidate.vuln.References, "\n")})
+		if candidate.confidence == confidenceHigh {
+			high++
+		}
+	}
+	fmt.Printf("%s\n", tw.Render())
+	for _, candidate := range candidates {
+		if candidate.confidence == confidenceHigh {
+			fmt.Printf("[!] %s %s: %s\n", candidate.vuln.ID, candidate.vuln.Name, candidate.vuln.Description)
+		}
+	}
+	fmt.Printf("[*] %d candidates, %d with high confidence\n", len(candidates), high)
+	return nil
+}
+
+// Function to plan matching the vulnerability database, /proc/version is read and the dpkg status
+// file downloaded when the target has one
+func planVulnerabilities() []plannedRPC {
+	rpcs := append([]plannedRPC{planDownload("/proc/version", false), planDownload("/proc/version_signature", true)}, planDownloadAll([]string{"/etc/os-release"})...)
+	return append(rpcs, planLs("/var/lib/dpkg", false), planDownload("/var/lib/dpkg/status", true))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"common/fakesliver"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.9.9-1ubuntu2.1", "1.9.9-1ubuntu2.2", -1},
+		{"1.9.5p2", "1.9.5", 1},
+		{"1.9.5p1", "1.9.5p2", -1},
+		{"5.10.102", "5.10.46", 1},
+		{"2.35-0ubuntu3.4", "2.35-0ubuntu3.4", 0},
+		{"1:1.0", "2.0", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"0.105", "121", -1},
+		{"6.8.0-45", "6.8.0-49", -1},
+		{"2.37.1.1+18.04", "2.37.1", 1},
+	}
+	for _, test := range tests {
+		got := compareVersions(test.a, test.b)
+		if (got < 0 && test.want >= 0) || (got > 0 && test.want <= 0) || (got == 0 && test.want != 0) {
+			t.Errorf("compareVersions(%q, %q) = %d want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestKernelVersions(t *testing.T) {
+	for release, want := range map[string][2]string{
+		"6.8.0-45-generic":       {"6.8.0", "6.8.0-45"},
+		"5.10.0-28-amd64":        {"5.10.0", "5.10.0-28"},
+		"6.5.6-300.fc39.x86_64":  {"6.5.6", "6.5.6-300"},
+		"4.19.128-microsoft-std": {"4.19.128", "4.19.128"},
+		"5.15.90.1-microsoft":    {"5.15.90", "5.15.90"},
+	} {
+		upstream, distro := kernelVersions(release)
+		if upstream != want[0] || distro != want[1] {
+			t.Errorf("kernelVersions(%q) = %q, %q want %q, %q", release, upstream, distro, want[0], want[1])
+		}
+	}
+}
+
+func TestKernelSignatureVersion(t *testing.T) {
+	for version, want := range map[string]string{
+		"Ubuntu 5.15.0-91.101-generic 5.15.131\n": "5.15.131",
+		"Linux version 5.15.0-91-generic (buildd@lcy02-amd64-045) (gcc (Ubuntu 11.4.0-1ubuntu1~22.04) 11.4.0, GNU ld (GNU Binutils for Ubuntu) 2.38) #101-Ubuntu SMP Tue Nov 14 13:30:08 UTC 2023 (Ubuntu 5.15.0-91.101-generic 5.15.131)": "5.15.131",
+		"Linux version 5.10.0-21-amd64 (debian-kernel@lists.debian.org) (gcc-10 (Debian 10.2.1-6) 10.2.1 20210110, GNU ld (GNU Binutils for Debian) 2.35.2) #1 SMP Debian 5.10.162-1 (2023-01-21)":                                         "5.10.162",
+		"Linux version 6.8.0-45-generic (buildd@lcy02-amd64-075) (x86_64-linux-gnu-gcc-12 (Ubuntu 12.3.0-1ubuntu1~22.04) 12.3.0, GNU ld (GNU Binutils for Ubuntu) 2.38) #45~22.04.1-Ubuntu SMP PREEMPT_DYNAMIC":                            "",
+		"Linux version 6.5.6-300.fc39.x86_64 (mockbuild@koji) (gcc (GCC) 13.2.1) #1 SMP PREEMPT_DYNAMIC":                                                                                                                                   "",
+	} {
+		if got := kernelSignatureVersion(version); got != want {
+			t.Errorf("kernelSignatureVersion(%q) = %q want %q", version, got, want)
+		}
+	}
+}
+
+func TestMatchVulnerabilities(t *testing.T) {
+	db, err := loadVulnDB("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		target vulnTarget
+		want   map[string]string
+	}{
+		{
+			name:   "distro kernel fixed",
+			target: vulnTarget{distro: "ubuntu", distroVersion: "20.04", kernel: "5.4.0", kernelDistro: "5.4.0-72", kernelUpstream: "5.4.0"},
+			want:   map[string]string{"CVE-2021-22555": confidenceLow, "CVE-2024-1086": confidenceLow},
+		},
+		{
+			name:   "distro kernel not fixed",
+			target: vulnTarget{distro: "ubuntu", distroVersion: "20.04", kernel: "5.4.0", kernelDistro: "5.4.0-70", kernelUpstream: "5.4.0"},
+			want:   map[string]string{"CVE-2021-3493": confidenceHigh, "CVE-2021-22555": confidenceLow, "CVE-2024-1086": confidenceLow},
+		},
+		{
+			name:   "hwe kernel without a distro fix for its version",
+			target: vulnTarget{distro: "ubuntu", distroVersion: "20.04", kernel: "5.11.0", kernelDistro: "5.11.0-27", kernelUpstream: "5.11.0"},
+			want:   map[string]string{"CVE-2022-0847": confidenceLow, "CVE-2021-22555": confidenceLow, "CVE-2024-1086": confidenceLow},
+		},
+		{
+			name:   "distro kernel built from a fixed upstream",
+			target: vulnTarget{distro: "ubuntu", distroVersion: "22.04", kernel: "5.15.0", kernelDistro: "5.15.0-91", kernelUpstream: "5.15.131"},
+			want:   map[string]string{"CVE-2024-1086": confidenceLow},
+		},
+		{
+			name:   "distro kernel built from the latest upstream",
+			target: vulnTarget{distro: "debian", distroVersion: "11", kernel: "5.10.0", kernelDistro: "5.10.0-28", kernelUpstream: "5.10.209"},
+			want:   map[string]string{},
+		},
+		{
+			name: "packages",
+			target: vulnTarget{distro: "debian", distroVersion: "11", packages: map[string]string{
+				"sudo": "1.9.5p2-3", "policykit-1": "0.105-31+deb11u1", "snapd": "2.30",
+			}},
+			want: map[string]string{"CVE-2023-22809": confidenceHigh, "CVE-2019-7304": confidenceMedium},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := map[string]string{}
+			for _, candidate := range matchVulnerabilities(db, test.target) {
+				got[candidate.vuln.ID] = candidate.confidence
+			}
+			if len(got) != len(test.want) {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+			for id, confidence := range test.want {
+				if got[id] != confidence {
+					t.Errorf("expected %s with %s confidence, got %v", id, confidence, got)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadVulnDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vulndb.json")
+	os.WriteFile(path, []byte(`{"updated": "2030-01-01", "vulnerabilities": [{"id": "CVE-2030-0001", "component": "package", "affected": [{"introduced": "1", "fixed": "2"}]}]}`), 0644)
+	if _, err := loadVulnDB(path); err == nil || !strings.Contains(err.Error(), "has no package") {
+		t.Errorf("expected the entry without a package to be refused, got %v", err)
+	}
+	os.WriteFile(path, []byte(`{"updated": "2030-01-01", "vulnerabilities": [{"id": "CVE-2030-0001", "component": "package", "package": "sudo", "affected": [{"introduced": "1", "fixed": "2"}]}]}`), 0644)
+	if db, err := loadVulnDB(path); err != nil || db.Updated != "2030-01-01" || len(db.Vulnerabilities) != 1 {
+		t.Errorf("expected the database given to replace the bundled one, got %v %v", db, err)
+	}
+}
+
+func TestVulnerabilitiesModule(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	s := newTestSurvey(t, fake, fake.Sessions.Sessions[0])
+	output := fakesliver.CaptureOutput(t, func() {
+		if err := s.vulnerabilities(); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, want := range []string{
+		"[*] Kernel 6.8.0-45 (upstream 6.8.12), ubuntu 22.04, 4 installed packages",
+		"[!] CVE-2023-4911 Looney Tunables",
+		"[!] CVE-2023-22809 sudoedit editor arguments",
+		"[!] CVE-2024-48990 needrestart PYTHONPATH",
+		"https://www.qualys.com/2023/10/03/cve-2023-4911/looney-tunables-local-privilege-escalation-glibc-ld-so.txt",
+		"[*] 3 candidates, 3 with high confidence",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "CVE-2021-4034") {
+		t.Errorf("expected the fixed policykit-1 to not be a candidate\n%s", output)
+	}
+}
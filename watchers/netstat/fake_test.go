@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"common/fakesliver"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+)
+
+// fakeSliver is the shared fake sliver server with the rpcs the netstat watcher makes, from the
+// fixture directory it also reads:
+//
+//	netstat.json   sliverpb.Netstat returned by Netstat
+type fakeSliver struct {
+	*fakesliver.Server
+
+	netstat *sliverpb.Netstat
+}
+
+// Function to load a fake sliver server from a fixture directory
+func newFakeSliver(t *testing.T, fixtures string) *fakeSliver {
+	t.Helper()
+	fake := &fakeSliver{
+		Server:  fakesliver.New(t, fixtures),
+		netstat: &sliverpb.Netstat{},
+	}
+	fakesliver.LoadFixture(t, filepath.Join(fixtures, "netstat.json"), fake.netstat)
+	return fake
+}
+
+func (f *fakeSliver) Netstat(ctx context.Context, req *sliverpb.NetstatReq) (*sliverpb.Netstat, error) {
+	f.Record("Netstat", "")
+	return f.netstat, nil
+}
+
+// Ls only ever lists the working directory for the implants timezone
+func (f *fakeSliver) Ls(ctx context.Context, req *sliverpb.LsReq) (*sliverpb.Ls, error) {
+	f.Record("Ls", req.Path)
+	return &sliverpb.Ls{Path: "/root", Exists: true, Timezone: "UTC"}, nil
+}
@@ -5,7 +5,14 @@ go 1.22.7
 toolchain go1.22.9
 
 require (
-	github.com/bishopfox/sliver v1.15.16 // indirect
+	common v0.0.0
+	github.com/bishopfox/sliver v1.15.16
+	github.com/jedib0t/go-pretty/v6 v6.6.1
+	google.golang.org/grpc v1.68.0
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
 	github.com/desertbit/closer/v3 v3.1.2 // indirect
 	github.com/desertbit/columnize v2.1.0+incompatible // indirect
 	github.com/desertbit/go-shlex v0.1.1 // indirect
@@ -15,7 +22,6 @@ require (
 	github.com/golang/protobuf v1.5.4 // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
-	github.com/jedib0t/go-pretty/v6 v6.6.1 // indirect
 	github.com/mattn/go-colorable v0.1.8 // indirect
 	github.com/mattn/go-isatty v0.0.13 // indirect
 	github.com/mattn/go-runewidth v0.0.15 // indirect
@@ -24,6 +30,6 @@ require (
 	golang.org/x/sys v0.25.0 // indirect
 	golang.org/x/text v0.18.0 // indirect
 	google.golang.org/genproto v0.0.0-20210722135532-667f2b7c528f // indirect
-	google.golang.org/grpc v1.68.0 // indirect
-	google.golang.org/protobuf v1.34.2 // indirect
 )
+
+replace common => ../../common
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"common"
+	"common/fakesliver"
+)
+
+func TestConnectionsPoll(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditor, err := common.NewAuditLogger(auditPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auditor.Close()
+	requestPacer, err := common.NewPacer(0, 0, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpc := fakesliver.Dial(t, fake, requestPacer.Interceptor, auditor.Interceptor)
+	session := fake.Sessions.Sessions[0]
+
+	// two polls, the same as two passes of the watcher loop
+	var output string
+	for poll := 0; poll < 2; poll++ {
+		output = fakesliver.CaptureOutput(t, func() { getConnections(session, rpc) })
+	}
+
+	for _, want := range []string{"Connections", "0.0.0.0:22", "268/sshd", "10.10.20.5:8888", "127.0.0.1:5432"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q:\n%s", want, output)
+		}
+	}
+	if calls := fake.Called("Netstat"); len(calls) != 2 {
+		t.Errorf("expected two Netstat calls, got %d", len(calls))
+	}
+
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected two audit entries, got %d", len(lines))
+	}
+	entry := common.AuditEntry{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Method != "/rpcpb.SliverRPC/Netstat" || entry.Session != session.ID || entry.Status != "ok" || entry.BytesReceived == 0 {
+		t.Errorf("unexpected audit entry %+v", entry)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"common/fakesliver"
+	"github.com/bishopfox/sliver/protobuf/sliverpb"
+)
+
+// fakeSliver is the shared fake sliver server with the rpcs the ps watcher makes, from the
+// fixture directory it also reads:
+//
+//	ps.json        sliverpb.Ps returned by Ps
+type fakeSliver struct {
+	*fakesliver.Server
+
+	ps *sliverpb.Ps
+}
+
+// Function to load a fake sliver server from a fixture directory
+func newFakeSliver(t *testing.T, fixtures string) *fakeSliver {
+	t.Helper()
+	fake := &fakeSliver{
+		Server: fakesliver.New(t, fixtures),
+		ps:     &sliverpb.Ps{},
+	}
+	fakesliver.LoadFixture(t, filepath.Join(fixtures, "ps.json"), fake.ps)
+	return fake
+}
+
+func (f *fakeSliver) Ps(ctx context.Context, req *sliverpb.PsReq) (*sliverpb.Ps, error) {
+	f.Record("Ps", "")
+	return f.ps, nil
+}
+
+// Ls only ever lists the working directory for the implants timezone
+func (f *fakeSliver) Ls(ctx context.Context, req *sliverpb.LsReq) (*sliverpb.Ls, error) {
+	f.Record("Ls", req.Path)
+	return &sliverpb.Ls{Path: "/root", Exists: true, Timezone: "UTC"}, nil
+}
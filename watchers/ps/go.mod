@@ -5,9 +5,11 @@ go 1.22.7
 toolchain go1.22.9
 
 require (
+	common v0.0.0
 	github.com/bishopfox/sliver v1.15.16
 	github.com/jedib0t/go-pretty/v6 v6.6.1
 	google.golang.org/grpc v1.68.0
+	google.golang.org/protobuf v1.34.2
 )
 
 require (
@@ -27,5 +29,6 @@ require (
 	golang.org/x/sys v0.25.0 // indirect
 	golang.org/x/text v0.18.0 // indirect
 	google.golang.org/genproto v0.0.0-20210722135532-667f2b7c528f // indirect
-	google.golang.org/protobuf v1.34.2 // indirect
 )
+
+replace common => ../../common
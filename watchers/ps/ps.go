@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -12,6 +13,7 @@ import (
 	"strings"
 	"time"
 
+	"common"
 	"github.com/bishopfox/sliver/client/assets"
 	"github.com/bishopfox/sliver/client/console"
 	"github.com/bishopfox/sliver/client/transport"
@@ -51,38 +53,46 @@ func makeBorder(header string) {
 // Function to make the connection from our device to the sliver server
 //
 // :param: configPath *string -> the path to our client config that will auth us to the sliver server
+// :param: interceptors ...grpc.UnaryClientInterceptor -> run on every call made through the returned rpc client
 // :return: *clientpb.Sessions -> the active sliver session that we will be interacting with
 // :return: rpcpb.SliverRPCClient -> the rpc client object that will allow us to make command requests to the server
 // :return: *grpc.ClientConn -> the connection object to the sliver server
-func makeConnection(configPath *string) (*clientpb.Sessions, rpcpb.SliverRPCClient, *grpc.ClientConn) {
+// :return: error -> if the config can not be read, the server can not be reached or has no sessions
+func makeConnection(configPath *string, interceptors ...grpc.UnaryClientInterceptor) (*clientpb.Sessions, rpcpb.SliverRPCClient, *grpc.ClientConn, error) {
 	// load the client configuration from the filesystem
 	config, err := assets.ReadConfig(*configPath)
 	if err != nil {
-		fmt.Println("[!] Failed to read config:", err)
+		return nil, nil, nil, fmt.Errorf("failed to read config: %v", err)
 	}
 	// connect to the server
-	rpc, ln, err := transport.MTLSConnect(config)
-
+	_, ln, err := transport.MTLSConnect(config)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, nil, fmt.Errorf("failed to connect to sliver server: %v", err)
 	}
+	rpc := rpcpb.NewSliverRPCClient(common.NewInterceptedConn(ln, interceptors...))
 	log.Println("[*] Connected to sliver server")
 
 	// get active sliver sessions connected to the server
 	sessions, err := rpc.GetSessions(context.Background(), &commonpb.Empty{})
 	if err != nil {
-		fmt.Println("[!] Failed to get sessions", err)
+		ln.Close()
+		return nil, nil, nil, fmt.Errorf("failed to get sessions: %v", err)
+	}
+	if len(sessions.Sessions) == 0 {
+		ln.Close()
+		return nil, nil, nil, errors.New("no active sessions")
 	}
-	return sessions, rpc, ln
+	return sessions, rpc, ln, nil
 }
 
-func processList(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient) {
+func processList(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient) error {
 	makeBorder("Process List")
 	ps, err := rpc.Ps(context.Background(), &sliverpb.PsReq{
 		Request: makeRequest(targetSession),
 	})
-	if err != nil {
-		fmt.Println(err)
+	if err := common.NewRPCError("ps", "", err, ps.GetResponse()); err != nil {
+		fmt.Println("[!]", err)
+		return err
 	}
 	tw := table.NewWriter()
 	tw.AppendHeader(table.Row{"PPID", "PID", "User", "Command"})
@@ -124,6 +134,7 @@ func processList(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient) {
 	for _, line := range strings.Split(tw.Render(), "\n") {
 		fmt.Printf("%s\n", strings.TrimSpace(line)) // Trim spaces for each line
 	}
+	return nil
 }
 
 func procRow(proc *commonpb.Process, cmdLine bool) table.Row {
@@ -152,6 +163,21 @@ func procRow(proc *commonpb.Process, cmdLine bool) table.Row {
 	return row
 }
 
+// Function to list the implants working directory, with -quiet-hours the pacer holds every
+// request until a listing has told it the implants timezone and the watcher lists nothing else
+//
+// :param: targetSession *clientpb.Session -> the target session we are interacting with
+// :param: rpc rpcpb.SliverRPCClient -> the sliver rpc client
+// :return: error -> if the listing failed
+func learnTimezone(targetSession *clientpb.Session, rpc rpcpb.SliverRPCClient) error {
+	fmt.Println("[*] Listing the working directory to learn the implant timezone for -quiet-hours")
+	ls, err := rpc.Ls(context.Background(), &sliverpb.LsReq{
+		Path:    ".",
+		Request: makeRequest(targetSession),
+	})
+	return common.NewRPCError("ls", ".", err, ls.GetResponse())
+}
+
 func clearScreen() {
 	cmd := exec.Command("clear") // 'clear' command for Linux/Mac
 	cmd.Stdout = os.Stdout
@@ -212,17 +238,74 @@ func handleMultipleSessions(sessions []*clientpb.Session) *clientpb.Session {
 func main() {
 	var configPath string
 	var sleepTime int
+	var delay time.Duration
+	var jitter time.Duration
+	var rate int
+	var quietHours string
+	var auditLog string
+	var capturePath string
+	var replayPath string
+	var retries int
+	var retryWait time.Duration
 	flag.StringVar(&configPath, "config", "", "path to sliver client config file")
 	flag.IntVar(&sleepTime, "sleep", 60, "the time to sleep in between process list polling")
+	flag.DurationVar(&delay, "delay", 0, "minimum time between two requests to the implant i.e. 5s")
+	flag.DurationVar(&jitter, "jitter", 0, "up to this much random time is added to every delay and sleep i.e. 10s")
+	flag.IntVar(&rate, "rate", 0, "maximum number of requests to the implant per minute, 0 for no limit")
+	flag.StringVar(&quietHours, "quiet-hours", "", "comma separated windows in implant time to suspend polling during i.e. 22:00-06:00, the working directory is listed first to learn the implant timezone")
+	flag.StringVar(&auditLog, "audit-log", "sliver-audit.jsonl", "file every rpc issued is appended to as a json line")
+	flag.StringVar(&capturePath, "capture", "", "write every rpc and its reply, with secrets redacted, to this capture archive")
+	flag.StringVar(&replayPath, "replay", "", "answer every rpc from this capture archive instead of the sliver server")
+	flag.IntVar(&retries, "retries", 2, "how many times an rpc that timed out or hit a server error is sent again")
+	flag.DurationVar(&retryWait, "retry-wait", 5*time.Second, "wait before the first retry, doubled for every retry after it")
 	flag.Parse()
 
-	if configPath == "" {
+	requestPacer, err := common.NewPacer(delay, jitter, rate, quietHours)
+	if err != nil {
+		fmt.Println("[!]", err)
+		os.Exit(1)
+	}
+
+	if configPath == "" && replayPath == "" {
 		fmt.Println("[!] Specify a client config to load")
 		os.Exit(1)
 	}
 
-	sessions, rpc, ln := makeConnection(&configPath)
-	defer ln.Close()
+	auditor, err := common.NewAuditLogger(auditLog, configPath)
+	if err != nil {
+		fmt.Println("[!] Failed to open audit log:", err)
+		os.Exit(1)
+	}
+	defer auditor.Close()
+	interceptors := []grpc.UnaryClientInterceptor{common.NewRetrier(retries, retryWait).Interceptor, requestPacer.Interceptor, auditor.Interceptor}
+
+	if capturePath != "" {
+		recorder, err := common.NewCaptureRecorder(capturePath)
+		if err != nil {
+			fmt.Println("[!] Failed to create capture archive:", err)
+			os.Exit(1)
+		}
+		defer recorder.Close()
+		interceptors = append(interceptors, recorder.Interceptor)
+	}
+
+	var sessions *clientpb.Sessions
+	var rpc rpcpb.SliverRPCClient
+	if replayPath != "" {
+		sessions, rpc, err = common.MakeReplayConnection(replayPath, interceptors...)
+		if err != nil {
+			fmt.Println("[!] Failed to replay capture:", err)
+			os.Exit(1)
+		}
+	} else {
+		var ln *grpc.ClientConn
+		sessions, rpc, ln, err = makeConnection(&configPath, interceptors...)
+		if err != nil {
+			fmt.Println("[!]", err)
+			os.Exit(1)
+		}
+		defer ln.Close()
+	}
 
 	var targetSession *clientpb.Session
 	//fmt.Println(sessions)
@@ -237,10 +320,25 @@ func main() {
 
 	//targetSession := sessions.Sessions[0]
 
+	if requestPacer.Holding() {
+		if err := learnTimezone(targetSession, rpc); err != nil {
+			fmt.Println("[!] Failed to learn the implant timezone:", err)
+			// not found exits 0 for the survey, but a watcher that never polled has failed
+			code := common.ClassifyError(err).ExitCode()
+			if code == 0 {
+				code = 1
+			}
+			os.Exit(code)
+		}
+	}
+
 	//fileTag := fmt.Sprintf(targetSession.RemoteAddress)
 	for {
-		processList(targetSession, rpc)
-		time.Sleep(time.Duration(sleepTime) * time.Second)
+		if err := processList(targetSession, rpc); errors.Is(err, common.ErrSessionGone) {
+			fmt.Println("[!] Session is gone, stopping the watcher")
+			os.Exit(common.CategorySessionGone.ExitCode())
+		}
+		time.Sleep(common.WithJitter(time.Duration(sleepTime)*time.Second, jitter))
 		clearScreen()
 	}
 }
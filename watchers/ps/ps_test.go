@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"common"
+	"common/fakesliver"
+)
+
+func TestProcessListPoll(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditor, err := common.NewAuditLogger(auditPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer auditor.Close()
+	requestPacer, err := common.NewPacer(0, 0, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpc := fakesliver.Dial(t, fake, requestPacer.Interceptor, auditor.Interceptor)
+	session := fake.Sessions.Sessions[0]
+
+	// two polls, the same as two passes of the watcher loop
+	var output string
+	for poll := 0; poll < 2; poll++ {
+		output = fakesliver.CaptureOutput(t, func() { processList(session, rpc) })
+	}
+
+	for _, want := range []string{"Process List", "/usr/sbin/sshd -D", "www-data", "test-ubuntu.elf"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected output to contain %q:\n%s", want, output)
+		}
+	}
+	if calls := fake.Called("Ps"); len(calls) != 2 {
+		t.Errorf("expected two Ps calls, got %d", len(calls))
+	}
+
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected two audit entries, got %d", len(lines))
+	}
+	entry := common.AuditEntry{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Method != "/rpcpb.SliverRPC/Ps" || entry.Session != session.ID || entry.Status != "ok" || entry.BytesReceived == 0 {
+		t.Errorf("unexpected audit entry %+v", entry)
+	}
+}
+
+func TestQuietHoursListFirst(t *testing.T) {
+	fake := newFakeSliver(t, "testdata/host")
+	// an empty window is never quiet but still needs the implant timezone
+	requestPacer, err := common.NewPacer(0, 0, 0, "00:00-00:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpc := fakesliver.Dial(t, fake, requestPacer.Interceptor)
+	session := fake.Sessions.Sessions[0]
+
+	polled := make(chan struct{})
+	go func() {
+		processList(session, rpc)
+		close(polled)
+	}()
+	select {
+	case <-polled:
+		t.Fatal("expected the poll to be held until the implant timezone is known")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if !requestPacer.Holding() {
+		t.Error("expected the pacer to be holding requests")
+	}
+
+	fakesliver.CaptureOutput(t, func() {
+		if err := learnTimezone(session, rpc); err != nil {
+			t.Error(err)
+		}
+	})
+	select {
+	case <-polled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the poll to go out once the implant timezone is known")
+	}
+	if calls := fake.Called("Ls"); len(calls) != 1 || calls[0] != "." {
+		t.Errorf("expected one Ls of the working directory, got %v", calls)
+	}
+}